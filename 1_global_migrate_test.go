@@ -87,3 +87,33 @@ func TestMigrationMustRegistration(t *testing.T) {
 		t.Errorf("Unexpected version/description: %d %s", registered[0].Version, registered[0].Description)
 	}
 }
+
+func TestMigrationStructRegistration(t *testing.T) {
+	oldMigrate := globalMigrate
+	defer func() {
+		globalMigrate = oldMigrate
+	}()
+	globalMigrate = NewMigrate(nil)
+
+	fn := func(ctx context.Context, db *mongo.Database) error { return nil }
+	if err := RegisterMigration(Migration{Version: 5, Description: "five", Up: fn, Down: fn}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := RegisterMigration(Migration{Version: 5, Description: "again", Up: fn}); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+	if err := RegisterMigration(Migration{Version: 6}); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+
+	db := &mongo.Database{}
+	m := NewMigrateFromRegistry(db)
+	if m.db != db || len(m.migrations) != 1 || m.migrations[0].Description != "five" {
+		t.Errorf("Unexpected migrator: %+v", m)
+	}
+	m.SetMigrationsCollection("other")
+	if globalMigrate.migrationsCollection == "other" {
+		t.Errorf("Unexpected shared settings")
+	}
+}
@@ -0,0 +1,176 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultAuditCollection = "migrations_audit"
+
+	// auditFilterLimit is a maximum length of rendered command filter stored in audit record.
+	auditFilterLimit = 256
+)
+
+// AuditRecord is a forensic record of migration callback run.
+type AuditRecord struct {
+	// ID is generated by IDGenerator, see SetIDGenerator.
+	ID interface{} `bson:"_id,omitempty"`
+
+	// RunID refers to record of run which performed migration, see Runs.
+	RunID interface{} `bson:"run_id,omitempty"`
+
+	// Track is a name of migration track, see SetTrack.
+	Track string `bson:"track,omitempty"`
+
+	Version     uint64        `bson:"version"`
+	Description string        `bson:"description"`
+	Down        bool          `bson:"down"`
+	Timestamp   time.Time     `bson:"timestamp"`
+	Duration    time.Duration `bson:"duration"`
+
+	// StartClusterTime and EndClusterTime are operation times of cluster before and after migration callback,
+	// so its oplog entries lie between them, e.g. for point-in-time restore. They're zero on standalone servers.
+	StartClusterTime primitive.Timestamp `bson:"start_cluster_time,omitempty"`
+	EndClusterTime   primitive.Timestamp `bson:"end_cluster_time,omitempty"`
+
+	// Error is a text of error returned by migration, empty if it succeeded.
+	Error string `bson:"error,omitempty"`
+
+	// Commands are commands executed by migration. They're captured only if CommandMonitor is attached to client.
+	Commands []CommandRecord `bson:"commands,omitempty"`
+
+	// SlowOperations are the slowest operations of migration recorded by profiler, see SetProfiling.
+	SlowOperations []SlowOperation `bson:"slow_operations,omitempty"`
+}
+
+// CommandRecord is a summary of command executed by migration.
+type CommandRecord struct {
+	Name      string        `bson:"name"`
+	Namespace string        `bson:"namespace"`
+	Duration  time.Duration `bson:"duration"`
+
+	// Filter is a filter, query or pipeline of command in Extended JSON truncated to 256 bytes.
+	Filter string `bson:"filter,omitempty"`
+	Error  string `bson:"error,omitempty"`
+}
+
+// SetAuditCollection replaces name of collection for storing audit records.
+// By default, it is "migrations_audit".
+func (m *Migrate) SetAuditCollection(name string) {
+	m.auditCollection = name
+}
+
+// SetAudit enables writing of audit record for each performed migration and each run, see Runs.
+func (m *Migrate) SetAudit(enabled bool) {
+	m.audit = enabled
+}
+
+// AuditRecords returns audit records of migration with provided version of migrator track (see SetTrack) in order of run.
+func (m *Migrate) AuditRecords(ctx context.Context, version uint64) ([]AuditRecord, error) {
+	// identifiers are not necessarily ordered, see SetIDGenerator
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := m.db.Collection(m.auditCollection).Find(ctx, bson.D{{Key: "version", Value: version}, {Key: "track", Value: m.trackFilter()}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []AuditRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// auditClusterTime returns current operation time of cluster for audit record. Errors are only logged.
+func (m *Migrate) auditClusterTime(ctx context.Context) primitive.Timestamp {
+	// commands like ping are not allowed in transactions
+	ts, err := m.clusterTime(withoutSession(ctx))
+	if err != nil {
+		m.report(MsgAuditTimeFailed, err)
+	}
+
+	return ts
+}
+
+func (m *Migrate) writeAudit(ctx context.Context, rec AuditRecord) {
+	rec.ID = m.newID()
+	rec.Track = m.track
+	if _, err := m.db.Collection(m.auditCollection).InsertOne(ctx, rec); err != nil {
+		m.report(MsgAuditFailed, rec.Version, err)
+	}
+}
+
+func commandNamespace(evt *event.CommandStartedEvent) string {
+	if collection, ok := evt.Command.Index(0).Value().StringValueOK(); ok {
+		return evt.DatabaseName + "." + collection
+	}
+
+	return evt.DatabaseName
+}
+
+// commandFilter renders the most informative part of command: filter of reads, query of the first
+// update or delete statement or aggregation pipeline.
+func commandFilter(command bson.Raw) string {
+	var value bson.RawValue
+	for _, key := range []string{"filter", "query", "q", "pipeline"} {
+		if v, err := command.LookupErr(key); err == nil {
+			value = v
+			break
+		}
+	}
+	for _, key := range []string{"updates", "deletes"} {
+		if v, err := command.LookupErr(key, "0", "q"); err == nil {
+			value = v
+			break
+		}
+	}
+	if value.Type == 0 {
+		return ""
+	}
+
+	data, err := renderValue(value)
+	if err != nil {
+		return ""
+	}
+	if len(data) > auditFilterLimit {
+		data = append(data[:auditFilterLimit:auditFilterLimit], "..."...)
+	}
+
+	return string(data)
+}
+
+// renderValue renders document or array of documents in relaxed Extended JSON.
+func renderValue(value bson.RawValue) ([]byte, error) {
+	if doc, ok := value.DocumentOK(); ok {
+		return bson.MarshalExtJSON(doc, false, false)
+	}
+
+	arr, ok := value.ArrayOK()
+	if !ok {
+		return []byte(value.String()), nil
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	data := []byte{'['}
+	for i, v := range values {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		rendered, err := renderValue(v)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, rendered...)
+	}
+	return append(data, ']'), nil
+}
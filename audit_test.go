@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCommandFilter(t *testing.T) {
+	tests := []struct {
+		command  bson.D
+		expected string
+	}{
+		{bson.D{{Key: "find", Value: "users"}, {Key: "filter", Value: bson.D{{Key: "age", Value: 30}}}}, `{"age":30}`},
+		{bson.D{{Key: "update", Value: "users"}, {Key: "updates", Value: bson.A{
+			bson.D{{Key: "q", Value: bson.D{{Key: "name", Value: "bob"}}}, {Key: "u", Value: bson.D{}}},
+		}}}, `{"name":"bob"}`},
+		{bson.D{{Key: "aggregate", Value: "users"}, {Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{}}},
+			bson.D{{Key: "$count", Value: "n"}},
+		}}}, `[{"$match":{}},{"$count":"n"}]`},
+		{bson.D{{Key: "drop", Value: "users"}}, ""},
+	}
+
+	for _, test := range tests {
+		command, err := bson.Marshal(test.command)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if actual := commandFilter(command); actual != test.expected {
+			t.Errorf("Unexpected filter of %v: %s", test.command, actual)
+		}
+	}
+}
+
+func TestCommandFilterTruncated(t *testing.T) {
+	command, err := bson.Marshal(bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{{Key: "name", Value: strings.Repeat("a", 2*auditFilterLimit)}}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	actual := commandFilter(command)
+	if len(actual) != auditFilterLimit+3 || !strings.HasSuffix(actual, "...") {
+		t.Errorf("Unexpected filter: %s", actual)
+	}
+}
@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultBatchSize = 1000
+
+// BatchOptions configures Batch.
+type BatchOptions struct {
+	// Size is a number of documents read by one query. Default is 1000.
+	Size int
+
+	// Parallelism is a number of workers transforming and writing parts of each batch. Default is 1.
+	Parallelism int
+
+	// ResumeKey is a name of checkpoint storing the last processed "_id", so transformation interrupted
+	// in migration resumes after the last completed batch. Default is "batch:<collection>".
+	ResumeKey string
+
+	// Progress is called after each batch with totals.
+	Progress func(result BatchResult)
+}
+
+// BatchResult reports totals of Batch.
+type BatchResult struct {
+	// Scanned is a number of read documents, Modified is a number of replaced ones.
+	Scanned  int64 `bson:"scanned"`
+	Modified int64 `bson:"modified"`
+}
+
+// batchProgress is a value of Batch checkpoint.
+type batchProgress struct {
+	LastID      bson.RawValue `bson:"last_id"`
+	BatchResult `bson:",inline"`
+}
+
+// Batch rewrites documents of coll matching filter using fn. Documents are read in batches ordered by "_id"
+// instead of single cursor which may time out on huge collections, each batch is split between workers
+// which write replacements by unordered bulk writes. Documents for which fn returns nil are left unchanged,
+// replacement must keep "_id" of document.
+// Inside migration progress is stored in checkpoint after each batch, so run resumed after failure (see ResumeDirty)
+// continues from the last completed batch. fn must be idempotent because failed batch is processed again.
+func Batch(ctx context.Context, coll *mongo.Collection, filter interface{}, fn TransformFunc, opts BatchOptions) (BatchResult, error) {
+	return batchWrite(ctx, coll, filter, replaceModel(fn), opts)
+}
+
+// modelFunc returns write model for provided document. Nil model means that document should be left unchanged.
+type modelFunc func(doc bson.Raw) (mongo.WriteModel, error)
+
+// replaceModel returns modelFunc replacing documents with results of fn.
+func replaceModel(fn TransformFunc) modelFunc {
+	return func(doc bson.Raw) (mongo.WriteModel, error) {
+		replacement, err := fn(doc)
+		if err != nil || replacement == nil {
+			return nil, err
+		}
+		return mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: doc.Lookup("_id")}}).
+			SetReplacement(replacement), nil
+	}
+}
+
+// batchWrite implements Batch writing models returned by model.
+func batchWrite(ctx context.Context, coll *mongo.Collection, filter interface{}, model modelFunc, opts BatchOptions) (BatchResult, error) {
+	if err := checkNamespace(ctx, coll.Name()); err != nil {
+		return BatchResult{}, err
+	}
+	if opts.Size <= 0 {
+		opts.Size = defaultBatchSize
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 1
+	}
+	if opts.ResumeKey == "" {
+		opts.ResumeKey = "batch:" + coll.Name()
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	state, inRun := runFromContext(ctx)
+	var progress batchProgress
+	if inRun {
+		rec, found, err := state.migrate.loadCheckpoint(ctx, state, opts.ResumeKey)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		if found {
+			if err := rec.Value.Unmarshal(&progress); err != nil {
+				return BatchResult{}, fmt.Errorf("migrate: decode checkpoint %q: %w", opts.ResumeKey, err)
+			}
+		}
+	}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(opts.Size))
+	for {
+		batchFilter := filter
+		if progress.LastID.Type != 0 {
+			batchFilter = bson.D{{Key: "$and", Value: bson.A{
+				filter,
+				bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: progress.LastID}}}},
+			}}}
+		}
+
+		cursor, err := coll.Find(ctx, batchFilter, findOpts)
+		if err != nil {
+			return progress.BatchResult, err
+		}
+		var docs []bson.Raw
+		if err := cursor.All(ctx, &docs); err != nil {
+			return progress.BatchResult, err
+		}
+		if len(docs) == 0 {
+			return progress.BatchResult, nil
+		}
+
+		modified, err := writeBatch(ctx, coll, docs, model, opts.Parallelism)
+		if err != nil {
+			return progress.BatchResult, err
+		}
+		progress.Scanned += int64(len(docs))
+		progress.Modified += modified
+		progress.LastID = docs[len(docs)-1].Lookup("_id")
+
+		if inRun {
+			if err := state.migrate.injectFault(FaultMidBatch, state.version); err != nil {
+				return progress.BatchResult, err
+			}
+			if err := state.migrate.saveCheckpoint(ctx, state, opts.ResumeKey, progress); err != nil {
+				return progress.BatchResult, err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress.BatchResult)
+		}
+		if len(docs) < opts.Size {
+			return progress.BatchResult, nil
+		}
+	}
+}
+
+// writeBatch transforms docs by parallel workers and writes models. It returns number of modified documents.
+func writeBatch(ctx context.Context, coll *mongo.Collection, docs []bson.Raw, model modelFunc, parallelism int) (int64, error) {
+	chunk := (len(docs) + parallelism - 1) / parallelism
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		modified int64
+		errs     []error
+	)
+	for start := 0; start < len(docs); start += chunk {
+		end := start + chunk
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		wg.Add(1)
+		go func(part []bson.Raw) {
+			defer wg.Done()
+			n, err := writePart(ctx, coll, part, model)
+
+			mu.Lock()
+			defer mu.Unlock()
+			modified += n
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(docs[start:end])
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return modified, errs[0]
+	}
+	return modified, nil
+}
+
+func writePart(ctx context.Context, coll *mongo.Collection, docs []bson.Raw, model modelFunc) (int64, error) {
+	models := make([]mongo.WriteModel, 0, len(docs))
+	for _, doc := range docs {
+		m, err := model(doc)
+		if err != nil {
+			return 0, fmt.Errorf("migrate: transform document %s: %w", doc.Lookup("_id"), err)
+		}
+		if m == nil {
+			continue
+		}
+		models = append(models, m)
+	}
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	res, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, fmt.Errorf("migrate: write batch of %q: %w", coll.Name(), err)
+	}
+	return res.ModifiedCount, nil
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestBatchFiltered(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Include: []string{"sessions"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+	// client connects lazily, so no server is needed
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	coll := client.Database("test").Collection("users")
+	keep := func(bson.Raw) (interface{}, error) { return nil, nil }
+	if _, err := Batch(ctx, coll, nil, keep, BatchOptions{}); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWriteBatchTransformError(t *testing.T) {
+	expectedErr := errors.New("bad document")
+	docs := make([]bson.Raw, 0, 5)
+	for i := 0; i < 5; i++ {
+		doc, _ := bson.Marshal(bson.D{{Key: "_id", Value: i}})
+		docs = append(docs, doc)
+	}
+
+	fn := func(doc bson.Raw) (interface{}, error) {
+		if doc.Lookup("_id").Int32() == 3 {
+			return nil, expectedErr
+		}
+		return nil, nil
+	}
+	// nothing is written when all replacements are nil, so collection is not used
+	if _, err := writeBatch(context.Background(), nil, docs, replaceModel(fn), 2); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
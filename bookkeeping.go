@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// schemaRecordID is an id of document storing layout version of bookkeeping collections.
+// Like run control document, it is stored in locks collection.
+const schemaRecordID = "schema"
+
+// ErrSchemaTooNew returned when bookkeeping collections were upgraded by newer version of package,
+// so this version may not interpret their documents correctly.
+var ErrSchemaTooNew = errors.New("migrate: bookkeeping schema is newer than supported")
+
+// schemaRecord is a state of bookkeeping schema.
+type schemaRecord struct {
+	Version    int       `bson:"version"`
+	UpgradedAt time.Time `bson:"upgraded_at"`
+}
+
+// schemaUpgrade converts bookkeeping collections from previous layout version.
+// Upgrades must be idempotent because concurrent runs without run lock may perform the same upgrade.
+type schemaUpgrade struct {
+	description string
+	apply       func(ctx context.Context, m *Migrate) error
+}
+
+// schemaUpgrades are upgrades of bookkeeping layout, upgrade with index i produces version i+1.
+// Append new upgrades to the end when layout of documents changes, never reorder or remove them.
+var schemaUpgrades = []schemaUpgrade{
+	{
+		description: "index version records by version",
+		apply: func(ctx context.Context, m *Migrate) error {
+			if m.versionStore != nil {
+				// custom stores maintain their layout themselves
+				return nil
+			}
+			model := mongo.IndexModel{Keys: bson.D{{Key: "version", Value: 1}}}
+			_, err := m.db.Collection(m.migrationsCollection).Indexes().CreateOne(ctx, model)
+			return err
+		},
+	},
+}
+
+// SchemaVersion returns layout version of bookkeeping collections and the newest version supported by package.
+// Zero version means collections were not upgraded yet.
+func (m *Migrate) SchemaVersion(ctx context.Context) (current, supported int, err error) {
+	var rec schemaRecord
+	err = m.db.Collection(m.locksCollection).FindOne(ctx, bson.D{{Key: "_id", Value: m.trackID(schemaRecordID)}}).Decode(&rec)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return 0, len(schemaUpgrades), nil
+	case err != nil:
+		return 0, 0, fmt.Errorf("migrate: read bookkeeping schema: %w", err)
+	}
+
+	return rec.Version, len(schemaUpgrades), nil
+}
+
+// UpgradeSchema converts documents of bookkeeping collections (history, audit, locks and so on)
+// to layout of current package version. It is called automatically by Up, Down, DownTo and MigrateTo,
+// so upgrade of package does not require manual conversion. ErrSchemaTooNew is returned if collections
+// were upgraded by newer version of package.
+func (m *Migrate) UpgradeSchema(ctx context.Context) error {
+	current, supported, err := m.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > supported {
+		return fmt.Errorf("%w: version %d, supported %d", ErrSchemaTooNew, current, supported)
+	}
+
+	for version := current; version < supported; version++ {
+		upgrade := schemaUpgrades[version]
+		if err := upgrade.apply(ctx, m); err != nil {
+			return fmt.Errorf("migrate: upgrade bookkeeping schema to version %d (%s): %w", version+1, upgrade.description, err)
+		}
+
+		// $max keeps version of concurrent process which went further
+		update := bson.D{{Key: "$max", Value: bson.D{{Key: "version", Value: version + 1}}},
+			{Key: "$set", Value: bson.D{{Key: "upgraded_at", Value: time.Now().UTC()}}}}
+		_, err := m.db.Collection(m.locksCollection).UpdateOne(ctx, bson.D{{Key: "_id", Value: m.trackID(schemaRecordID)}}, update,
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("migrate: write bookkeeping schema version: %w", err)
+		}
+		m.report(MsgSchemaUpgraded, version+1, upgrade.description)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultCappedBatchSize = 1000
+	cappedStagingSuffix    = "_converting"
+	cappedBackupSuffix     = "_capped_backup"
+)
+
+// CappedConversion describes target of ConvertCapped.
+type CappedConversion struct {
+	// Size is a maximum size of target capped collection in bytes. Zero means conversion to regular collection.
+	Size int64
+
+	// MaxDocuments is a maximum number of documents of target capped collection. Ignored if Size is zero.
+	MaxDocuments int64
+
+	// BatchSize is a number of documents copied by one insert. Default is 1000.
+	BatchSize int
+
+	// KeepBackup disables removal of original collection. It's renamed to "<collection>_capped_backup"
+	// replacing backup kept by previous conversion.
+	KeepBackup bool
+}
+
+// ConvertCapped converts capped collection to regular one or changes limits of capped collection.
+// Server doesn't support it in-place, so documents and indexes are copied to staging collection
+// "<collection>_converting" in natural order, copy is verified and then renamed over original collection.
+// Stale staging collection left by conversion interrupted before renames is dropped and conversion starts over.
+// Conversion interrupted between renames (original collection is renamed to backup, staging one is not renamed yet)
+// is completed from verified staging collection, so helper may be safely retried.
+// Writes to collection during conversion are lost, so writers must be stopped.
+func ConvertCapped(ctx context.Context, db *mongo.Database, collection string, target CappedConversion) error {
+	// staging and backup collections are dropped, so they must pass filter too
+	if err := checkNamespace(ctx, collection, collection+cappedStagingSuffix, collection+cappedBackupSuffix); err != nil {
+		return err
+	}
+	if err := checkRunCommands(ctx, "drop", "create", "createIndexes", "insert", "renameCollection"); err != nil {
+		return err
+	}
+	if target.Size < 0 || target.MaxDocuments < 0 {
+		return errors.New("migrate: capped size and max documents must not be negative")
+	}
+	if target.BatchSize <= 0 {
+		target.BatchSize = defaultCappedBatchSize
+	}
+
+	source := db.Collection(collection)
+	staging := db.Collection(collection + cappedStagingSuffix)
+	backup := collection + cappedBackupSuffix
+
+	existing, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{collection, staging.Name(), backup}}}}})
+	if err != nil {
+		return fmt.Errorf("migrate: list collections: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+	if !exists[collection] && exists[backup] && exists[staging.Name()] {
+		// staging collection was verified before original one was renamed to backup
+		return finishCappedConversion(ctx, db, collection, target)
+	}
+
+	if err := staging.Drop(ctx); err != nil {
+		return fmt.Errorf("migrate: drop stale staging collection of %q: %w", collection, err)
+	}
+
+	createOpts := options.CreateCollection()
+	if target.Size > 0 {
+		createOpts.SetCapped(true).SetSizeInBytes(target.Size)
+		if target.MaxDocuments > 0 {
+			createOpts.SetMaxDocuments(target.MaxDocuments)
+		}
+	}
+	if err := db.CreateCollection(ctx, staging.Name(), createOpts); err != nil {
+		return fmt.Errorf("migrate: create staging collection of %q: %w", collection, err)
+	}
+
+	if err := copyIndexes(ctx, source, staging); err != nil {
+		return err
+	}
+
+	copied, err := copyDocuments(ctx, source, staging, target.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyCappedCopy(ctx, source, staging, copied, target); err != nil {
+		return err
+	}
+
+	// backup kept by previous conversion is replaced
+	if err := renameCollection(ctx, db, collection, backup, true); err != nil {
+		return err
+	}
+
+	return finishCappedConversion(ctx, db, collection, target)
+}
+
+// finishCappedConversion renames staging collection over original one which is already renamed to backup.
+func finishCappedConversion(ctx context.Context, db *mongo.Database, collection string, target CappedConversion) error {
+	if err := renameCollection(ctx, db, collection+cappedStagingSuffix, collection, false); err != nil {
+		return err
+	}
+	if target.KeepBackup {
+		return nil
+	}
+
+	if err := db.Collection(collection + cappedBackupSuffix).Drop(ctx); err != nil {
+		return fmt.Errorf("migrate: drop backup of %q: %w", collection, err)
+	}
+
+	return nil
+}
+
+func copyIndexes(ctx context.Context, from, to *mongo.Collection) error {
+	cursor, err := from.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: list indexes of %q: %w", from.Name(), err)
+	}
+
+	var specs []bson.D
+	if err := cursor.All(ctx, &specs); err != nil {
+		return err
+	}
+
+	var indexes bson.A
+	for _, spec := range specs {
+		var (
+			index bson.D
+			name  interface{}
+		)
+		for _, elem := range spec {
+			switch elem.Key {
+			case "v", "ns":
+				continue
+			case "name":
+				name = elem.Value
+			}
+			index = append(index, elem)
+		}
+		if name == "_id_" {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	command := bson.D{{Key: "createIndexes", Value: to.Name()}, {Key: "indexes", Value: indexes}}
+	if err := to.Database().RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("migrate: copy indexes of %q: %w", from.Name(), err)
+	}
+
+	return nil
+}
+
+func copyDocuments(ctx context.Context, from, to *mongo.Collection, batchSize int) (int64, error) {
+	cursor, err := from.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "$natural", Value: 1}}))
+	if err != nil {
+		return 0, fmt.Errorf("migrate: read %q: %w", from.Name(), err)
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		copied int64
+		batch  []interface{}
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := to.InsertMany(ctx, batch); err != nil {
+			return fmt.Errorf("migrate: copy documents of %q: %w", from.Name(), err)
+		}
+		copied += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		batch = append(batch, append(bson.Raw(nil), cursor.Current...))
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return copied, err
+	}
+
+	return copied, flush()
+}
+
+// verifyCappedCopy checks that staging collection contains all copied documents. Capped target may evict
+// oldest documents, in this case it must keep the newest ones.
+func verifyCappedCopy(ctx context.Context, source, staging *mongo.Collection, copied int64, target CappedConversion) error {
+	count, err := staging.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	if target.Size == 0 && count != copied {
+		return fmt.Errorf("migrate: staging collection of %q has %d documents, expected %d", source.Name(), count, copied)
+	}
+	if count > copied {
+		return fmt.Errorf("migrate: staging collection of %q has %d documents, more than %d copied", source.Name(), count, copied)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	last := options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}})
+	sourceLast, err := source.FindOne(ctx, bson.D{}, last).Raw()
+	if err != nil {
+		return err
+	}
+	stagingLast, err := staging.FindOne(ctx, bson.D{}, last).Raw()
+	if err != nil {
+		return err
+	}
+	if !sourceLast.Lookup("_id").Equal(stagingLast.Lookup("_id")) {
+		return fmt.Errorf("migrate: newest document of %q is missing in staging collection", source.Name())
+	}
+
+	return nil
+}
+
+func renameCollection(ctx context.Context, db *mongo.Database, from, to string, dropTarget bool) error {
+	command := bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + from},
+		{Key: "to", Value: db.Name() + "." + to},
+		{Key: "dropTarget", Value: dropTarget},
+	}
+	if err := db.Client().Database("admin").RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("migrate: rename %q to %q: %w", from, to, err)
+	}
+
+	return nil
+}
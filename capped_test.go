@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConvertCappedFiltered(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Exclude: []string{"logs"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+	if err := ConvertCapped(ctx, nil, "logs", CappedConversion{}); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
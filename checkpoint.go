@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultCheckpointsCollection = "migrations_checkpoints"
+
+// checkpointRecord stores progress of migration which was interrupted.
+// Checkpoints of migration are removed once it completes successfully.
+type checkpointRecord struct {
+	Version   uint64        `bson:"version"`
+	Direction direction     `bson:"direction"`
+	Name      string        `bson:"name"`
+	Value     bson.RawValue `bson:"value,omitempty"`
+	Timestamp time.Time     `bson:"timestamp"`
+}
+
+// SetCheckpointsCollection replaces name of collection for storing progress of interrupted migrations.
+// By default, it is "migrations_checkpoints".
+func (m *Migrate) SetCheckpointsCollection(name string) {
+	m.checkpointsCollection = name
+}
+
+func checkpointFilter(state *runState, name string) bson.D {
+	return bson.D{
+		{Key: "version", Value: state.version},
+		{Key: "direction", Value: state.direction},
+		{Key: "name", Value: name},
+	}
+}
+
+func (m *Migrate) loadCheckpoint(ctx context.Context, state *runState, name string) (rec checkpointRecord, found bool, err error) {
+	err = m.db.Collection(m.checkpointsCollection).FindOne(ctx, checkpointFilter(state, name)).Decode(&rec)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return checkpointRecord{}, false, nil
+	case err != nil:
+		return checkpointRecord{}, false, fmt.Errorf("migrate: load checkpoint %q: %w", name, err)
+	}
+
+	return rec, true, nil
+}
+
+func (m *Migrate) saveCheckpoint(ctx context.Context, state *runState, name string, value interface{}) error {
+	set := bson.D{{Key: "timestamp", Value: time.Now().UTC()}}
+	if value != nil {
+		set = append(set, bson.E{Key: "value", Value: value})
+	}
+
+	update := bson.D{{Key: "$set", Value: set}}
+	opts := options.Update().SetUpsert(true)
+	_, err := m.db.Collection(m.checkpointsCollection).UpdateOne(ctx, checkpointFilter(state, name), update, opts)
+	if err != nil {
+		return fmt.Errorf("migrate: save checkpoint %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func (m *Migrate) clearCheckpoints(ctx context.Context, version uint64, dir direction) error {
+	filter := bson.D{{Key: "version", Value: version}, {Key: "direction", Value: dir}}
+	if _, err := m.db.Collection(m.checkpointsCollection).DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("migrate: clear checkpoints: %w", err)
+	}
+
+	return nil
+}
+
+// Savepoint splits migration into several logical steps.
+// It calls fn only if savepoint with provided name was not passed by current migration before:
+// when migration fails after some savepoints, run resumed by ResumeDirty continues from the first not passed one.
+// Passed savepoints are forgotten when migration completes successfully.
+// Names must be unique within migration. Outside of migration process fn is called unconditionally.
+func Savepoint(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return fn(ctx)
+	}
+
+	_, passed, err := state.migrate.loadCheckpoint(ctx, state, name)
+	if err != nil {
+		return err
+	}
+	if passed {
+		state.migrate.report(MsgSavepointSkipped, name, state.version)
+		return nil
+	}
+
+	if err := fn(ctx); err != nil {
+		return err
+	}
+
+	return state.migrate.saveCheckpoint(ctx, state, name, nil)
+}
@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSavepointOutsideMigration(t *testing.T) {
+	var called bool
+	err := Savepoint(context.Background(), "test", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Errorf("Savepoint callback unexpectedly not called")
+	}
+}
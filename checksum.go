@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrChecksumMismatch returned when applied migration was changed after applying.
+	ErrChecksumMismatch = errors.New("migrate: checksum mismatch")
+
+	// ErrMissingMigration returned when applied migration is not registered anymore.
+	ErrMissingMigration = errors.New("migrate: applied migration is missing")
+)
+
+// Checksum returns hex-encoded SHA-256 of data, suitable for Migration.Checksum.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate checks that history of applied migrations matches registered migrations:
+// each applied version must be registered and its checksum must be equal to recorded one.
+// Checksums are compared only if both recorded and registered ones are set.
+// All found problems are reported in one error matching ErrMissingMigration and/or ErrChecksumMismatch.
+func (m *Migrate) Validate(ctx context.Context) error {
+	current, err := m.currentRecord(ctx)
+	if err != nil {
+		return err
+	}
+	history, err := m.Versions().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return validateHistory(m.migrations, history, current.Version)
+}
+
+func validateHistory(migrations []Migration, history []VersionRecord, current uint64) error {
+	registered := make(map[uint64]Migration, len(migrations))
+	for _, migration := range migrations {
+		registered[migration.Version] = migration
+	}
+
+	// the latest record of version describes how it was applied
+	applied := make(map[uint64]VersionRecord)
+	for _, rec := range history {
+		if rec.Version != 0 && rec.Version <= current && !rec.Dirty {
+			applied[rec.Version] = rec
+		}
+	}
+
+	var errs []error
+	for _, rec := range sortedRecords(applied) {
+		migration, ok := registered[rec.Version]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%w: version %d (%s)", ErrMissingMigration, rec.Version, rec.Description))
+		case rec.Checksum != "" && migration.Checksum != "" && rec.Checksum != migration.Checksum:
+			errs = append(errs, fmt.Errorf("%w: version %d (%s) applied with %s, registered %s",
+				ErrChecksumMismatch, rec.Version, rec.Description, rec.Checksum, migration.Checksum))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func sortedRecords(records map[uint64]VersionRecord) []VersionRecord {
+	ret := make([]VersionRecord, 0, len(records))
+	for _, rec := range records {
+		ret = append(ret, rec)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret
+}
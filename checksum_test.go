@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateHistory(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Checksum: "a"},
+		{Version: 2, Checksum: "b"},
+		{Version: 3},
+		{Version: 5, Checksum: "e"},
+	}
+
+	for name, tc := range map[string]struct {
+		history  []VersionRecord
+		current  uint64
+		mismatch bool
+		missing  bool
+	}{
+		"clean": {
+			history: []VersionRecord{{Version: 1, Checksum: "a"}, {Version: 2, Checksum: "b"}, {Version: 3, Checksum: "c"}},
+			current: 3,
+		},
+		"no recorded checksum": {
+			history: []VersionRecord{{Version: 1}, {Version: 2}},
+			current: 2,
+		},
+		"edited": {
+			history:  []VersionRecord{{Version: 1, Checksum: "a"}, {Version: 2, Checksum: "old"}},
+			current:  2,
+			mismatch: true,
+		},
+		"reapplied after edit": {
+			history: []VersionRecord{{Version: 1, Checksum: "a"}, {Version: 2, Checksum: "old"}, {Version: 1, Checksum: "a"}, {Version: 2, Checksum: "b"}},
+			current: 2,
+		},
+		"reverted edited": {
+			history: []VersionRecord{{Version: 1, Checksum: "a"}, {Version: 2, Checksum: "old"}, {Version: 1, Checksum: "a"}},
+			current: 1,
+		},
+		"missing": {
+			history: []VersionRecord{{Version: 1, Checksum: "a"}, {Version: 4}},
+			current: 4,
+			missing: true,
+		},
+		"both": {
+			history:  []VersionRecord{{Version: 1, Checksum: "x"}, {Version: 4}},
+			current:  4,
+			mismatch: true,
+			missing:  true,
+		},
+	} {
+		err := validateHistory(migrations, tc.history, tc.current)
+		if errors.Is(err, ErrChecksumMismatch) != tc.mismatch || errors.Is(err, ErrMissingMigration) != tc.missing {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const connectTimeout = 20 * time.Second
+
+// dbFlags are common flags of commands working with database.
+type dbFlags struct {
+	uri        string
+	collection string
+	prefix     string
+	suffix     string
+	track      string
+	codes      bool
+}
+
+func (f *dbFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.uri, "uri", os.Getenv("MONGO_URL"), "MongoDB connection string with database name in path (default is $MONGO_URL)")
+	flags.StringVar(&f.collection, "collection", "", "collection for migrations history (default is \"migrations\")")
+	flags.StringVar(&f.prefix, "prefix", "", "prefix of bookkeeping collection names")
+	flags.StringVar(&f.suffix, "suffix", "", "suffix of bookkeeping collection names")
+	flags.StringVar(&f.track, "track", "", "name of independent migration track, e.g. \"analytics\"")
+	flags.BoolVar(&f.codes, "codes", false, codesUsage)
+}
+
+const codesUsage = "prefix messages with their codes, e.g. \"[migrated-up] Migrated UP: 1 init\""
+
+// newMigrate returns migrator configured with bookkeeping collection names from flags.
+func (f *dbFlags) newMigrate(db *mongo.Database, migrations ...migrate.Migration) *migrate.Migrate {
+	m := migrate.NewMigrate(db, migrations...)
+	m.SetCollectionAffixes(f.prefix, f.suffix)
+	m.SetTrack(f.track)
+	if f.collection != "" {
+		m.SetMigrationsCollection(f.collection)
+	}
+	if f.codes {
+		m.SetLocalizer(withCode)
+	}
+	return m
+}
+
+// withCode renders message text prefixed with message code, so scripts may match messages by codes.
+func withCode(msg migrate.Message) (string, bool) {
+	return "[" + string(msg.Code) + "] " + msg.String(), true
+}
+
+// text renders message printed by the tool without migrator, see "-codes" flag.
+func (f *dbFlags) text(msg migrate.Message) string {
+	return messageText(f.codes)(msg)
+}
+
+// messageText returns renderer of messages printed by the tool, see withCode.
+func messageText(codes bool) func(migrate.Message) string {
+	if codes {
+		return func(msg migrate.Message) string {
+			text, _ := withCode(msg)
+			return text
+		}
+	}
+	return migrate.Message.String
+}
+
+func (f *dbFlags) connect(ctx context.Context) (*mongo.Client, *mongo.Database, error) {
+	if f.uri == "" {
+		return nil, nil, errors.New("connection string is not set")
+	}
+
+	addr, err := url.Parse(f.uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse connection string: %w", err)
+	}
+	name := strings.TrimLeft(addr.Path, "/")
+	if name == "" {
+		return nil, nil, errors.New("database name is not set in connection string")
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(f.uri))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, client.Database(name), nil
+}
+
+type logger struct {
+	w io.Writer
+}
+
+func (l logger) Printf(format string, args ...any) {
+	fmt.Fprintf(l.w, format+"\n", args...)
+}
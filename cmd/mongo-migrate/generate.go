@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	migrate "github.com/xakep666/mongo-migrate"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func runSchema(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var db dbFlags
+	db.register(flags)
+	out := flags.String("out", "", "file to write schema snapshot to (default is stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, database, err := db.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	schema, err := migrate.ExportSchema(ctx, database, db.newMigrate(database).BookkeepingCollections()...)
+	if err != nil {
+		return err
+	}
+
+	data, err := bson.MarshalExtJSONIndent(schema, false, false, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0o644)
+}
+
+func runGenerate(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var db dbFlags
+	db.register(flags)
+	from := flags.String("from", "", "current schema snapshot file (default is live database schema from -uri)")
+	to := flags.String("to", "", "desired schema snapshot file")
+	dir := flags.String("dir", "migrations", "directory of migration files")
+	name := flags.String("name", "schema_diff", "description of generated migration")
+	version := flags.Uint64("version", 0, "version of generated migration (default is next after the newest in -dir)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return errors.New("desired schema is not set")
+	}
+
+	desired, err := readSchema(*to)
+	if err != nil {
+		return err
+	}
+
+	var current migrate.Schema
+	if *from != "" {
+		current, err = readSchema(*from)
+	} else {
+		current, err = liveSchema(&db)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *version == 0 {
+		migrations, err := migrate.NewFileMigrations(os.DirFS(*dir), ".")
+		if err != nil {
+			return err
+		}
+		*version = 1
+		if len(migrations) > 0 {
+			*version = migrations[len(migrations)-1].Version + 1
+		}
+	}
+
+	up, down := migrate.DiffSchema(current, desired)
+	if len(up) == 0 {
+		fmt.Fprintln(stdout, db.text(migrate.NewMessage(migrate.MsgSchemasEqual)))
+		return nil
+	}
+
+	written, err := migrate.WriteSchemaMigration(*dir, *version, *name, up, down)
+	for _, file := range written {
+		fmt.Fprintln(stdout, db.text(migrate.NewMessage(migrate.MsgFileCreated, file)))
+	}
+	return err
+}
+
+func readSchema(name string) (migrate.Schema, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return migrate.Schema{}, err
+	}
+
+	var schema migrate.Schema
+	if err := bson.UnmarshalExtJSON(data, false, &schema); err != nil {
+		return migrate.Schema{}, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return schema, nil
+}
+
+func liveSchema(db *dbFlags) (migrate.Schema, error) {
+	ctx := context.Background()
+	client, database, err := db.connect(ctx)
+	if err != nil {
+		return migrate.Schema{}, err
+	}
+	defer client.Disconnect(ctx)
+
+	return migrate.ExportSchema(ctx, database, db.newMigrate(database).BookkeepingCollections()...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "from.json")
+	to := filepath.Join(dir, "to.json")
+	migrations := filepath.Join(dir, "migrations")
+	files := map[string]string{
+		from: `{"collections": [{"name": "users"}]}`,
+		to:   `{"collections": [{"name": "users", "indexes": [{"name": "login_1", "key": {"login": 1}, "unique": true}]}]}`,
+		filepath.Join(migrations, "1_init.up.json"): `{"create": "users"}`,
+	}
+	if err := os.MkdirAll(migrations, 0o755); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"generate", "-from", from, "-to", to, "-dir", migrations, "-name", "login_index"}, &stdout, &stderr)
+	if code != 0 {
+		t.Errorf("Unexpected exit code %d: %s", code, stderr.String())
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(migrations, "2_login_index.up.json"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !strings.Contains(string(data), `"createIndexes": "users"`) {
+		t.Errorf("Unexpected generated migration: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(migrations, "2_login_index.down.json")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+// templates use custom delimiters to not clash with composite literals of generated code
+var scaffoldTemplates = template.Must(template.New("").Delims("[[", "]]").ParseFS(templatesFS, "templates/*.tmpl"))
+
+type scaffoldFile struct {
+	template string
+	path     string
+}
+
+type scaffoldData struct {
+	Package string
+	Dir     string
+}
+
+func runInit(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", "migrations", "directory of migrations package")
+	pkg := flags.String("package", "", "name of migrations package (default is base name of -dir)")
+	force := flags.Bool("force", false, "overwrite existing files")
+	codes := flags.Bool("codes", false, codesUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	data := scaffoldData{Package: *pkg, Dir: filepath.ToSlash(filepath.Clean(*dir))}
+	if data.Package == "" {
+		data.Package = path.Base(data.Dir)
+	}
+
+	return scaffold(".", data, *force, stdout, messageText(*codes))
+}
+
+// scaffold writes migrations package with example migration, Makefile and docker-compose test setup into root.
+// Existing files are kept untouched unless force is set.
+func scaffold(root string, data scaffoldData, force bool, stdout io.Writer, text func(migrate.Message) string) error {
+	files := []scaffoldFile{
+		{template: "migrations.go.tmpl", path: path.Join(data.Dir, "migrations.go")},
+		{template: "example.go.tmpl", path: path.Join(data.Dir, "1_example.go")},
+		{template: "migrations_test.go.tmpl", path: path.Join(data.Dir, "migrations_integration_test.go")},
+		{template: "Makefile.tmpl", path: "Makefile"},
+		{template: "docker-compose.yml.tmpl", path: "docker-compose.yml"},
+	}
+
+	for _, file := range files {
+		target := filepath.Join(root, filepath.FromSlash(file.path))
+		if _, err := os.Stat(target); err == nil && !force {
+			fmt.Fprintln(stdout, text(migrate.NewMessage(migrate.MsgFileSkipped, file.path)))
+			continue
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err := writeTemplate(target, file.template, data); err != nil {
+			return fmt.Errorf("write %s: %w", file.path, err)
+		}
+		fmt.Fprintln(stdout, text(migrate.NewMessage(migrate.MsgFileCreated, file.path)))
+	}
+
+	return nil
+}
+
+func writeTemplate(target, name string, data scaffoldData) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	if err := scaffoldTemplates.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
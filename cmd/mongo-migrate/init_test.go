@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+func TestScaffold(t *testing.T) {
+	root := t.TempDir()
+	var out bytes.Buffer
+	if err := scaffold(root, scaffoldData{Package: "dbmigrations", Dir: "internal/dbmigrations"}, false, &out, migrate.Message.String); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	for _, name := range []string{"migrations.go", "1_example.go", "migrations_integration_test.go"} {
+		file := filepath.Join(root, "internal", "dbmigrations", name)
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ParseComments)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if f.Name.Name != "dbmigrations" {
+			t.Errorf("Unexpected package name in %s: %s", name, f.Name.Name)
+		}
+	}
+
+	makefile, err := os.ReadFile(filepath.Join(root, "Makefile"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !strings.Contains(string(makefile), "./internal/dbmigrations/...") {
+		t.Errorf("Unexpected Makefile contents: %s", makefile)
+	}
+	if _, err := os.Stat(filepath.Join(root, "docker-compose.yml")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestScaffoldKeepsExisting(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "Makefile"), []byte("all:\n"), 0o644); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	var out bytes.Buffer
+	if err := scaffold(root, scaffoldData{Package: "migrations", Dir: "migrations"}, false, &out, migrate.Message.String); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	makefile, err := os.ReadFile(filepath.Join(root, "Makefile"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if string(makefile) != "all:\n" {
+		t.Errorf("Existing Makefile unexpectedly overwritten")
+	}
+	if !strings.Contains(out.String(), "skip Makefile") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Command mongo-migrate is a command-line tool for managing MongoDB migrations.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+// Exit codes are stable, so scripts may branch on them.
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitDirty   = 2 // migration failed halfway, see "force" and "repair"
+	exitPending = 3 // "status" and "version" found pending migrations
+	exitLocked  = 4 // run lock is held by another process
+)
+
+// errPending returned by "status" and "version" commands when database is behind.
+var errPending = errors.New("pending migrations")
+
+type command struct {
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"init":     {usage: "scaffold migrations package", run: runInit},
+	"up":       {usage: "apply [n|all] pending migrations (default is all)", run: runUp},
+	"down":     {usage: "revert [n|all] applied migrations (default is 1)", run: runDown},
+	"goto":     {usage: "migrate up or down to <version>", run: runGoto},
+	"status":   {usage: "show applied, pending and missing migrations", run: runStatus},
+	"version":  {usage: "show current database version", run: runVersion},
+	"validate": {usage: "check applied migrations for edits and missing files", run: runValidate},
+	"verify":   {usage: "check history of restored database for consistency", run: runVerify},
+	"force":    {usage: "set database version to <version> without running migrations", run: runForce},
+	"repair":   {usage: "clear dirty state of failed migration, so it is started from scratch", run: runRepair},
+	"pause":    {usage: "pause running migrations before their next migration", run: runPause},
+	"resume":   {usage: "resume paused migrations", run: runResume},
+	"watch":    {usage: "apply new and changed migration files to development database", run: runWatch},
+	"schema":   {usage: "export schema snapshot of database", run: runSchema},
+	"generate": {usage: "generate migration files from schema snapshots diff", run: runGenerate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	text := messageText(codesRequested(args))
+	if len(args) == 0 {
+		usage(stderr, text)
+		return exitError
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintln(stderr, text(migrate.NewMessage(migrate.MsgUnknownCommand, args[0])))
+		usage(stderr, text)
+		return exitError
+	}
+
+	err := cmd.run(args[1:], stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, text(migrate.NewMessage(migrate.MsgCommandFailed, args[0], err)))
+	}
+	return exitCode(err)
+}
+
+// codesRequested reports whether "-codes" flag is passed, so messages printed outside of commands
+// are prefixed with codes as well.
+func codesRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-codes", "--codes", "-codes=true", "--codes=true":
+			return true
+		}
+	}
+	return false
+}
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, migrate.ErrDirty):
+		return exitDirty
+	case errors.Is(err, errPending):
+		return exitPending
+	case errors.Is(err, migrate.ErrLocked):
+		return exitLocked
+	default:
+		return exitError
+	}
+}
+
+func usage(w io.Writer, text func(migrate.Message) string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, text(migrate.NewMessage(migrate.MsgUsage)))
+	for _, name := range names {
+		fmt.Fprintln(w, text(migrate.NewMessage(migrate.MsgUsageCommand, name, commands[name].usage)))
+	}
+	fmt.Fprintln(w, text(migrate.NewMessage(migrate.MsgUsageExitCodes)))
+}
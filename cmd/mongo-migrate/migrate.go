@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrateFlags are common flags of commands performing migrations.
+type migrateFlags struct {
+	db    dbFlags
+	dir   string
+	tui   bool
+	quiet bool
+	lock  time.Duration
+	env   string
+
+	secretsDir string
+
+	// loaded are migrations of opened migrator
+	loaded []migrate.Migration
+}
+
+func (f *migrateFlags) register(flags *flag.FlagSet) {
+	f.db.register(flags)
+	flags.StringVar(&f.dir, "dir", "", "directory with migration files (default is only registered migrations)")
+	flags.BoolVar(&f.tui, "tui", false, "show plan, live progress and summary (up and down only)")
+	flags.DurationVar(&f.lock, "lock", 0, "TTL of run lock, concurrent run fails with exit code 4 (default is no lock)")
+	flags.StringVar(&f.secretsDir, "secrets-dir", "", "directory with files of secrets referenced by migration files (default is environment variables)")
+	flags.StringVar(&f.env, "env", os.Getenv("MONGO_MIGRATE_ENV"), "name of environment, migrations forbidden there are refused (default is $MONGO_MIGRATE_ENV)")
+	flags.BoolVar(&f.quiet, "quiet", false, "print only state line \"version=<n> pending=<n> dirty=<bool>\"")
+}
+
+// migrations returns registered migrations together with ones loaded from directory.
+// Registered migrations are present only if command is built with migrations package imported.
+func (f *migrateFlags) migrations() ([]migrate.Migration, error) {
+	migrations := migrate.RegisteredMigrations()
+	if f.dir == "" {
+		return migrations, nil
+	}
+
+	files, err := migrate.NewFileMigrations(os.DirFS(f.dir), ".")
+	if err != nil {
+		return nil, fmt.Errorf("load migrations from %q: %w", f.dir, err)
+	}
+	migrations = append(migrations, files...)
+	if err := migrate.ValidateMigrations(migrations); err != nil {
+		return nil, err
+	}
+
+	return migrations, nil
+}
+
+// open parses flags, connects to database and calls fn with migrator.
+// The first interrupt stops run after current migration, the second one cancels context passed to fn.
+func (f *migrateFlags) open(name string, args []string, stdout, stderr io.Writer,
+	fn func(ctx context.Context, m *migrate.Migrate, args []string) error) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	f.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	migrations, err := f.migrations()
+	if err != nil {
+		return err
+	}
+
+	client, database, err := f.db.connect(context.Background())
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	f.loaded = migrations
+	m := f.newMigrate(database, migrations, stdout)
+	ctx, stop := m.NotifyContext(context.Background())
+	defer stop()
+
+	err = fn(ctx, m, flags.Args())
+	if !f.quiet {
+		return err
+	}
+	// state is meaningful also when command reports dirty database or pending migrations
+	if code := exitCode(err); code != exitOK && code != exitDirty && code != exitPending {
+		return err
+	}
+	line, stateErr := stateLine(ctx, m)
+	if stateErr != nil {
+		return errors.Join(err, stateErr)
+	}
+	fmt.Fprintln(stdout, line)
+	return err
+}
+
+// stateLine returns machine-readable state of database printed in quiet mode.
+func stateLine(ctx context.Context, m *migrate.Migrate) (string, error) {
+	info, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	_, dirty, err := m.DirtyVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("version=%d pending=%d dirty=%t", info.Current.Version, info.Pending, dirty), nil
+}
+
+// checkState returns error with exit code of dirty database or database with pending migrations.
+func checkState(ctx context.Context, m *migrate.Migrate) error {
+	rec, dirty, err := m.DirtyVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: migration %d (%s) failed", migrate.ErrDirty, rec.Version, rec.Description)
+	}
+
+	info, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if info.Behind() {
+		return fmt.Errorf("%w: %d", errPending, info.Pending)
+	}
+	return nil
+}
+
+func (f *migrateFlags) newMigrate(db *mongo.Database, migrations []migrate.Migration, stdout io.Writer) *migrate.Migrate {
+	m := f.db.newMigrate(db, migrations...)
+	if !f.tui && !f.quiet {
+		// log lines would break progress rendering
+		m.SetLogger(logger{w: stdout})
+	}
+	m.SetRunControl(true)
+	m.SetRunLock(f.lock, false)
+	m.SetRunInfo(migrate.RunInfo{Environment: f.env})
+	if f.secretsDir != "" {
+		m.SetSecretResolver(migrate.FileSecrets{Dir: f.secretsDir})
+	} else {
+		m.SetSecretResolver(migrate.EnvSecrets{})
+	}
+	return m
+}
+
+// countArg parses optional number of migrations, "all" means all available ones.
+func countArg(args []string, def int) (int, error) {
+	switch {
+	case len(args) == 0:
+		return def, nil
+	case len(args) > 1:
+		return 0, errors.New("too many arguments")
+	case args[0] == "all":
+		return migrate.AllAvailable, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+	}
+	return n, nil
+}
+
+// versionArg parses required version argument.
+func versionArg(args []string) (uint64, error) {
+	if len(args) != 1 {
+		return 0, errors.New("version argument is required")
+	}
+
+	version, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+	return version, nil
+}
+
+func runUp(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("up", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		n, err := countArg(args, migrate.AllAvailable)
+		if err != nil {
+			return err
+		}
+		if !f.tui {
+			return m.Up(ctx, n)
+		}
+
+		plan, err := m.Plan(ctx, n)
+		if err != nil {
+			return err
+		}
+		return newTUI(stdout, m.Text, plan, f.loaded).run(ctx, func(ctx context.Context) error { return m.Up(ctx, 1) })
+	})
+}
+
+func runDown(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("down", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		n, err := countArg(args, 1)
+		if err != nil {
+			return err
+		}
+		if !f.tui {
+			return m.Down(ctx, n)
+		}
+
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		plan := downPlan(status, n)
+		return newTUI(stdout, m.Text, plan, f.loaded).run(ctx, func(ctx context.Context) error { return m.Down(ctx, 1) })
+	})
+}
+
+func runGoto(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("goto", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		version, err := versionArg(args)
+		if err != nil {
+			return err
+		}
+		return m.MigrateTo(ctx, version)
+	})
+}
+
+func runStatus(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("status", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		control, err := m.RunControl(ctx)
+		if err != nil {
+			return err
+		}
+		hidden, err := m.HiddenIndexes(ctx)
+		if err != nil {
+			return err
+		}
+		views, err := m.Views(ctx)
+		if err != nil {
+			return err
+		}
+		if !f.quiet {
+			printControl(stdout, m.Text, control)
+			if err := printStatus(stdout, status); err != nil {
+				return err
+			}
+			if err := printHiddenIndexes(stdout, m.Text, hidden); err != nil {
+				return err
+			}
+			if err := printViews(stdout, m.Text, views); err != nil {
+				return err
+			}
+		}
+		return checkState(ctx, m)
+	})
+}
+
+func printControl(w io.Writer, text func(migrate.Message) string, control migrate.RunControlRecord) {
+	for _, msg := range control.Messages() {
+		fmt.Fprintln(w, text(msg))
+	}
+}
+
+func printStatus(w io.Writer, status []migrate.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range status {
+		appliedAt := "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.State(), appliedAt, s.Description)
+	}
+	return tw.Flush()
+}
+
+// printHiddenIndexes prints indexes hidden by staged drops, so they are not forgotten before the drop migration.
+func printHiddenIndexes(w io.Writer, text func(migrate.Message) string, hidden []migrate.HiddenIndex) error {
+	if len(hidden) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, text(migrate.NewMessage(migrate.MsgHiddenIndexes)))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTION\tINDEX")
+	for _, index := range hidden {
+		fmt.Fprintf(tw, "%s\t%s\n", index.Collection, index.Name)
+	}
+	return tw.Flush()
+}
+
+// printViews prints when materialized views were rebuilt last time.
+func printViews(w io.Writer, text func(migrate.Message) string, views []migrate.ViewRecord) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, text(migrate.NewMessage(migrate.MsgMaterializedViews)))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VIEW\tSOURCE\tREFRESHED AT\tDURATION\tVERSION")
+	for _, view := range views {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", view.Name, view.Source, view.RefreshedAt.Format("2006-01-02 15:04:05"),
+			view.Duration.Round(time.Millisecond), view.Version)
+	}
+	return tw.Flush()
+}
+
+func runVersion(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("version", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		info, err := m.CurrentVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if !f.quiet {
+			fmt.Fprintln(stdout, m.Text(info.Message()))
+		}
+		return checkState(ctx, m)
+	})
+}
+
+func runValidate(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("validate", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		return m.Validate(ctx)
+	})
+}
+
+func runVerify(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("verify", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		return m.VerifyRestore(ctx)
+	})
+}
+
+func runForce(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("force", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		version, err := versionArg(args)
+		if err != nil {
+			return err
+		}
+		return m.Force(ctx, version)
+	})
+}
+
+func runRepair(args []string, stdout, stderr io.Writer) error {
+	var f migrateFlags
+	return f.open("repair", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate, args []string) error {
+		return m.Repair(ctx)
+	})
+}
+
+// runControl parses database flags and calls fn with migrator. Migrations are not loaded.
+func runControl(name string, args []string, stdout, stderr io.Writer, fn func(ctx context.Context, m *migrate.Migrate) error) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var db dbFlags
+	db.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, database, err := db.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	return fn(ctx, db.newMigrate(database))
+}
+
+func runPause(args []string, stdout, stderr io.Writer) error {
+	return runControl("pause", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate) error {
+		if err := m.PauseRun(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, m.Text(migrate.NewMessage(migrate.MsgPauseSent)))
+		return nil
+	})
+}
+
+func runResume(args []string, stdout, stderr io.Writer) error {
+	return runControl("resume", args, stdout, stderr, func(ctx context.Context, m *migrate.Migrate) error {
+		control, err := m.RunControl(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.ResumeRun(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, m.Text(resumeMessage(control)))
+		return nil
+	})
+}
+
+// resumeMessage reports which run continues after resume requested while control was in provided state.
+func resumeMessage(control migrate.RunControlRecord) migrate.Message {
+	if control.Paused && control.Waiter != "" {
+		return migrate.NewMessage(migrate.MsgResumeSent, control.Waiter, control.WaitingVersion)
+	}
+	return migrate.NewMessage(migrate.MsgResumeSentIdle)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+func TestCountArg(t *testing.T) {
+	if n, err := countArg(nil, 1); err != nil || n != 1 {
+		t.Errorf("Unexpected default count: %v %v", n, err)
+	}
+	if n, err := countArg([]string{"all"}, 1); err != nil || n != migrate.AllAvailable {
+		t.Errorf("Unexpected count: %v %v", n, err)
+	}
+	if n, err := countArg([]string{"3"}, 1); err != nil || n != 3 {
+		t.Errorf("Unexpected count: %v %v", n, err)
+	}
+	for _, args := range [][]string{{"0"}, {"x"}, {"1", "2"}} {
+		if _, err := countArg(args, 1); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+	if _, err := versionArg(nil); err == nil {
+		t.Errorf("Expected error for missing version")
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	var out bytes.Buffer
+	err := printStatus(&out, []migrate.MigrationStatus{
+		{Version: 1, Description: "one", Applied: true, AppliedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Version: 2, Description: "gone", Applied: true, Missing: true},
+		{Version: 3, Description: "three"},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Errorf("Unexpected output: %s", out.String())
+		return
+	}
+	for i, expected := range []string{"applied", "missing", "pending"} {
+		if !strings.Contains(lines[i+1], expected) {
+			t.Errorf("Unexpected line %q, expected state %s", lines[i+1], expected)
+		}
+	}
+}
+
+func TestPrintHiddenIndexes(t *testing.T) {
+	var out bytes.Buffer
+	if err := printHiddenIndexes(&out, migrate.Message.String, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if out.Len() != 0 {
+		t.Errorf("Unexpected output: %s", out.String())
+		return
+	}
+
+	err := printHiddenIndexes(&out, migrate.Message.String, []migrate.HiddenIndex{{Collection: "users", Name: "email_1"}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !strings.Contains(out.String(), "pending drop") || !strings.Contains(out.String(), "users") || !strings.Contains(out.String(), "email_1") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestPrintViews(t *testing.T) {
+	var out bytes.Buffer
+	err := printViews(&out, migrate.Message.String, []migrate.ViewRecord{{
+		Name:        "totals",
+		Source:      "orders",
+		RefreshedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:    1500 * time.Millisecond,
+		Version:     7,
+	}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !strings.Contains(out.String(), "Materialized views:") || !strings.Contains(out.String(), "2024-01-02 03:04:05") ||
+		!strings.Contains(out.String(), "1.5s") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"unknown"}, &stdout, &stderr); code == 0 {
+		t.Errorf("Unexpected exit code %d", code)
+		return
+	}
+	if !strings.HasPrefix(stderr.String(), "unknown command \"unknown\"\nUsage:") {
+		t.Errorf("Unexpected output: %s", stderr.String())
+		return
+	}
+
+	stderr.Reset()
+	run([]string{"unknown", "-codes"}, &stdout, &stderr)
+	if !strings.HasPrefix(stderr.String(), "[unknown-command] unknown command \"unknown\"\n[usage] Usage:") {
+		t.Errorf("Unexpected output: %s", stderr.String())
+	}
+}
+
+func TestPrintControl(t *testing.T) {
+	var out bytes.Buffer
+	printControl(&out, migrate.Message.String, migrate.RunControlRecord{})
+	if out.Len() != 0 {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	printControl(&out, migrate.Message.String, migrate.RunControlRecord{Paused: true, Waiter: "host:1", WaitingVersion: 3})
+	if !strings.Contains(out.String(), "host:1 waits before migration 3") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestPrintControlInterrupted(t *testing.T) {
+	var out bytes.Buffer
+	printControl(&out, migrate.Message.String, migrate.RunControlRecord{InterruptedAt: time.Now(), InterruptedVersion: 4, InterruptedBy: "terminated"})
+	if !strings.Contains(out.String(), "interrupted by terminated") || !strings.Contains(out.String(), "before migration 4") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestResumeMessage(t *testing.T) {
+	msg := resumeMessage(migrate.RunControlRecord{Paused: true, Waiter: "host:1", WaitingVersion: 3})
+	if msg.Code != migrate.MsgResumeSent || msg.String() != "Resume requested, host:1 continues from migration 3" {
+		t.Errorf("Unexpected message: %s", msg)
+		return
+	}
+	if msg := resumeMessage(migrate.RunControlRecord{Paused: true}); msg.Code != migrate.MsgResumeSentIdle {
+		t.Errorf("Unexpected message: %s", msg)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for err, expected := range map[error]int{
+		nil:                                    exitOK,
+		errors.New("other"):                    exitError,
+		fmt.Errorf("w: %w", migrate.ErrDirty):  exitDirty,
+		fmt.Errorf("w: %w", errPending):        exitPending,
+		fmt.Errorf("w: %w", migrate.ErrLocked): exitLocked,
+	} {
+		if code := exitCode(err); code != expected {
+			t.Errorf("Unexpected exit code %d for %v", code, err)
+		}
+	}
+}
+
+func TestWithCode(t *testing.T) {
+	text, ok := withCode(migrate.NewMessage(migrate.MsgForced, uint64(3)))
+	if !ok || text != "[forced] Forced version 3" {
+		t.Errorf("Unexpected text: %s", text)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+const (
+	tuiRefreshInterval = 200 * time.Millisecond
+	tuiBarWidth        = 20
+)
+
+// tuiStep is a migration of interactive run.
+type tuiStep struct {
+	version     uint64
+	description string
+	estimate    time.Duration
+
+	started  bool
+	done     bool
+	duration time.Duration
+	err      error
+}
+
+// tui shows plan, live progress and summary of interactive run. Migrations are performed one by one.
+type tui struct {
+	w     io.Writer
+	text  func(migrate.Message) string
+	steps []tuiStep
+	now   func() time.Time
+}
+
+func newTUI(w io.Writer, text func(migrate.Message) string, plan []migrate.MigrationStatus, migrations []migrate.Migration) *tui {
+	estimates := make(map[uint64]time.Duration, len(migrations))
+	for _, migration := range migrations {
+		estimates[migration.Version] = migration.Estimate
+	}
+
+	steps := make([]tuiStep, 0, len(plan))
+	for _, s := range plan {
+		steps = append(steps, tuiStep{version: s.Version, description: s.Description, estimate: estimates[s.Version]})
+	}
+	return &tui{w: w, text: text, steps: steps, now: time.Now}
+}
+
+// run calls apply for each planned migration. apply must perform exactly one migration.
+func (u *tui) run(ctx context.Context, apply func(ctx context.Context) error) error {
+	if len(u.steps) == 0 {
+		fmt.Fprintln(u.w, u.text(migrate.NewMessage(migrate.MsgNothingToMigrate)))
+		return nil
+	}
+
+	u.printPlan()
+	started := u.now()
+	var err error
+	for i := range u.steps {
+		step := &u.steps[i]
+		step.started = true
+		stepStarted := u.now()
+
+		stop := make(chan struct{})
+		redrawn := make(chan struct{})
+		go func() {
+			defer close(redrawn)
+			ticker := time.NewTicker(tuiRefreshInterval)
+			defer ticker.Stop()
+			for {
+				fmt.Fprint(u.w, "\r\033[K"+u.progressLine(i, u.now().Sub(stepStarted), u.now().Sub(started)))
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+
+		err = apply(ctx)
+		close(stop)
+		<-redrawn
+
+		step.done = true
+		step.duration = u.now().Sub(stepStarted)
+		step.err = err
+		fmt.Fprint(u.w, "\r\033[K"+u.progressLine(i, step.duration, u.now().Sub(started))+"\n")
+		if err != nil {
+			break
+		}
+	}
+
+	u.printSummary(u.now().Sub(started))
+	return err
+}
+
+func (u *tui) printPlan() {
+	fmt.Fprintln(u.w, u.text(migrate.NewMessage(migrate.MsgPlan, len(u.steps))))
+	for _, step := range u.steps {
+		fmt.Fprintf(u.w, "  %d %s\n", step.version, step.description)
+	}
+}
+
+// progressLine renders state of step i: overall progress, progress of step against its estimate and ETA of run.
+func (u *tui) progressLine(i int, stepElapsed, elapsed time.Duration) string {
+	step := u.steps[i]
+	completed := i
+	if step.done {
+		completed++
+	}
+
+	line := fmt.Sprintf("[%s] %d/%d %d %s", progressBar(float64(completed)/float64(len(u.steps))),
+		completed, len(u.steps), step.version, step.description)
+	switch {
+	case step.done && step.err != nil:
+		line += fmt.Sprintf(" failed after %s: %v", round(step.duration), step.err)
+	case step.done:
+		line += fmt.Sprintf(" done in %s", round(step.duration))
+	case step.estimate > 0:
+		line += fmt.Sprintf(" [%s] %s/%s", progressBar(float64(stepElapsed)/float64(step.estimate)),
+			round(stepElapsed), round(step.estimate))
+	default:
+		line += " " + round(stepElapsed).String()
+	}
+
+	line += fmt.Sprintf(", elapsed %s", round(elapsed))
+	if eta, ok := u.eta(i, stepElapsed); ok && !step.done {
+		line += fmt.Sprintf(", ETA %s", round(eta))
+	}
+	return line
+}
+
+// eta estimates remaining duration of run while step i is running for stepElapsed.
+// Migrations without Estimate are expected to take average duration of completed ones.
+func (u *tui) eta(i int, stepElapsed time.Duration) (time.Duration, bool) {
+	var total time.Duration
+	for _, step := range u.steps[:i] {
+		total += step.duration
+	}
+	var average time.Duration
+	if i > 0 {
+		average = total / time.Duration(i)
+	}
+
+	var remaining time.Duration
+	for _, step := range u.steps[i:] {
+		estimate := step.estimate
+		if estimate <= 0 {
+			estimate = average
+		}
+		if estimate <= 0 {
+			return 0, false
+		}
+		remaining += estimate
+	}
+
+	remaining -= stepElapsed
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+func (u *tui) printSummary(elapsed time.Duration) {
+	tw := tabwriter.NewWriter(u.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, u.text(migrate.NewMessage(migrate.MsgSummaryHeader)))
+	for _, step := range u.steps {
+		msg := migrate.NewMessage(migrate.MsgStepSkipped, step.version, step.description)
+		switch {
+		case step.done && step.err != nil:
+			msg = migrate.NewMessage(migrate.MsgStepFailed, step.version, round(step.duration), step.description)
+		case step.done:
+			msg = migrate.NewMessage(migrate.MsgStepDone, step.version, round(step.duration), step.description)
+		}
+		fmt.Fprintln(tw, u.text(msg))
+	}
+	tw.Flush()
+	fmt.Fprintln(u.w, u.text(migrate.NewMessage(migrate.MsgTotal, round(elapsed))))
+}
+
+func progressBar(fraction float64) string {
+	switch {
+	case fraction < 0:
+		fraction = 0
+	case fraction > 1:
+		fraction = 1
+	}
+
+	filled := int(fraction * tuiBarWidth)
+	return strings.Repeat("#", filled) + strings.Repeat("-", tuiBarWidth-filled)
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
+
+// downPlan returns applied registered migrations which would be reverted by Down(ctx, n), newest first.
+func downPlan(status []migrate.MigrationStatus, n int) []migrate.MigrationStatus {
+	var plan []migrate.MigrationStatus
+	for i := len(status) - 1; i >= 0 && (n <= 0 || len(plan) < n); i-- {
+		if status[i].Applied && !status[i].Missing {
+			plan = append(plan, status[i])
+		}
+	}
+	return plan
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+func TestTUIRun(t *testing.T) {
+	var out bytes.Buffer
+	plan := []migrate.MigrationStatus{{Version: 1, Description: "one"}, {Version: 2, Description: "two"}, {Version: 3, Description: "three"}}
+	u := newTUI(&out, migrate.Message.String, plan, []migrate.Migration{{Version: 2, Estimate: time.Minute}})
+
+	calls := 0
+	errFailed := errors.New("failed")
+	err := u.run(context.Background(), func(context.Context) error {
+		calls++
+		if calls == 2 {
+			return errFailed
+		}
+		return nil
+	})
+	if !errors.Is(err, errFailed) || calls != 2 {
+		t.Errorf("Unexpected result: %v, %d calls", err, calls)
+		return
+	}
+
+	for _, expected := range []string{"Plan: 3 migrations", "1/3 1 one done", "2/3 2 two failed", "skipped  -         three"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Output does not contain %q: %s", expected, out.String())
+		}
+	}
+}
+
+func TestTUICodes(t *testing.T) {
+	var out bytes.Buffer
+	if err := newTUI(&out, messageText(true), nil, nil).run(context.Background(), nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if out.String() != "[nothing-to-migrate] Nothing to migrate\n" {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
+
+func TestTUIETA(t *testing.T) {
+	u := &tui{steps: []tuiStep{
+		{duration: 2 * time.Second, done: true},
+		{estimate: 10 * time.Second},
+		{},
+	}}
+	if eta, ok := u.eta(1, 3*time.Second); !ok || eta != 9*time.Second {
+		t.Errorf("Unexpected ETA: %v %v", eta, ok)
+	}
+	if _, ok := (&tui{steps: []tuiStep{{}}}).eta(0, 0); ok {
+		t.Errorf("Unexpected ETA without estimates")
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	if bar := progressBar(0.5); bar != "##########----------" {
+		t.Errorf("Unexpected bar: %s", bar)
+	}
+	if bar := progressBar(2); bar != strings.Repeat("#", tuiBarWidth) {
+		t.Errorf("Unexpected bar: %s", bar)
+	}
+}
+
+func TestDownPlan(t *testing.T) {
+	status := []migrate.MigrationStatus{
+		{Version: 1, Applied: true},
+		{Version: 2, Applied: true, Missing: true},
+		{Version: 3, Applied: true},
+		{Version: 4},
+	}
+	plan := downPlan(status, 2)
+	if len(plan) != 2 || plan[0].Version != 3 || plan[1].Version != 1 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+}
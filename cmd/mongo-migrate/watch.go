@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	migrate "github.com/xakep666/mongo-migrate"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func runWatch(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var db dbFlags
+	db.register(flags)
+	dir := flags.String("dir", "migrations", "directory with migration files")
+	interval := flags.Duration("interval", time.Second, "interval between directory checks")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, database, err := db.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	w := &watcher{
+		db:    database,
+		flags: db,
+		fsys:  os.DirFS(*dir),
+		log:   logger{w: stdout},
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		w.sync(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watcher applies new and changed migration files to development database.
+type watcher struct {
+	db    *mongo.Database
+	flags dbFlags
+	fsys  fs.FS
+	log   migrate.Logger
+
+	hashes     map[string][sha256.Size]byte
+	migrations []migrate.Migration
+}
+
+func (w *watcher) newMigrate(migrations []migrate.Migration) *migrate.Migrate {
+	m := w.flags.newMigrate(w.db, migrations...)
+	m.SetLogger(w.log)
+	return m
+}
+
+// report prints message of watcher using logger.
+func (w *watcher) report(code migrate.MessageCode, args ...any) {
+	w.log.Printf("%s", w.flags.text(migrate.NewMessage(code, args...)))
+}
+
+func (w *watcher) sync(ctx context.Context) {
+	hashes, err := hashFiles(w.fsys)
+	if err != nil {
+		w.report(migrate.MsgWatchReadFailed, err)
+		return
+	}
+	if w.hashes != nil && equalHashes(w.hashes, hashes) {
+		return
+	}
+
+	changed := changedFiles(w.hashes, hashes)
+	// remember state anyway to not retry broken files until they are changed again
+	w.hashes = hashes
+
+	migrations, err := migrate.NewFileMigrations(w.fsys, ".")
+	if err != nil {
+		w.report(migrate.MsgWatchLoadFailed, err)
+		return
+	}
+
+	if err := w.revertChanged(ctx, changed); err != nil {
+		w.report(migrate.MsgWatchRevertFailed, err)
+		return
+	}
+	w.migrations = migrations
+
+	if err := w.newMigrate(migrations).Up(ctx, migrate.AllAvailable); err != nil {
+		w.report(migrate.MsgWatchApplyFailed, err)
+	}
+}
+
+// revertChanged reverts applied migrations starting from the oldest changed one using their previous definitions,
+// so changed files are re-applied by the next "up".
+func (w *watcher) revertChanged(ctx context.Context, changed []string) error {
+	var oldest uint64
+	for _, name := range changed {
+		version, ok := fileVersion(name)
+		if ok && (oldest == 0 || version < oldest) {
+			oldest = version
+		}
+	}
+	if oldest == 0 {
+		return nil
+	}
+
+	m := w.newMigrate(w.migrations)
+	current, _, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if oldest > current {
+		return nil
+	}
+
+	var target uint64
+	for _, migration := range w.migrations {
+		if migration.Version < oldest && migration.Version > target {
+			target = migration.Version
+		}
+	}
+
+	w.report(migrate.MsgWatchReverting, oldest, target)
+	return m.DownTo(ctx, target, migrate.DownToOptions{})
+}
+
+func hashFiles(fsys fs.FS) (map[string][sha256.Size]byte, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string][sha256.Size]byte, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		hashes[entry.Name()] = sha256.Sum256(data)
+	}
+	return hashes, nil
+}
+
+func equalHashes(a, b map[string][sha256.Size]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, hash := range a {
+		if other, ok := b[name]; !ok || other != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// changedFiles returns names of files which were changed or removed. New files are not included.
+func changedFiles(prev, next map[string][sha256.Size]byte) []string {
+	var changed []string
+	for name, hash := range prev {
+		if other, ok := next[name]; !ok || other != hash {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func fileVersion(name string) (uint64, bool) {
+	idx := strings.IndexByte(name, '_')
+	if idx <= 0 {
+		return 0, false
+	}
+
+	version, err := strconv.ParseUint(name[:idx], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChangedFiles(t *testing.T) {
+	prev := map[string][sha256.Size]byte{
+		"1_a.up.json": sha256.Sum256([]byte("a")),
+		"2_b.up.json": sha256.Sum256([]byte("b")),
+		"3_c.up.json": sha256.Sum256([]byte("c")),
+	}
+	next := map[string][sha256.Size]byte{
+		"1_a.up.json": sha256.Sum256([]byte("a")),
+		"2_b.up.json": sha256.Sum256([]byte("changed")),
+		"4_d.up.json": sha256.Sum256([]byte("d")),
+	}
+	changed := changedFiles(prev, next)
+	if len(changed) != 2 || changed[0] != "2_b.up.json" || changed[1] != "3_c.up.json" {
+		t.Errorf("Unexpected changed files: %v", changed)
+	}
+	if equalHashes(prev, next) {
+		t.Errorf("Unexpectedly equal hashes")
+	}
+	if !equalHashes(prev, prev) {
+		t.Errorf("Unexpectedly non-equal hashes")
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_a.up.json": {Data: []byte(`{"create": "a"}`)},
+		"notes.txt":   {Data: []byte("ignored")},
+	}
+	hashes, err := hashFiles(fsys)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(hashes) != 1 {
+		t.Errorf("Unexpected hashes: %v", hashes)
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	if version, ok := fileVersion("12_add_index.up.json"); !ok || version != 12 {
+		t.Errorf("Unexpected version: %v %v", version, ok)
+	}
+	if _, ok := fileVersion("add_index.up.json"); ok {
+		t.Errorf("Unexpectedly parsed version")
+	}
+}
@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// SetBSONRegistry sets codec registry used for documents of migrations: database passed to callbacks
+// is configured with it, typed helpers (TypedTransform, MarshalDocument, UnmarshalDocument) honor it.
+// By default, driver registry is used.
+func (m *Migrate) SetBSONRegistry(registry *bsoncodec.Registry) {
+	m.bsonRegistry = registry
+}
+
+// SetBSONOptions sets BSON marshaling and unmarshaling behavior in the same way as SetBSONRegistry.
+func (m *Migrate) SetBSONOptions(opts *options.BSONOptions) {
+	m.bsonOptions = opts
+}
+
+// migrationDB returns database handle passed to migration callbacks. Non-nil readPref overrides read preference of database.
+func (m *Migrate) migrationDB(readPref *readpref.ReadPref) *mongo.Database {
+	if m.bsonRegistry == nil && m.bsonOptions == nil && readPref == nil {
+		return m.db
+	}
+
+	opts := options.Database().SetRegistry(m.bsonRegistry).SetBSONOptions(m.bsonOptions)
+	if readPref != nil {
+		opts.SetReadPreference(readPref)
+	}
+	return m.db.Client().Database(m.db.Name(), opts)
+}
+
+func codecFromContext(ctx context.Context) (*bsoncodec.Registry, *options.BSONOptions) {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	return state.migrate.bsonRegistry, state.migrate.bsonOptions
+}
+
+// MarshalDocument marshals v using codec settings of migrator which performs current migration.
+func MarshalDocument(ctx context.Context, v interface{}) (bson.Raw, error) {
+	registry, opts := codecFromContext(ctx)
+
+	var buf bytes.Buffer
+	vw, err := bsonrw.NewBSONValueWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := bson.NewEncoder(vw)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		if opts.ErrorOnInlineDuplicates {
+			enc.ErrorOnInlineDuplicates()
+		}
+		if opts.IntMinSize {
+			enc.IntMinSize()
+		}
+		if opts.NilByteSliceAsEmpty {
+			enc.NilByteSliceAsEmpty()
+		}
+		if opts.NilMapAsEmpty {
+			enc.NilMapAsEmpty()
+		}
+		if opts.NilSliceAsEmpty {
+			enc.NilSliceAsEmpty()
+		}
+		if opts.OmitZeroStruct {
+			enc.OmitZeroStruct()
+		}
+		if opts.StringifyMapKeysWithFmt {
+			enc.StringifyMapKeysWithFmt()
+		}
+		if opts.UseJSONStructTags {
+			enc.UseJSONStructTags()
+		}
+	}
+	if registry != nil {
+		if err := enc.SetRegistry(registry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalDocument unmarshals doc into v using codec settings of migrator which performs current migration.
+func UnmarshalDocument(ctx context.Context, doc bson.Raw, v interface{}) error {
+	registry, opts := codecFromContext(ctx)
+
+	dec, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(doc))
+	if err != nil {
+		return err
+	}
+	if opts != nil {
+		if opts.AllowTruncatingDoubles {
+			dec.AllowTruncatingDoubles()
+		}
+		if opts.BinaryAsSlice {
+			dec.BinaryAsSlice()
+		}
+		if opts.DefaultDocumentD {
+			dec.DefaultDocumentD()
+		}
+		if opts.DefaultDocumentM {
+			dec.DefaultDocumentM()
+		}
+		if opts.UseJSONStructTags {
+			dec.UseJSONStructTags()
+		}
+		if opts.UseLocalTimeZone {
+			dec.UseLocalTimeZone()
+		}
+		if opts.ZeroMaps {
+			dec.ZeroMaps()
+		}
+		if opts.ZeroStructs {
+			dec.ZeroStructs()
+		}
+	}
+	if registry != nil {
+		if err := dec.SetRegistry(registry); err != nil {
+			return err
+		}
+	}
+
+	return dec.Decode(v)
+}
+
+// TypedTransform adapts callback operating on decoded documents to TransformFunc.
+// Documents are decoded using codec settings of migrator which performs current migration.
+func TypedTransform[T any](ctx context.Context, fn func(doc *T) (replacement interface{}, err error)) TransformFunc {
+	return func(raw bson.Raw) (interface{}, error) {
+		var doc T
+		if err := UnmarshalDocument(ctx, raw, &doc); err != nil {
+			return nil, err
+		}
+
+		return fn(&doc)
+	}
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testCelsius float64
+
+func TestMarshalDocumentOptions(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetBSONOptions(&options.BSONOptions{IntMinSize: true})
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+
+	raw, err := MarshalDocument(ctx, bson.M{"n": int64(1)})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if raw.Lookup("n").Type != bsontype.Int32 {
+		t.Errorf("Unexpected document: %s", raw)
+	}
+
+	raw, err = MarshalDocument(context.Background(), bson.M{"n": int64(1)})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if raw.Lookup("n").Type != bsontype.Int64 {
+		t.Errorf("Unexpected document: %s", raw)
+	}
+}
+
+func TestTypedTransformRegistry(t *testing.T) {
+	// celsius values are stored as strings by application codec
+	registry := bson.NewRegistry()
+	registry.RegisterTypeDecoder(reflect.TypeOf(testCelsius(0)), bsoncodec.ValueDecoderFunc(
+		func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+			s, err := vr.ReadString()
+			if err != nil {
+				return err
+			}
+			if s != "36.6C" {
+				t.Errorf("Unexpected value: %s", s)
+			}
+			val.SetFloat(36.6)
+			return nil
+		}))
+
+	m := NewMigrate(nil)
+	m.SetBSONRegistry(registry)
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+
+	doc, err := bson.Marshal(bson.D{{Key: "temperature", Value: "36.6C"}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	type reading struct {
+		Temperature testCelsius `bson:"temperature"`
+	}
+	fn := TypedTransform(ctx, func(doc *reading) (interface{}, error) {
+		if doc.Temperature != 36.6 {
+			t.Errorf("Unexpected decoded document: %+v", doc)
+		}
+		return nil, nil
+	})
+	if _, err := fn(doc); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
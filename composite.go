@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Seed fills database with data of environment, e.g. fixtures of development database or default tenant.
+// Seeds are applied by every ApplyAll call, so Apply must be idempotent (e.g. use upserts).
+type Seed struct {
+	Name string
+
+	// Environments are names of environments (see RunInfo.Environment) where seed is applied.
+	// Empty list means all environments.
+	Environments []string
+
+	Apply MigrationFunc
+}
+
+// CompositePlan describes work which would be performed by ApplyAll in order of execution.
+type CompositePlan struct {
+	// Migrations are versioned migrations which would be applied, see Plan.
+	Migrations []MigrationStatus
+
+	// Views are names of materialized views which are due for refresh, see SetMaterializedViews.
+	Views []string
+
+	// Seeds are names of seeds of current environment, see SetSeeds.
+	Seeds []string
+}
+
+// SetSeeds sets seeds applied by ApplyAll after versioned migrations and materialized views.
+func (m *Migrate) SetSeeds(seeds ...Seed) {
+	m.seeds = seeds
+}
+
+// PlanAll returns work which would be performed by ApplyAll.
+func (m *Migrate) PlanAll(ctx context.Context) (CompositePlan, error) {
+	migrations, err := m.Plan(ctx, AllAvailable)
+	if err != nil {
+		return CompositePlan{}, err
+	}
+
+	plan := CompositePlan{Migrations: migrations}
+	for _, view := range m.views {
+		due, err := m.viewDue(ctx, view)
+		if err != nil {
+			return CompositePlan{}, err
+		}
+		if due {
+			plan.Views = append(plan.Views, view.Name)
+		}
+	}
+	for _, seed := range m.environmentSeeds() {
+		plan.Seeds = append(plan.Seeds, seed.Name)
+	}
+
+	return plan, nil
+}
+
+// ApplyAll performs all pending versioned migrations, refreshes due materialized views and applies seeds
+// of current environment in this order. All of them are performed under one run lock and recorded
+// as one run, so application startup needs a single call. The first failure stops the rest.
+func (m *Migrate) ApplyAll(ctx context.Context) (err error) {
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "apply-all"})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+
+	if err := m.upWithFailover(ctx, AllAvailable, false); err != nil {
+		return err
+	}
+	if err := m.RefreshViews(ctx); err != nil {
+		return err
+	}
+	for _, seed := range m.environmentSeeds() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := seed.Apply(ctx, m.db); err != nil {
+			return fmt.Errorf("migrate: apply seed %q: %w", seed.Name, err)
+		}
+		m.report(MsgSeedApplied, seed.Name)
+	}
+
+	return nil
+}
+
+// environmentSeeds returns seeds applied in environment set by SetRunInfo.
+func (m *Migrate) environmentSeeds() []Seed {
+	var seeds []Seed
+	for _, seed := range m.seeds {
+		if len(seed.Environments) == 0 {
+			seeds = append(seeds, seed)
+			continue
+		}
+		for _, environment := range seed.Environments {
+			if strings.EqualFold(environment, m.runInfo.Environment) {
+				seeds = append(seeds, seed)
+				break
+			}
+		}
+	}
+
+	return seeds
+}
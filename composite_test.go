@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestEnvironmentSeeds(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetSeeds(
+		Seed{Name: "default-tenant"},
+		Seed{Name: "fixtures", Environments: []string{"dev", "test"}},
+		Seed{Name: "prod-settings", Environments: []string{"prod"}},
+	)
+	m.SetRunInfo(RunInfo{Environment: "Dev"})
+
+	seeds := m.environmentSeeds()
+	if len(seeds) != 2 || seeds[0].Name != "default-tenant" || seeds[1].Name != "fixtures" {
+		t.Errorf("Unexpected seeds: %+v", seeds)
+	}
+}
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type direction string
+
+const (
+	directionUp   direction = "up"
+	directionDown direction = "down"
+)
+
+type runContextKey struct{}
+
+// runState describes migration which is currently performed. It is available for migration callbacks via context.
+type runState struct {
+	migrate   *Migrate
+	version   uint64
+	direction direction
+
+	// before is a cluster time captured before migration started, it is set only for Verify step.
+	before primitive.Timestamp
+
+	// dryRun collects commands issued via RunCommand instead of execution.
+	dryRun *[]bson.D
+}
+
+// RunInfo describes environment of migration run. Migrations get it using RunInfoFromContext.
+type RunInfo struct {
+	// Environment is a name of environment, e.g. "prod" or "staging".
+	Environment string
+
+	// Tenant identifies tenant which database is migrated.
+	Tenant string
+
+	// Flags contains feature flags enabled for the run.
+	Flags map[string]bool
+
+	// Version is a version of currently performed migration and Down reports if it is reverted.
+	// Set by migrator.
+	Version uint64
+	Down    bool
+
+	// Logger is a logger of migrator, it is never nil. Set by migrator.
+	Logger Logger
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...any) {}
+
+// SetRunInfo sets environment description passed to migrations via context.
+func (m *Migrate) SetRunInfo(info RunInfo) {
+	m.runInfo = info
+}
+
+// SetContextValues sets values which are added to context passed to migrations,
+// so they may be retrieved using ctx.Value(key).
+func (m *Migrate) SetContextValues(values map[any]any) {
+	m.contextValues = values
+}
+
+// RunInfoFromContext returns environment description of the run which performs current migration.
+func RunInfoFromContext(ctx context.Context) (RunInfo, bool) {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return RunInfo{}, false
+	}
+
+	info := state.migrate.runInfo
+	info.Version = state.version
+	info.Down = state.direction == directionDown
+	info.Logger = state.migrate.log
+	if info.Logger == nil {
+		info.Logger = nopLogger{}
+	}
+	return info, true
+}
+
+func (m *Migrate) runContext(ctx context.Context, migration Migration, dir direction) context.Context {
+	for key, value := range m.contextValues {
+		ctx = context.WithValue(ctx, key, value)
+	}
+
+	return context.WithValue(ctx, runContextKey{}, &runState{
+		migrate:   m,
+		version:   migration.Version,
+		direction: dir,
+	})
+}
+
+func runFromContext(ctx context.Context) (*runState, bool) {
+	state, ok := ctx.Value(runContextKey{}).(*runState)
+	return state, ok
+}
@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+type testContextKey struct{}
+
+func TestRunInfoFromContext(t *testing.T) {
+	if _, ok := RunInfoFromContext(context.Background()); ok {
+		t.Errorf("Unexpectedly found run info outside of migration")
+	}
+
+	m := NewMigrate(nil)
+	m.SetRunInfo(RunInfo{Environment: "staging", Tenant: "acme", Flags: map[string]bool{"new-schema": true}})
+	m.SetContextValues(map[any]any{testContextKey{}: "value"})
+	ctx := m.runContext(context.Background(), Migration{Version: 3}, directionDown)
+
+	info, ok := RunInfoFromContext(ctx)
+	if !ok {
+		t.Errorf("Unexpectedly not found run info")
+		return
+	}
+	if info.Environment != "staging" || info.Tenant != "acme" || !info.Flags["new-schema"] || info.Version != 3 || !info.Down {
+		t.Errorf("Unexpected run info: %+v", info)
+	}
+	if info.Logger == nil {
+		t.Errorf("Unexpected nil logger")
+	}
+	if v, _ := ctx.Value(testContextKey{}).(string); v != "value" {
+		t.Errorf("Unexpected context value: %v", v)
+	}
+}
@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// controlRecordID is an id of run control document. It is stored in locks collection
+// because both coordinate processes sharing the database.
+const controlRecordID = "control"
+
+// RunControlRecord is a state of run control document, see SetRunControl.
+type RunControlRecord struct {
+	// Paused reports that pause was requested at RequestedAt.
+	Paused      bool      `bson:"paused"`
+	RequestedAt time.Time `bson:"requested_at,omitempty"`
+
+	// Waiter identifies process which is paused before migration of WaitingVersion since WaitingSince.
+	// It is empty if none of runs reached boundary yet.
+	Waiter         string    `bson:"waiter,omitempty"`
+	WaitingVersion uint64    `bson:"waiting_version,omitempty"`
+	WaitingSince   time.Time `bson:"waiting_since,omitempty"`
+
+	// InterruptedAt is a time of the last run stop by signal before migration of InterruptedVersion,
+	// InterruptedBy is a name of signal. See NotifyContext.
+	InterruptedAt      time.Time `bson:"interrupted_at,omitempty"`
+	InterruptedVersion uint64    `bson:"interrupted_version,omitempty"`
+	InterruptedBy      string    `bson:"interrupted_by,omitempty"`
+}
+
+// SetRunControl enables checking run control document before each migration. When pause is requested
+// (see PauseRun), run waits before the next migration until ResumeRun is called or context is canceled.
+// Migration which is in progress is not interrupted, so run is paused only at safe boundary.
+func (m *Migrate) SetRunControl(enabled bool) {
+	m.runControl = enabled
+}
+
+// PauseRun requests pause of runs with enabled run control. It is persistent: run started while pause is requested
+// waits before its first migration.
+func (m *Migrate) PauseRun(ctx context.Context) error {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "paused", Value: true},
+		{Key: "requested_at", Value: time.Now().UTC()},
+	}}}
+	if err := m.updateControl(ctx, update); err != nil {
+		return fmt.Errorf("migrate: pause run: %w", err)
+	}
+
+	return nil
+}
+
+// ResumeRun cancels pause request, so paused runs continue.
+func (m *Migrate) ResumeRun(ctx context.Context) error {
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "paused", Value: false}}},
+		{Key: "$unset", Value: bson.D{{Key: "requested_at", Value: ""}}},
+	}
+	if err := m.updateControl(ctx, update); err != nil {
+		return fmt.Errorf("migrate: resume run: %w", err)
+	}
+
+	return nil
+}
+
+// RunControl returns current state of run control document.
+func (m *Migrate) RunControl(ctx context.Context) (RunControlRecord, error) {
+	var rec RunControlRecord
+	err := m.db.Collection(m.locksCollection).FindOne(ctx, bson.D{{Key: "_id", Value: controlRecordID}}).Decode(&rec)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return RunControlRecord{}, nil
+	case err != nil:
+		return RunControlRecord{}, fmt.Errorf("migrate: read run control: %w", err)
+	}
+
+	return rec, nil
+}
+
+// Messages describe interruption of the last run and requested pause, they are empty if neither happened.
+func (rec RunControlRecord) Messages() []Message {
+	var msgs []Message
+	if !rec.InterruptedAt.IsZero() {
+		msgs = append(msgs, NewMessage(MsgRunInterrupted, rec.InterruptedBy, rec.InterruptedAt, rec.InterruptedVersion))
+	}
+	switch {
+	case !rec.Paused:
+	case rec.Waiter != "":
+		msgs = append(msgs, NewMessage(MsgPauseWaiting, rec.RequestedAt, rec.Waiter, rec.WaitingVersion))
+	default:
+		msgs = append(msgs, NewMessage(MsgPauseRequested, rec.RequestedAt))
+	}
+
+	return msgs
+}
+
+func (m *Migrate) updateControl(ctx context.Context, update bson.D) error {
+	filter := bson.D{{Key: "_id", Value: controlRecordID}}
+	_, err := m.db.Collection(m.locksCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// awaitResume blocks before migration of provided version while pause is requested.
+func (m *Migrate) awaitResume(ctx context.Context, version uint64) error {
+	if !m.runControl {
+		return nil
+	}
+
+	waiting := false
+	for {
+		rec, err := m.RunControl(ctx)
+		if err != nil {
+			return err
+		}
+		if !rec.Paused {
+			if waiting {
+				m.report(MsgRunResumed, version)
+				return m.clearWaiter(ctx)
+			}
+			return nil
+		}
+
+		if !waiting {
+			update := bson.D{{Key: "$set", Value: bson.D{
+				{Key: "waiter", Value: m.lockOwner},
+				{Key: "waiting_version", Value: version},
+				{Key: "waiting_since", Value: time.Now().UTC()},
+			}}}
+			if err := m.updateControl(ctx, update); err != nil {
+				return fmt.Errorf("migrate: update run control: %w", err)
+			}
+			m.report(MsgRunPaused, version)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := m.clearWaiter(context.Background()); err != nil {
+				m.report(MsgControlFailed, err)
+			}
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (m *Migrate) clearWaiter(ctx context.Context) error {
+	filter := bson.D{{Key: "_id", Value: controlRecordID}, {Key: "waiter", Value: m.lockOwner}}
+	update := bson.D{{Key: "$unset", Value: bson.D{
+		{Key: "waiter", Value: ""},
+		{Key: "waiting_version", Value: ""},
+		{Key: "waiting_since", Value: ""},
+	}}}
+	if _, err := m.db.Collection(m.locksCollection).UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("migrate: update run control: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultDeleteBatchSize = 1000
+
+// PacedDeleteOptions configures PacedDelete.
+type PacedDeleteOptions struct {
+	// BatchSize is a number of documents removed by one command. Default is 1000.
+	BatchSize int
+
+	// Pause is a delay between batches to leave capacity for regular load and let secondaries catch up.
+	Pause time.Duration
+
+	// Progress is called after each batch with total number of deleted documents.
+	Progress func(deleted int64)
+
+	// Checkpoint is a name of checkpoint storing progress, so deletion interrupted in migration
+	// resumes after the last deleted document. Default is "paced-delete:<collection>".
+	Checkpoint string
+}
+
+// pacedDeleteProgress is a value of PacedDelete checkpoint.
+type pacedDeleteProgress struct {
+	LastID  bson.RawValue `bson:"last_id"`
+	Deleted int64         `bson:"deleted"`
+}
+
+// PacedDelete removes documents of coll matching filter in batches ordered by "_id" with pauses between them
+// instead of single "deleteMany" which may stall cluster on huge collections. It returns total number of deleted documents.
+// Inside migration progress is stored in checkpoint, so run resumed after failure (see ResumeDirty)
+// continues from the last batch.
+func PacedDelete(ctx context.Context, coll *mongo.Collection, filter interface{}, opts PacedDeleteOptions) (int64, error) {
+	if err := checkNamespace(ctx, coll.Name()); err != nil {
+		return 0, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultDeleteBatchSize
+	}
+	if opts.Checkpoint == "" {
+		opts.Checkpoint = "paced-delete:" + coll.Name()
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	state, inRun := runFromContext(ctx)
+	var progress pacedDeleteProgress
+	if inRun {
+		rec, found, err := state.migrate.loadCheckpoint(ctx, state, opts.Checkpoint)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			if err := rec.Value.Unmarshal(&progress); err != nil {
+				return 0, fmt.Errorf("migrate: decode checkpoint %q: %w", opts.Checkpoint, err)
+			}
+		}
+	}
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(opts.BatchSize))
+	for {
+		batchFilter := filter
+		if progress.LastID.Type != 0 {
+			batchFilter = bson.D{{Key: "$and", Value: bson.A{
+				filter,
+				bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: progress.LastID}}}},
+			}}}
+		}
+
+		cursor, err := coll.Find(ctx, batchFilter, findOpts)
+		if err != nil {
+			return progress.Deleted, err
+		}
+		var docs []struct {
+			ID bson.RawValue `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return progress.Deleted, err
+		}
+		if len(docs) == 0 {
+			return progress.Deleted, nil
+		}
+
+		ids := make(bson.A, 0, len(docs))
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		// documents changed since they were found may not match filter anymore
+		deleteFilter := bson.D{{Key: "$and", Value: bson.A{
+			filter,
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}},
+		}}}
+		res, err := coll.DeleteMany(ctx, deleteFilter)
+		if err != nil {
+			return progress.Deleted, fmt.Errorf("migrate: delete batch of %q: %w", coll.Name(), err)
+		}
+		progress.Deleted += res.DeletedCount
+		progress.LastID = docs[len(docs)-1].ID
+
+		if inRun {
+			if err := state.migrate.injectFault(FaultMidBatch, state.version); err != nil {
+				return progress.Deleted, err
+			}
+			if err := state.migrate.saveCheckpoint(ctx, state, opts.Checkpoint, progress); err != nil {
+				return progress.Deleted, err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress.Deleted)
+		}
+		if len(docs) < opts.BatchSize {
+			return progress.Deleted, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return progress.Deleted, ctx.Err()
+		case <-time.After(opts.Pause):
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPacedDeleteFiltered(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Include: []string{"sessions"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+	// client connects lazily, so no server is needed
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	coll := client.Database("test").Collection("users")
+	if _, err := PacedDelete(ctx, coll, nil, PacedDeleteOptions{}); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrDirty returned when the last migration failed halfway, so database is in undefined state.
+// Fix database manually and call Force or Repair to continue, or call ResumeDirty if migration stored checkpoints.
+var ErrDirty = errors.New("migrate: database is dirty")
+
+// cleanFilter matches version records of completed migrations.
+var cleanFilter = bson.D{{Key: "dirty", Value: bson.D{{Key: "$ne", Value: true}}}}
+
+// currentFilter matches version records which may define current version.
+var currentFilter = append(bson.D{{Key: "out_of_order", Value: bson.D{{Key: "$ne", Value: true}}}}, cleanFilter...)
+
+// DirtyVersion returns record of migration which failed halfway. Found is false if database is clean.
+// Migration is marked dirty before it starts and the mark is cleared when it completes,
+// the mark is not used when transactions are enabled because failed migration is rolled back.
+func (m *Migrate) DirtyVersion(ctx context.Context) (rec VersionRecord, found bool, err error) {
+	last, found, err := m.versions().Last(ctx)
+	if err != nil || !found || !last.Dirty {
+		return VersionRecord{}, false, err
+	}
+
+	return last, true, nil
+}
+
+// checkDirty returns ErrDirty if database is dirty.
+func (m *Migrate) checkDirty(ctx context.Context) error {
+	rec, dirty, err := m.DirtyVersion(ctx)
+	if err != nil || !dirty {
+		return err
+	}
+
+	return fmt.Errorf("%w: migration %d (%s) failed", ErrDirty, rec.Version, rec.Description)
+}
+
+// checkResumable returns ErrDirty if database is dirty and failed migration stored no checkpoints,
+// so it can't be continued.
+func (m *Migrate) checkResumable(ctx context.Context) error {
+	rec, dirty, err := m.DirtyVersion(ctx)
+	if err != nil || !dirty {
+		return err
+	}
+
+	count, err := m.db.Collection(m.checkpointsCollection).CountDocuments(ctx, bson.D{{Key: "version", Value: rec.Version}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: migration %d (%s) failed and has no checkpoints", ErrDirty, rec.Version, rec.Description)
+	}
+
+	return nil
+}
+
+// ResumeDirty performs "up" migrations like Up, but dirty migration which stored checkpoints
+// (see Savepoint, Batch and PacedDelete) is continued from them instead of refusing the run.
+// Call it once the cause of failure is fixed. ErrDirty is returned if failed migration stored no checkpoints,
+// such migration is recovered by Force or Repair.
+func (m *Migrate) ResumeDirty(ctx context.Context, n int) (err error) {
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "resume-dirty", N: n})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+	if err := m.UpgradeSchema(ctx); err != nil {
+		return err
+	}
+	if err := m.checkResumable(ctx); err != nil {
+		return err
+	}
+	if err := m.upWithFailover(ctx, n, true); err != nil {
+		return err
+	}
+	return m.RefreshViews(ctx)
+}
+
+// markDirty records that migration of provided version starts. It returns true if dirty record
+// was written, so commitVersion replaces it. Dirty mark is not used when transactions are enabled.
+// Dirty record of resumed migration is reused.
+func (m *Migrate) markDirty(ctx context.Context, migration Migration) (bool, error) {
+	if m.transactions {
+		return false, nil
+	}
+
+	last, found, err := m.versions().Last(ctx)
+	if err != nil {
+		return false, err
+	}
+	if found && last.Dirty && last.Version == migration.Version {
+		return true, nil
+	}
+
+	rec := VersionRecord{
+		Version:     migration.Version,
+		Description: migration.Description,
+		Timestamp:   time.Now().UTC(),
+		Checksum:    migration.Checksum,
+		Dirty:       true,
+	}
+	if err := m.versions().Append(ctx, rec); err != nil {
+		return false, fmt.Errorf("migrate: mark version %d dirty: %w", migration.Version, err)
+	}
+
+	return true, nil
+}
+
+// commitVersion writes version record of completed migration replacing dirty record if it was written.
+func (m *Migrate) commitVersion(ctx context.Context, dirty bool, rec VersionRecord) error {
+	if !dirty {
+		return m.versions().Append(ctx, rec)
+	}
+
+	return m.versions().ReplaceLast(ctx, rec)
+}
+
+// Force acknowledges manual fix of database and records provided version as current one without running migrations.
+// Version must be 0 or one of registered versions. Checkpoints of dirty migration are removed.
+func (m *Migrate) Force(ctx context.Context, version uint64) error {
+	migration := Migration{Version: version}
+	if version != 0 {
+		var ok bool
+		if migration, ok = findMigration(m.migrations, version); !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	if err := m.clearDirtyCheckpoints(ctx); err != nil {
+		return err
+	}
+	if err := m.setVersion(ctx, Migration{Version: version, Description: migration.Description, Checksum: migration.Checksum}); err != nil {
+		return fmt.Errorf("migrate: force version %d: %w", version, err)
+	}
+
+	m.report(MsgForced, version)
+	return nil
+}
+
+// Repair acknowledges that changes of failed migration were reverted manually: dirty mark and checkpoints
+// of migration are removed, so database stays at the version before it and the next run starts it from scratch.
+func (m *Migrate) Repair(ctx context.Context) error {
+	last, found, err := m.versions().Last(ctx)
+	if err != nil || !found || !last.Dirty {
+		return err
+	}
+
+	if err := m.clearDirtyCheckpoints(ctx); err != nil {
+		return err
+	}
+	if err := m.versions().DeleteLast(ctx); err != nil {
+		return fmt.Errorf("migrate: repair: %w", err)
+	}
+
+	m.report(MsgRepaired, last.Version, last.Description)
+	return nil
+}
+
+func (m *Migrate) clearDirtyCheckpoints(ctx context.Context) error {
+	rec, dirty, err := m.DirtyVersion(ctx)
+	if err != nil || !dirty {
+		return err
+	}
+
+	for _, dir := range []direction{directionUp, directionDown} {
+		if err := m.clearCheckpoints(ctx, rec.Version, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findMigration(migrations []Migration, version uint64) (Migration, bool) {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DryRunStep describes commands which "up" migration would issue.
+type DryRunStep struct {
+	Version     uint64
+	Description string
+
+	// Declarative reports whether migration commands were rendered. Callbacks of other migrations
+	// are opaque, so they are not called by DryRun.
+	Declarative bool
+	Commands    []bson.D
+}
+
+// String renders step as a header followed by commands in relaxed Extended JSON, one per line.
+func (s DryRunStep) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", s.Version, s.Description)
+	if !s.Declarative {
+		b.WriteString(" (not declarative, commands unknown)")
+	}
+	for _, command := range s.Commands {
+		data, err := bson.MarshalExtJSON(command, false, false)
+		if err != nil {
+			fmt.Fprintf(&b, "\n\t<%v>", err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n\t%s", data)
+	}
+
+	return b.String()
+}
+
+// RunCommand runs database command. It's a command-construction layer used by declarative migrations:
+// during DryRun command is recorded instead of execution.
+// Command is checked against CommandPolicy before execution, secret placeholders are resolved right before it (see SetSecretResolver).
+func RunCommand(ctx context.Context, db *mongo.Database, command bson.D) error {
+	if state, ok := runFromContext(ctx); ok {
+		if len(command) > 0 {
+			if err := state.migrate.checkCommand(command[0].Key); err != nil {
+				return err
+			}
+		}
+		if state.dryRun != nil {
+			*state.dryRun = append(*state.dryRun, command)
+			return nil
+		}
+
+		resolved, err := resolveSecrets(ctx, state.migrate.secrets, command)
+		if err != nil {
+			return err
+		}
+		command = resolved
+	}
+
+	return db.RunCommand(ctx, command).Err()
+}
+
+// DryRun renders commands which Up(ctx, n) would issue without executing them.
+// Migrations are planned the same way as by Plan, including missed ones (see SetMissedMigrations).
+// Only callbacks of declarative migrations are called, see Migration.
+func (m *Migrate) DryRun(ctx context.Context, n int) ([]DryRunStep, error) {
+	current, err := m.currentRecord(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := m.upPlan(ctx, current.Version, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []DryRunStep
+	for _, migration := range migrations {
+		step := DryRunStep{Version: migration.Version, Description: migration.Description, Declarative: migration.Declarative}
+		if migration.Declarative {
+			runCtx := m.runContext(ctx, migration, directionUp)
+			state, _ := runFromContext(runCtx)
+			state.dryRun = &step.Commands
+			if err := migration.Up(runCtx, m.db); err != nil {
+				return steps, fmt.Errorf("migrate: dry run of migration %d: %w", migration.Version, err)
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,41 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRunCommandDryRun(t *testing.T) {
+	model := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName("email_1")}
+	migrations, err := StagedIndexDrop(1, 2, "users", model)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	m := NewMigrate(nil)
+	step := DryRunStep{Version: 2, Description: migrations[1].Description, Declarative: migrations[1].Declarative}
+	ctx := m.runContext(context.Background(), migrations[1], directionUp)
+	state, _ := runFromContext(ctx)
+	state.dryRun = &step.Commands
+	if err := migrations[1].Up(ctx, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	expected := "2 drop hidden index email_1 of users\n\t" + `{"dropIndexes":"users","index":"email_1"}`
+	if actual := step.String(); actual != expected {
+		t.Errorf("Unexpected rendered step: %q", actual)
+	}
+}
+
+func TestDryRunStepOpaque(t *testing.T) {
+	step := DryRunStep{Version: 3, Description: "custom"}
+	if actual := step.String(); actual != "3 custom (not declarative, commands unknown)" {
+		t.Errorf("Unexpected rendered step: %q", actual)
+	}
+}
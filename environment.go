@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrForbiddenEnvironment returned when planned migration must never run in current environment,
+// see Migration.ForbiddenEnvironments.
+var ErrForbiddenEnvironment = errors.New("migrate: migration is forbidden in environment")
+
+// forbiddenIn reports if migration must not run in environment. Unknown (empty) environment may be any of them,
+// so migration with restrictions is forbidden there.
+func forbiddenIn(migration Migration, environment string) bool {
+	if len(migration.ForbiddenEnvironments) == 0 {
+		return false
+	}
+	if environment == "" {
+		return true
+	}
+
+	for _, forbidden := range migration.ForbiddenEnvironments {
+		if strings.EqualFold(forbidden, environment) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEnvironment fails if any migration of plan is forbidden in environment set by SetRunInfo.
+// It's called before the first migration of plan is performed, so run is refused as a whole.
+func (m *Migrate) checkEnvironment(plan []Migration) error {
+	environment := m.runInfo.Environment
+
+	var versions []string
+	for _, migration := range plan {
+		if forbiddenIn(migration, environment) {
+			versions = append(versions, fmt.Sprintf("%d (%s)", migration.Version, migration.Description))
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	if environment == "" {
+		environment = "<unset>"
+	}
+	return fmt.Errorf("%w %q: %s", ErrForbiddenEnvironment, environment, strings.Join(versions, ", "))
+}
+
+// migrationsAt returns migrations with provided indexes of sorted migrations list.
+func (m *Migrate) migrationsAt(plan []int) []Migration {
+	ret := make([]Migration, 0, len(plan))
+	for _, i := range plan {
+		ret = append(ret, m.migrations[i])
+	}
+	return ret
+}
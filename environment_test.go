@@ -0,0 +1,26 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckEnvironment(t *testing.T) {
+	plan := []Migration{
+		{Version: 1},
+		{Version: 2, Description: "wipe test data", ForbiddenEnvironments: []string{"prod", "staging"}},
+	}
+
+	for environment, forbidden := range map[string]bool{"dev": false, "PROD": true, "staging": true, "": true} {
+		m := NewMigrate(nil)
+		m.SetRunInfo(RunInfo{Environment: environment})
+		err := m.checkEnvironment(plan)
+		if forbidden != errors.Is(err, ErrForbiddenEnvironment) {
+			t.Errorf("Unexpected error for environment %q: %v", environment, err)
+		}
+	}
+
+	if err := NewMigrate(nil).checkEnvironment(plan[:1]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
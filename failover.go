@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// ErrNoPrimary returned when run was aborted by failover and new primary was not elected in FailoverRetry.Wait.
+var ErrNoPrimary = errors.New("migrate: primary is not available")
+
+// FailoverRetry configures automatic resume of run aborted by primary election.
+type FailoverRetry struct {
+	// Attempts is a maximum number of resumes of one run. Zero disables automatic resume.
+	Attempts int
+
+	// Wait limits waiting for new primary before each resume. Default is 30 seconds.
+	Wait time.Duration
+
+	// PollInterval is a pause between checks of primary availability. Default is 1 second.
+	PollInterval time.Duration
+}
+
+const (
+	defaultPrimaryWait         = 30 * time.Second
+	defaultPrimaryPollInterval = time.Second
+)
+
+// failoverCodes are codes of server errors caused by primary step down or election.
+var failoverCodes = []int{
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// SetFailoverRetry enables automatic resume of Up and ApplyAll aborted by primary election.
+// After failover migrator waits for new primary, re-reads recorded history and performs the rest of plan.
+// Migration interrupted halfway is replayed from scratch (or from its last checkpoint), so migrations
+// must be idempotent to use this mode. By default, runs are not resumed.
+func (m *Migrate) SetFailoverRetry(retry FailoverRetry) {
+	if retry.Wait <= 0 {
+		retry.Wait = defaultPrimaryWait
+	}
+	if retry.PollInterval <= 0 {
+		retry.PollInterval = defaultPrimaryPollInterval
+	}
+	m.failoverRetry = retry
+}
+
+// upWithFailover performs up resuming it after failover according to FailoverRetry.
+// Resumed run performs only the rest of n migrations. Replay allows dirty migration to be continued, see ResumeDirty.
+func (m *Migrate) upWithFailover(ctx context.Context, n int, replay bool) error {
+	applied, err := m.up(ctx, n, replay)
+	for attempt := 1; err != nil && attempt <= m.failoverRetry.Attempts && isFailover(err); attempt++ {
+		m.report(MsgFailoverResume, attempt, m.failoverRetry.Attempts, err)
+		if err := m.awaitPrimary(ctx); err != nil {
+			return err
+		}
+
+		version, _, verErr := m.Version(ctx)
+		if verErr != nil {
+			return verErr
+		}
+		m.report(MsgFailoverResuming, version)
+		if n > 0 {
+			n -= applied
+		}
+		// migration interrupted by this run is left dirty, it's replayed
+		applied, err = m.up(ctx, n, true)
+	}
+
+	return err
+}
+
+// awaitPrimary blocks until primary answers ping or FailoverRetry.Wait elapses.
+func (m *Migrate) awaitPrimary(ctx context.Context) error {
+	waitCtx, cancel := context.WithTimeout(ctx, m.failoverRetry.Wait)
+	defer cancel()
+
+	for {
+		err := m.db.Client().Ping(waitCtx, readpref.Primary())
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("%w in %s: %v", ErrNoPrimary, m.failoverRetry.Wait, err)
+		case <-time.After(m.failoverRetry.PollInterval):
+		}
+	}
+}
+
+// isFailover reports if error is caused by primary step down, election or lost connection to primary.
+func isFailover(err error) bool {
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+
+	var selectionErr topology.ServerSelectionError
+	if errors.As(err, &selectionErr) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	for _, code := range failoverCodes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsFailover(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		failover bool
+	}{
+		{mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}, true},
+		{fmt.Errorf("wrapped: %w", mongo.CommandError{Code: 10107}), true},
+		{mongo.WriteException{WriteConcernError: &mongo.WriteConcernError{Code: 11602}}, true},
+		{mongo.CommandError{Labels: []string{"NetworkError"}}, true},
+		{mongo.CommandError{Code: 11000, Name: "DuplicateKey"}, false},
+		{errors.New("migration failed"), false},
+	} {
+		if isFailover(tc.err) != tc.failover {
+			t.Errorf("Unexpected failover detection of %v", tc.err)
+		}
+	}
+}
+
+func TestSetFailoverRetryDefaults(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetFailoverRetry(FailoverRetry{Attempts: 1})
+	if m.failoverRetry.Wait != defaultPrimaryWait || m.failoverRetry.PollInterval != defaultPrimaryPollInterval {
+		t.Errorf("Unexpected failover retry: %+v", m.failoverRetry)
+	}
+}
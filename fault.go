@@ -0,0 +1,49 @@
+package migrate
+
+import "fmt"
+
+// FaultPoint identifies place of migration process where FaultInjector is consulted.
+type FaultPoint string
+
+const (
+	// FaultAfterUp consulted after successful "up" callback but before new version is recorded.
+	FaultAfterUp FaultPoint = "after-up"
+
+	// FaultAfterDown consulted after successful "down" callback but before previous version is recorded.
+	FaultAfterDown FaultPoint = "after-down"
+
+	// FaultMidBatch consulted by Batch and PacedDelete after batch is written but before its checkpoint is saved,
+	// so run resumed by ResumeDirty processes this batch again.
+	FaultMidBatch FaultPoint = "mid-batch"
+)
+
+// FaultInjector decides if failure should be injected at provided point of migration with provided version.
+// Returned non-nil error aborts migration process as if it was returned by migration callback.
+type FaultInjector func(point FaultPoint, version uint64) error
+
+// FailAt returns FaultInjector which returns err at provided point of migration with provided version.
+func FailAt(point FaultPoint, version uint64, err error) FaultInjector {
+	return func(p FaultPoint, v uint64) error {
+		if p == point && v == version {
+			return err
+		}
+		return nil
+	}
+}
+
+// SetFaultInjector sets injector of failures into migration process.
+// It is intended for tests only: use it to verify recovery procedures against partially applied migrations.
+func (m *Migrate) SetFaultInjector(injector FaultInjector) {
+	m.faultInjector = injector
+}
+
+func (m *Migrate) injectFault(point FaultPoint, version uint64) error {
+	if m.faultInjector == nil {
+		return nil
+	}
+
+	if err := m.faultInjector(point, version); err != nil {
+		return fmt.Errorf("migrate: fault injected at %s of version %d: %w", point, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFailAt(t *testing.T) {
+	expectedErr := errors.New("injected")
+	m := NewMigrate(nil)
+	m.SetFaultInjector(FailAt(FaultAfterUp, 2, expectedErr))
+
+	if err := m.injectFault(FaultAfterUp, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := m.injectFault(FaultAfterDown, 2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := m.injectFault(FaultAfterUp, 2); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
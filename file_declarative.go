@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// declarativeOps expands shorthand operations of migration files into commands.
+// Operation is recognized by the name of the first field which value is a target collection.
+var declarativeOps = map[string]func(collection string, args bson.D) (bson.D, error){
+	"createIndex": expandCreateIndex,
+	"dropIndex":   expandDropIndex,
+	"renameField": expandRenameField,
+}
+
+// expandCommand returns command for shorthand operation or command itself if it is not a shorthand.
+func expandCommand(command bson.D) (bson.D, error) {
+	if len(command) == 0 {
+		return command, nil
+	}
+	expand, ok := declarativeOps[command[0].Key]
+	if !ok {
+		return command, nil
+	}
+
+	collection, ok := command[0].Value.(string)
+	if !ok || collection == "" {
+		return nil, fmt.Errorf("%s: collection name must be a string", command[0].Key)
+	}
+	ret, err := expand(collection, command[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", command[0].Key, err)
+	}
+	return ret, nil
+}
+
+// expandCreateIndex handles {"createIndex": "<collection>", "keys": {...}, <index options>}.
+// Index name is generated from keys like drivers do if it is not set.
+func expandCreateIndex(collection string, args bson.D) (bson.D, error) {
+	keys, ok := argValue(args, "keys").(bson.D)
+	if !ok || len(keys) == 0 {
+		return nil, errors.New("keys must be a non-empty document")
+	}
+
+	index := bson.D{{Key: "key", Value: keys}}
+	if argValue(args, "name") == nil {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+		}
+		index = append(index, bson.E{Key: "name", Value: strings.Join(parts, "_")})
+	}
+	for _, e := range args {
+		if e.Key != "keys" {
+			index = append(index, e)
+		}
+	}
+
+	return bson.D{{Key: "createIndexes", Value: collection}, {Key: "indexes", Value: bson.A{index}}}, nil
+}
+
+// expandDropIndex handles {"dropIndex": "<collection>", "name": "<index>"}.
+func expandDropIndex(collection string, args bson.D) (bson.D, error) {
+	name, ok := argValue(args, "name").(string)
+	if !ok || name == "" {
+		return nil, errors.New("name must be a non-empty string")
+	}
+
+	return bson.D{{Key: "dropIndexes", Value: collection}, {Key: "index", Value: name}}, nil
+}
+
+// expandRenameField handles {"renameField": "<collection>", "from": "<field>", "to": "<field>"}.
+// Field is renamed in all documents having it.
+func expandRenameField(collection string, args bson.D) (bson.D, error) {
+	from, _ := argValue(args, "from").(string)
+	to, _ := argValue(args, "to").(string)
+	if from == "" || to == "" {
+		return nil, errors.New("from and to must be non-empty strings")
+	}
+
+	update := bson.D{
+		{Key: "q", Value: bson.D{{Key: from, Value: bson.D{{Key: "$exists", Value: true}}}}},
+		{Key: "u", Value: bson.D{{Key: "$rename", Value: bson.D{{Key: from, Value: to}}}}},
+		{Key: "multi", Value: true},
+	}
+	return bson.D{{Key: "update", Value: collection}, {Key: "updates", Value: bson.A{update}}}, nil
+}
+
+func argValue(args bson.D, key string) interface{} {
+	for _, e := range args {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	upFileSuffix   = ".up.json"
+	downFileSuffix = ".down.json"
+)
+
+// NewFileMigrations loads migrations from files of dir in fsys. It is compatible with embed.FS.
+// Files should be named like "<version>_<description>.up.json" and "<version>_<description>.down.json".
+// Each file contains a command document or an array of command documents in MongoDB Extended JSON
+// which are run one by one using "runCommand". "down" file is optional.
+// Besides commands, files may contain shorthand operations which are expanded to commands at load time:
+//
+//	{"createIndex": "users", "keys": {"login": 1}, "unique": true}
+//	{"dropIndex": "users", "name": "login_1"}
+//	{"renameField": "users", "from": "login", "to": "username"}
+//
+// Files are Go text/template templates evaluated at load time with functions for dates, environment lookups
+// and ObjectID generation (now, addDays, date, env, envOr, objectID, json) and secret placeholders (secret), e.g.
+//
+//	{"delete": "sessions", "deletes": [{"q": {"created": {"$lt": {{ date (addDays now -30) }}}}, "limit": 0}]}
+//
+// Literal "{{" must be written as {{"{{"}}.
+// Checksum of migration is a hash of its "up" file, see Validate.
+// Files with other names are ignored.
+func NewFileMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	var order []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		var dirn direction
+		switch {
+		case strings.HasSuffix(name, upFileSuffix):
+			dirn = directionUp
+		case strings.HasSuffix(name, downFileSuffix):
+			dirn = directionDown
+		default:
+			continue
+		}
+
+		version, description, err := splitVersionDescription(strings.TrimSuffix(strings.TrimSuffix(name, upFileSuffix), downFileSuffix))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		// checksum of template, so values like "now" do not change it
+		checksum := Checksum(data)
+		if data, err = renderTemplate(name, data); err != nil {
+			return nil, fmt.Errorf("migrate: render %q: %w", name, err)
+		}
+		commands, err := parseCommands(data)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: parse %q: %w", name, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Description: description, Declarative: true}
+			byVersion[version] = migration
+			order = append(order, version)
+		} else if migration.Description != description {
+			return nil, fmt.Errorf("migrate: files of version %d have different descriptions", version)
+		}
+
+		switch dirn {
+		case directionUp:
+			migration.Up = runCommands(commands)
+			migration.Checksum = checksum
+		case directionDown:
+			migration.Down = runCommands(commands)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(order))
+	for _, version := range order {
+		migration := byVersion[version]
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migrate: no up file for version %d", version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	migrationSort(migrations)
+
+	return migrations, nil
+}
+
+// parseCommands parses single command document or array of command documents in Extended JSON.
+// Shorthand operations are expanded to commands, see declarativeOps.
+func parseCommands(data []byte) ([]bson.D, error) {
+	commands, err := parseCommandDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, command := range commands {
+		if commands[i], err = expandCommand(command); err != nil {
+			return nil, fmt.Errorf("command %d: %w", i, err)
+		}
+	}
+	return commands, nil
+}
+
+func parseCommandDocuments(data []byte) ([]bson.D, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var command bson.D
+		if err := bson.UnmarshalExtJSON(data, false, &command); err != nil {
+			return nil, err
+		}
+		return []bson.D{command}, nil
+	}
+
+	// Extended JSON parser accepts only documents at top level
+	wrapped := append(append([]byte(`{"commands":`), data...), '}')
+	var doc struct {
+		Commands []bson.D `bson:"commands"`
+	}
+	if err := bson.UnmarshalExtJSON(wrapped, false, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Commands, nil
+}
+
+func runCommands(commands []bson.D) MigrationFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for i, command := range commands {
+			// most of commands take target collection name as a value of the first field
+			if len(command) > 0 {
+				if collection, ok := command[0].Value.(string); ok {
+					if err := checkNamespace(ctx, collection); err != nil {
+						return fmt.Errorf("command %d: %w", i, err)
+					}
+				}
+			}
+			if err := RunCommand(ctx, db, command); err != nil {
+				return fmt.Errorf("command %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNewFileMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/1_create_users.up.json":   {Data: []byte(`{"create": "users"}`)},
+		"migrations/1_create_users.down.json": {Data: []byte(`{"drop": "users"}`)},
+		"migrations/2_add_index.up.json": {Data: []byte(`[
+			{"createIndexes": "users", "indexes": [{"key": {"login": 1}, "name": "login_1", "unique": true}]}
+		]`)},
+		"migrations/README.md": {Data: []byte("ignored")},
+	}
+	migrations, err := NewFileMigrations(fsys, "migrations")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(migrations) != 2 {
+		t.Errorf("Unexpected migrations count: %v", len(migrations))
+		return
+	}
+	if migrations[0].Version != 1 || migrations[0].Description != "create_users" || migrations[0].Up == nil || migrations[0].Down == nil {
+		t.Errorf("Unexpected first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Description != "add_index" || migrations[1].Up == nil || migrations[1].Down != nil {
+		t.Errorf("Unexpected second migration: %+v", migrations[1])
+	}
+}
+
+func TestNewFileMigrationsErrors(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad json":     {"m/1_a.up.json": {Data: []byte(`{"create": `)}},
+		"bad template": {"m/1_a.up.json": {Data: []byte(`{"create": {{ unknown }}}`)}},
+		"no up file":   {"m/1_a.down.json": {Data: []byte(`{"drop": "a"}`)}},
+		"bad version":  {"m/a_b.up.json": {Data: []byte(`{"create": "a"}`)}},
+		"descriptions": {"m/1_a.up.json": {Data: []byte(`{"create": "a"}`)}, "m/1_b.down.json": {Data: []byte(`{"drop": "a"}`)}},
+	} {
+		if _, err := NewFileMigrations(fsys, "m"); err == nil {
+			t.Errorf("Unexpected nil error for %s", name)
+		}
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	commands, err := parseCommands([]byte(` [{"create": "a"}, {"create": "b"}] `))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(commands) != 2 || commands[1][0].Value != "b" {
+		t.Errorf("Unexpected commands: %v", commands)
+	}
+}
+
+func TestNewFileMigrationsTemplate(t *testing.T) {
+	t.Setenv("TEST_TENANT", `acme "inc"`)
+	fsys := fstest.MapFS{
+		"m/1_seed.up.json": {Data: []byte(`{"insert": "tenants", "documents": [
+			{"_id": {{ objectID }}, "name": {{ env "TEST_TENANT" | json }}, "plan": "{{ envOr "TEST_UNSET_PLAN" "free" }}",
+			 "expires": {{ date (addDays now 30) }}}
+		]}`)},
+	}
+	migrations, err := NewFileMigrations(fsys, "m")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	var commands []bson.D
+	ctx := NewMigrate(nil).runContext(context.Background(), migrations[0], directionUp)
+	state, _ := runFromContext(ctx)
+	state.dryRun = &commands
+	if err := migrations[0].Up(ctx, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	raw, err := bson.Marshal(commands[0])
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	doc := bson.Raw(raw).Lookup("documents", "0").Document()
+	if _, ok := doc.Lookup("_id").ObjectIDOK(); !ok {
+		t.Errorf("Unexpected id: %v", doc.Lookup("_id"))
+	}
+	if name := doc.Lookup("name").StringValue(); name != `acme "inc"` {
+		t.Errorf("Unexpected name: %v", name)
+	}
+	if plan := doc.Lookup("plan").StringValue(); plan != "free" {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+	if expires := doc.Lookup("expires").Time(); expires.Before(time.Now().AddDate(0, 0, 29)) {
+		t.Errorf("Unexpected expiration: %v", expires)
+	}
+}
+
+func TestParseCommandsDeclarative(t *testing.T) {
+	commands, err := parseCommands([]byte(`[
+		{"createIndex":"users", "keys":{"login":1, "tenant":-1}, "unique":true},
+		{"dropIndex":"users", "name":"old_1"},
+		{"renameField":"users", "from":"login", "to":"username"},
+		{"create":"plain"}
+	]`))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	expected := []string{
+		`{"createIndexes":"users","indexes":[{"key":{"login":1,"tenant":-1},"name":"login_1_tenant_-1","unique":true}]}`,
+		`{"dropIndexes":"users","index":"old_1"}`,
+		`{"update":"users","updates":[{"q":{"login":{"$exists":true}},"u":{"$rename":{"login":"username"}},"multi":true}]}`,
+		`{"create":"plain"}`,
+	}
+	if len(commands) != len(expected) {
+		t.Errorf("Unexpected commands: %v", commands)
+		return
+	}
+	for i, command := range commands {
+		data, err := bson.MarshalExtJSON(command, false, false)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if string(data) != expected[i] {
+			t.Errorf("Unexpected command %d: %s", i, data)
+		}
+	}
+
+	for _, data := range []string{
+		`{"createIndex":"users"}`,
+		`{"dropIndex":"users"}`,
+		`{"renameField":"users", "from":"a"}`,
+		`{"renameField":1, "from":"a", "to":"b"}`,
+	} {
+		if _, err := parseCommands([]byte(data)); err == nil {
+			t.Errorf("Unexpected nil error for %s", data)
+		}
+	}
+}
+
+func TestNewFileMigrationsChecksum(t *testing.T) {
+	up := []byte(`{"insert": "a", "documents": [{"at": {{ date now }}}]}`)
+	fsys := fstest.MapFS{"m/1_a.up.json": {Data: up}}
+	migrations, err := NewFileMigrations(fsys, "m")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if migrations[0].Checksum != Checksum(up) {
+		t.Errorf("Unexpected checksum: %s", migrations[0].Checksum)
+	}
+}
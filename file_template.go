@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// templateFuncs are functions available in migration files:
+//
+// - now: current time in UTC
+//
+// - addDays: time shifted by number of days, e.g. {{ addDays now -30 }}
+//
+// - date: Extended JSON date literal for time, e.g. {{ date now }}
+//
+// - env, envOr: value of environment variable, the latter with default for unset variable
+//
+// - objectID: Extended JSON literal of newly generated ObjectID
+//
+// - json: value encoded as JSON, e.g. {{ env "TENANT" | json }} renders quoted and escaped string
+//
+// - secret: placeholder of secret resolved when command runs, see SetSecretResolver
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"now": func() time.Time {
+			return time.Now().UTC()
+		},
+		"addDays": func(t time.Time, days int) time.Time {
+			return t.AddDate(0, 0, days)
+		},
+		"date": func(t time.Time) string {
+			return `{"$date":"` + t.UTC().Format(time.RFC3339Nano) + `"}`
+		},
+		"env": os.Getenv,
+		"envOr": func(name, def string) string {
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+			return def
+		},
+		"objectID": func() string {
+			return `{"$oid":"` + primitive.NewObjectID().Hex() + `"}`
+		},
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"secret": func(name string) (string, error) {
+			data, err := json.Marshal(name)
+			return `{"` + secretKey + `":` + string(data) + `}`, err
+		},
+	}
+}
+
+// renderTemplate evaluates text/template syntax of migration file.
+func renderTemplate(name string, data []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -3,9 +3,13 @@ package migrate
 import (
 	"context"
 	"fmt"
+	"os"
 	"runtime"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var globalMigrate = NewMigrate(nil)
@@ -38,6 +42,8 @@ func internalRegister(up, down MigrationFunc, skip int) error {
 //	 package migrations
 //
 //	 import (
+//		 "context"
+//
 //		 "go.mongodb.org/mongo-driver/bson"
 //		 "go.mongodb.org/mongo-driver/mongo"
 //		 "go.mongodb.org/mongo-driver/mongo/options"
@@ -45,17 +51,17 @@ func internalRegister(up, down MigrationFunc, skip int) error {
 //	 )
 //
 //	 func init() {
-//		 migrate.Register(func(db *mongo.Database) error {
+//		 migrate.Register(func(ctx context.Context, db *mongo.Database) error {
 //		 	 opt := options.Index().SetName("my-index")
 //		 	 keys := bson.D{{Key: "my-key", Value: 1}}
 //		 	 model := mongo.IndexModel{Keys: keys, Options: opt}
-//		 	 _, err := db.Collection("my-coll").Indexes().CreateOne(context.TODO(), model)
+//		 	 _, err := db.Collection("my-coll").Indexes().CreateOne(ctx, model)
 //		 	 if err != nil {
 //		 		 return err
 //		 	 }
 //		 	 return nil
-//		 }, func(db *mongo.Database) error {
-//		 	 _, err := db.Collection("my-coll").Indexes().DropOne(context.TODO(), "my-index")
+//		 }, func(ctx context.Context, db *mongo.Database) error {
+//		 	 _, err := db.Collection("my-coll").Indexes().DropOne(ctx, "my-index")
 //		 	 if err != nil {
 //		 		 return err
 //		 	 }
@@ -73,6 +79,33 @@ func MustRegister(up, down MigrationFunc) {
 	}
 }
 
+// RegisterMigration registers fully described migration, e.g. with Verify step or Collections set.
+// Version and description are taken from migration, not from file name as Register does.
+// Registration of already registered or invalid migration fails.
+func RegisterMigration(migration Migration) error {
+	if err := ValidateMigrations([]Migration{migration}); err != nil {
+		return err
+	}
+	if hasVersion(globalMigrate.migrations, migration.Version) {
+		return fmt.Errorf("migration with version %v already registered", migration.Version)
+	}
+	globalMigrate.migrations = append(globalMigrate.migrations, migration)
+	return nil
+}
+
+// MustRegisterMigration acts like RegisterMigration but panics on errors.
+func MustRegisterMigration(migration Migration) {
+	if err := RegisterMigration(migration); err != nil {
+		panic(err)
+	}
+}
+
+// NewMigrateFromRegistry returns migrator for registered migrations. Unlike global functions it has
+// own settings, so several databases may be migrated with the same migrations.
+func NewMigrateFromRegistry(db *mongo.Database) *Migrate {
+	return NewMigrate(db, globalMigrate.migrations...)
+}
+
 // RegisteredMigrations returns all registered migrations.
 func RegisteredMigrations() []Migration {
 	ret := make([]Migration, len(globalMigrate.migrations))
@@ -90,24 +123,281 @@ func SetMigrationsCollection(name string) {
 	globalMigrate.SetMigrationsCollection(name)
 }
 
+// SetCollectionAffixes renames all bookkeeping collections to default names surrounded by prefix and suffix.
+func SetCollectionAffixes(prefix, suffix string) {
+	globalMigrate.SetCollectionAffixes(prefix, suffix)
+}
+
+// SetCheckpointsCollection changes default collection name for progress of interrupted migrations.
+func SetCheckpointsCollection(name string) {
+	globalMigrate.SetCheckpointsCollection(name)
+}
+
+// SetViewsCollection changes default collection name for freshness metadata of materialized views.
+func SetViewsCollection(name string) {
+	globalMigrate.SetViewsCollection(name)
+}
+
+// SetMaterializedViews sets materialized views refreshed by every "Up" call.
+func SetMaterializedViews(views ...MaterializedView) {
+	globalMigrate.SetMaterializedViews(views...)
+}
+
+// SetSeeds sets seeds applied by ApplyAll.
+func SetSeeds(seeds ...Seed) {
+	globalMigrate.SetSeeds(seeds...)
+}
+
+// SetCommandPolicy sets policy of commands which migrations may execute.
+func SetCommandPolicy(policy CommandPolicy) {
+	globalMigrate.SetCommandPolicy(policy)
+}
+
+// SetAuditCollection changes default collection name for audit records.
+func SetAuditCollection(name string) {
+	globalMigrate.SetAuditCollection(name)
+}
+
+// SetAudit enables writing of audit record for each performed migration.
+func SetAudit(enabled bool) {
+	globalMigrate.SetAudit(enabled)
+}
+
+// SetTrack makes global migrator version independent migration track.
+func SetTrack(name string) {
+	globalMigrate.SetTrack(name)
+}
+
+// SetIDGenerator sets generator of identifiers of audit documents.
+func SetIDGenerator(gen IDGenerator) {
+	globalMigrate.SetIDGenerator(gen)
+}
+
+// SetProfiling enables capture of slow operations of registered migrations into audit records.
+// Detailed description available in Migrate.SetProfiling().
+func SetProfiling(threshold time.Duration, limit int) {
+	globalMigrate.SetProfiling(threshold, limit)
+}
+
+// SetStorageLimit sets guardrails checked before registered migrations which declare expected growth.
+func SetStorageLimit(limit StorageLimit) {
+	globalMigrate.SetStorageLimit(limit)
+}
+
+// SetBSONRegistry sets codec registry used for documents of migrations.
+func SetBSONRegistry(registry *bsoncodec.Registry) {
+	globalMigrate.SetBSONRegistry(registry)
+}
+
+// SetBSONOptions sets BSON marshaling and unmarshaling behavior for documents of migrations.
+func SetBSONOptions(opts *options.BSONOptions) {
+	globalMigrate.SetBSONOptions(opts)
+}
+
+// SetRunLock enables lock which prevents concurrent runs of Up and Down by different processes.
+func SetRunLock(ttl time.Duration, wait bool) {
+	globalMigrate.SetRunLock(ttl, wait)
+}
+
+// SetTransactions enables running each migration together with version record write in one transaction.
+func SetTransactions(enabled bool) {
+	globalMigrate.SetTransactions(enabled)
+}
+
+// SetRunControl enables pausing of runs at migration boundaries, see PauseRun.
+func SetRunControl(enabled bool) {
+	globalMigrate.SetRunControl(enabled)
+}
+
+// PauseRun requests pause of runs before their next migration.
+func PauseRun(ctx context.Context) error {
+	return globalMigrate.PauseRun(ctx)
+}
+
+// ResumeRun cancels pause request, so paused runs continue.
+func ResumeRun(ctx context.Context) error {
+	return globalMigrate.ResumeRun(ctx)
+}
+
+// RunControl returns current state of run control document.
+func RunControl(ctx context.Context) (RunControlRecord, error) {
+	return globalMigrate.RunControl(ctx)
+}
+
+// NotifyContext installs handler of signals which gracefully stops runs of registered migrations.
+// Detailed description available in Migrate.NotifyContext().
+func NotifyContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	return globalMigrate.NotifyContext(parent, signals...)
+}
+
+// SetVersionStore replaces storage of migrations history of registered migrations.
+func SetVersionStore(store VersionStore) {
+	globalMigrate.SetVersionStore(store)
+}
+
+// UpgradeSchema converts documents of bookkeeping collections to layout of current package version.
+// Detailed description available in Migrate.UpgradeSchema().
+func UpgradeSchema(ctx context.Context) error {
+	return globalMigrate.UpgradeSchema(ctx)
+}
+
+// SetMissedMigrations sets handling of registered migrations older than current version which were never applied.
+func SetMissedMigrations(policy MissedMigrationsPolicy) {
+	globalMigrate.SetMissedMigrations(policy)
+}
+
+// SetFailoverRetry enables automatic resume of runs aborted by primary election.
+// Detailed description available in Migrate.SetFailoverRetry().
+func SetFailoverRetry(retry FailoverRetry) {
+	globalMigrate.SetFailoverRetry(retry)
+}
+
+// SetLocksCollection changes default collection name for locks.
+func SetLocksCollection(name string) {
+	globalMigrate.SetLocksCollection(name)
+}
+
+// SetNamespaceFilter restricts collections which may be touched by migrations helpers.
+func SetNamespaceFilter(filter NamespaceFilter) error {
+	return globalMigrate.SetNamespaceFilter(filter)
+}
+
+// SetRunInfo sets environment description passed to registered migrations via context.
+func SetRunInfo(info RunInfo) {
+	globalMigrate.SetRunInfo(info)
+}
+
 // Version returns current database version.
 func Version(ctx context.Context) (uint64, string, error) {
 	return globalMigrate.Version(ctx)
 }
 
+// CurrentVersion returns full record of current database version together with registered head version.
+// Detailed description available in Migrate.CurrentVersion().
+func CurrentVersion(ctx context.Context) (VersionInfo, error) {
+	return globalMigrate.CurrentVersion(ctx)
+}
+
+// SetPendingHandler sets callback called by Notify when database is behind.
+func SetPendingHandler(handler PendingHandler) {
+	globalMigrate.SetPendingHandler(handler)
+}
+
+// Notify checks whether database is behind the newest registered migration without running migrations.
+func Notify(ctx context.Context) (VersionInfo, error) {
+	return globalMigrate.Notify(ctx)
+}
+
+// Status reports state of registered and recorded migrations.
+// Detailed description available in Migrate.Status().
+func Status(ctx context.Context) ([]MigrationStatus, error) {
+	return globalMigrate.Status(ctx)
+}
+
+// Plan returns registered migrations which would be performed by Up(ctx, n).
+func Plan(ctx context.Context, n int) ([]MigrationStatus, error) {
+	return globalMigrate.Plan(ctx, n)
+}
+
+// PlanAll returns work which would be performed by ApplyAll.
+func PlanAll(ctx context.Context) (CompositePlan, error) {
+	return globalMigrate.PlanAll(ctx)
+}
+
+// Validate checks that history of applied migrations matches registered migrations.
+// Detailed description available in Migrate.Validate().
+func Validate(ctx context.Context) error {
+	return globalMigrate.Validate(ctx)
+}
+
+// VerifyRestore checks consistency of migrations history against registered migrations.
+// Detailed description available in Migrate.VerifyRestore().
+func VerifyRestore(ctx context.Context) error {
+	return globalMigrate.VerifyRestore(ctx)
+}
+
+// DirtyVersion returns record of migration which failed halfway.
+// Detailed description available in Migrate.DirtyVersion().
+func DirtyVersion(ctx context.Context) (VersionRecord, bool, error) {
+	return globalMigrate.DirtyVersion(ctx)
+}
+
+// Force records provided version as current one without running migrations, clearing dirty state.
+func Force(ctx context.Context, version uint64) error {
+	return globalMigrate.Force(ctx, version)
+}
+
+// Repair removes dirty state of failed migration, so it is started from scratch by the next run.
+func Repair(ctx context.Context) error {
+	return globalMigrate.Repair(ctx)
+}
+
+// ResumeDirty performs "up" migration using registered migrations continuing dirty migration from its checkpoints.
+// Detailed description available in Migrate.ResumeDirty().
+func ResumeDirty(ctx context.Context, n int) error {
+	return globalMigrate.ResumeDirty(ctx, n)
+}
+
+// Runs returns up to limit audit records of runs of registered migrations, the newest first.
+func Runs(ctx context.Context, limit int) ([]RunRecord, error) {
+	return globalMigrate.Runs(ctx, limit)
+}
+
+// MetaStats reports footprint of collections used by migrator itself.
+func MetaStats(ctx context.Context) ([]CollectionStats, error) {
+	return globalMigrate.MetaStats(ctx)
+}
+
 // Up performs "up" migration using registered migrations.
 // Detailed description available in Migrate.Up().
 func Up(ctx context.Context, n int) error {
 	return globalMigrate.Up(ctx, n)
 }
 
+// ApplyAll performs registered migrations, refreshes materialized views and applies seeds in one run.
+// Detailed description available in Migrate.ApplyAll().
+func ApplyAll(ctx context.Context) error {
+	return globalMigrate.ApplyAll(ctx)
+}
+
 // Down performs "down" migration using registered migrations.
 // Detailed description available in Migrate.Down().
 func Down(ctx context.Context, n int) error {
 	return globalMigrate.Down(ctx, n)
 }
 
+// MigrateTo migrates database to target version using registered migrations.
+// Detailed description available in Migrate.MigrateTo().
+func MigrateTo(ctx context.Context, target uint64) error {
+	return globalMigrate.MigrateTo(ctx, target)
+}
+
 // SetLogger sets a logger to print the migration process
 func SetLogger(log Logger) {
 	globalMigrate.SetLogger(log)
 }
+
+// SetMessageHandler sets handler which receives messages of migrator.
+func SetMessageHandler(handler MessageHandler) {
+	globalMigrate.SetMessageHandler(handler)
+}
+
+// SetLocalizer sets localizer of message texts passed to logger.
+func SetLocalizer(localizer Localizer) {
+	globalMigrate.SetLocalizer(localizer)
+}
+
+// SetStructuredLogger sets logger with key-value pairs, e.g. *slog.Logger, which gets events of each migration.
+func SetStructuredLogger(log StructuredLogger) {
+	globalMigrate.SetStructuredLogger(log)
+}
+
+// SetSecretResolver sets resolver of secret placeholders in commands of migrations.
+func SetSecretResolver(resolver SecretResolver) {
+	globalMigrate.SetSecretResolver(resolver)
+}
+
+// SetHooks sets hooks called around each performed migration.
+func SetHooks(hooks Hooks) {
+	globalMigrate.SetHooks(hooks)
+}
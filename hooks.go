@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// MigrationEvent describes migration passed to hooks.
+type MigrationEvent struct {
+	Version     uint64
+	Description string
+	Down        bool
+
+	// Duration is a time spent by migration, it is zero for BeforeEach.
+	Duration time.Duration
+}
+
+// Hooks are called around each performed migration, e.g. to report progress or push duration metrics.
+// Any of hooks may be nil.
+type Hooks struct {
+	BeforeEach func(ctx context.Context, event MigrationEvent)
+	AfterEach  func(ctx context.Context, event MigrationEvent)
+	OnError    func(ctx context.Context, event MigrationEvent, err error)
+}
+
+// StructuredLogger is a logger with key-value pairs, *slog.Logger satisfies it.
+type StructuredLogger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// SetHooks sets hooks called around each performed migration.
+func (m *Migrate) SetHooks(hooks Hooks) {
+	m.hooks = hooks
+}
+
+// SetStructuredLogger sets logger which gets start, completion and failure of each migration
+// with attributes "version", "description", "direction", "duration" and "error".
+// It is used together with logger set by SetLogger.
+func (m *Migrate) SetStructuredLogger(log StructuredLogger) {
+	m.structuredLog = log
+}
+
+// observe calls fn which performs migration reporting it to hooks and structured logger.
+func (m *Migrate) observe(ctx context.Context, migration Migration, dir direction, fn func() error) error {
+	event := MigrationEvent{
+		Version:     migration.Version,
+		Description: migration.Description,
+		Down:        dir == directionDown,
+	}
+
+	if m.structuredLog != nil {
+		m.structuredLog.Info("migration started", eventAttrs(event, dir)...)
+	}
+	if m.hooks.BeforeEach != nil {
+		m.hooks.BeforeEach(ctx, event)
+	}
+
+	started := time.Now()
+	err := fn()
+	event.Duration = time.Since(started)
+
+	if err != nil {
+		if m.structuredLog != nil {
+			m.structuredLog.Error("migration failed", append(eventAttrs(event, dir), "error", err)...)
+		}
+		if m.hooks.OnError != nil {
+			m.hooks.OnError(ctx, event, err)
+		}
+		return err
+	}
+
+	if m.structuredLog != nil {
+		m.structuredLog.Info("migration completed", eventAttrs(event, dir)...)
+	}
+	if m.hooks.AfterEach != nil {
+		m.hooks.AfterEach(ctx, event)
+	}
+	return nil
+}
+
+func eventAttrs(event MigrationEvent, dir direction) []any {
+	attrs := []any{"version", event.Version, "description", event.Description, "direction", string(dir)}
+	if event.Duration > 0 {
+		attrs = append(attrs, "duration", event.Duration)
+	}
+	return attrs
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testStructuredLogger struct {
+	lines []string
+}
+
+func (l *testStructuredLogger) Info(msg string, args ...any) {
+	l.lines = append(l.lines, "INFO "+msg+" "+strings.TrimSpace(fmt.Sprintln(args[:6]...)))
+}
+
+func (l *testStructuredLogger) Error(msg string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf("ERROR %s %v", msg, args[len(args)-1]))
+}
+
+func TestObserve(t *testing.T) {
+	var events []string
+	log := &testStructuredLogger{}
+	m := NewMigrate(nil)
+	m.SetStructuredLogger(log)
+	m.SetHooks(Hooks{
+		BeforeEach: func(ctx context.Context, event MigrationEvent) {
+			events = append(events, fmt.Sprintf("before %d %v", event.Version, event.Down))
+		},
+		AfterEach: func(ctx context.Context, event MigrationEvent) {
+			events = append(events, fmt.Sprintf("after %d %v", event.Version, event.Duration > 0))
+		},
+		OnError: func(ctx context.Context, event MigrationEvent, err error) {
+			events = append(events, fmt.Sprintf("error %d %v", event.Version, err))
+		},
+	})
+
+	ctx := context.Background()
+	migration := Migration{Version: 1, Description: "one"}
+	if err := m.observe(ctx, migration, directionUp, func() error { return nil }); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	expectedErr := errors.New("failed")
+	if err := m.observe(ctx, migration, directionDown, func() error { return expectedErr }); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if actual := strings.Join(events, ", "); actual != "before 1 false, after 1 true, before 1 true, error 1 failed" {
+		t.Errorf("Unexpected events: %s", actual)
+	}
+	expectedLog := []string{
+		"INFO migration started version 1 description one direction up",
+		"INFO migration completed version 1 description one direction up",
+		"INFO migration started version 1 description one direction down",
+		"ERROR migration failed failed",
+	}
+	if strings.Join(log.lines, "\n") != strings.Join(expectedLog, "\n") {
+		t.Errorf("Unexpected log:\n%s", strings.Join(log.lines, "\n"))
+	}
+}
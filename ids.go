@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// crockford is an alphabet of Crockford's Base32 used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator returns identifier of new audit document (see AuditRecord and RunRecord).
+// Identifiers must be unique, their order does not matter.
+type IDGenerator func() interface{}
+
+// SetIDGenerator sets generator of identifiers of audit documents, so they follow identifier conventions
+// of the rest of data. By default, it is NewObjectID.
+func (m *Migrate) SetIDGenerator(gen IDGenerator) {
+	m.idGenerator = gen
+}
+
+// NewObjectID is an IDGenerator returning primitive.ObjectID.
+func NewObjectID() interface{} {
+	return primitive.NewObjectID()
+}
+
+// NewULID is an IDGenerator returning ULID (https://github.com/ulid/spec) as 26 characters string.
+func NewULID() interface{} {
+	var id [16]byte
+	putMillis(id[:6], time.Now())
+	_, _ = rand.Read(id[6:])
+
+	return encodeULID(id)
+}
+
+// encodeULID renders ULID in Crockford's Base32.
+func encodeULID(id [16]byte) string {
+	// 128 bits are encoded by 5 bits from the most significant ones, the first character holds 3 bits only
+	var text [26]byte
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := len(text) - 1; i >= 0; i-- {
+		text[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(text[:])
+}
+
+// NewUUIDv7 is an IDGenerator returning time-ordered UUID version 7 (RFC 9562) as BSON binary of UUID subtype.
+func NewUUIDv7() interface{} {
+	var id [16]byte
+	putMillis(id[:6], time.Now())
+	_, _ = rand.Read(id[6:])
+	id[6] = id[6]&0x0f | 0x70
+	id[8] = id[8]&0x3f | 0x80
+
+	return primitive.Binary{Subtype: 0x04, Data: id[:]}
+}
+
+// putMillis writes 48-bit Unix time in milliseconds in big-endian order.
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// newID returns identifier of new audit document.
+func (m *Migrate) newID() interface{} {
+	if m.idGenerator == nil {
+		return NewObjectID()
+	}
+
+	return m.idGenerator()
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewULID(t *testing.T) {
+	first, ok := NewULID().(string)
+	if !ok || len(first) != 26 {
+		t.Errorf("Unexpected ULID: %v", first)
+		return
+	}
+	for _, c := range first {
+		if !strings.ContainsRune(crockford, c) {
+			t.Errorf("Unexpected character of ULID %s: %c", first, c)
+			return
+		}
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second := NewULID().(string)
+	if second <= first {
+		t.Errorf("ULIDs are not ordered by time: %s, %s", first, second)
+	}
+}
+
+func TestNewULIDTimestamp(t *testing.T) {
+	var id [16]byte
+	putMillis(id[:6], time.UnixMilli(1469922850259))
+	// example of specification
+	if text := encodeULID(id); !strings.HasPrefix(text, "01ARZ3NDEK") || text[10:] != "0000000000000000" {
+		t.Errorf("Unexpected ULID: %s", text)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	id, ok := NewUUIDv7().(primitive.Binary)
+	if !ok || id.Subtype != 0x04 || len(id.Data) != 16 {
+		t.Errorf("Unexpected UUID: %v", id)
+		return
+	}
+	if id.Data[6]>>4 != 7 || id.Data[8]>>6 != 2 {
+		t.Errorf("Unexpected version or variant of UUID: %x", id.Data)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	m := NewMigrate(nil)
+	if _, ok := m.newID().(primitive.ObjectID); !ok {
+		t.Errorf("Unexpected default id type")
+	}
+
+	m.SetIDGenerator(NewULID)
+	if _, ok := m.newID().(string); !ok {
+		t.Errorf("Unexpected id type")
+	}
+}
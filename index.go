@@ -0,0 +1,480 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HideIndex hides index from query planner using "collMod" command. Hidden index is still maintained on writes,
+// so it may be unhidden instantly if queries degrade.
+func HideIndex(ctx context.Context, db *mongo.Database, collection, name string) error {
+	return setIndexHidden(ctx, db, collection, name, true)
+}
+
+// UnhideIndex makes hidden index visible to query planner again.
+func UnhideIndex(ctx context.Context, db *mongo.Database, collection, name string) error {
+	return setIndexHidden(ctx, db, collection, name, false)
+}
+
+func setIndexHidden(ctx context.Context, db *mongo.Database, collection, name string, hidden bool) error {
+	if err := checkNamespace(ctx, collection); err != nil {
+		return err
+	}
+
+	command := bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "hidden", Value: hidden}}},
+	}
+	if err := RunCommand(ctx, db, command); err != nil {
+		return fmt.Errorf("migrate: set hidden=%t for index %q of %q: %w", hidden, name, collection, err)
+	}
+
+	return nil
+}
+
+// StagedIndexDrop returns pair of linked migrations implementing safe index removal.
+// Migration with hideVersion hides index and migration with dropVersion actually drops it,
+// so there is a window between deployments to check that nothing relies on the index.
+// Both migrations are reversible: index is unhidden or recreated as hidden from provided model.
+// Model must have a name set in options.
+func StagedIndexDrop(hideVersion, dropVersion uint64, collection string, model mongo.IndexModel) ([]Migration, error) {
+	if model.Options == nil || model.Options.Name == nil {
+		return nil, errors.New("migrate: staged index drop requires index name")
+	}
+	if hideVersion >= dropVersion {
+		return nil, fmt.Errorf("migrate: hide version %d must be less than drop version %d", hideVersion, dropVersion)
+	}
+	name := *model.Options.Name
+
+	hide := Migration{
+		Version:     hideVersion,
+		Description: fmt.Sprintf("hide index %s of %s", name, collection),
+		Declarative: true,
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := HideIndex(ctx, db, collection, name); err != nil {
+				return err
+			}
+			if state, ok := runFromContext(ctx); ok && state.dryRun == nil {
+				state.migrate.report(MsgIndexHidden, name, collection, dropVersion)
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return UnhideIndex(ctx, db, collection, name)
+		},
+	}
+
+	drop := Migration{
+		Version:     dropVersion,
+		Description: fmt.Sprintf("drop hidden index %s of %s", name, collection),
+		DependsOn:   []uint64{hideVersion},
+		Declarative: true,
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndex(ctx, db, collection, name)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := checkNamespace(ctx, collection); err != nil {
+				return err
+			}
+			opts := *model.Options
+			hidden := model
+			hidden.Options = opts.SetHidden(true)
+			_, err := db.Collection(collection).Indexes().CreateOne(ctx, hidden)
+			return err
+		},
+	}
+
+	return []Migration{hide, drop}, nil
+}
+
+// HiddenIndex describes index hidden from query planner and pending drop.
+type HiddenIndex struct {
+	Collection string
+	Name       string
+}
+
+// HiddenIndexes lists indexes of database currently hidden from query planner, i.e. pending drop.
+func (m *Migrate) HiddenIndexes(ctx context.Context) ([]HiddenIndex, error) {
+	collections, err := m.getCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hidden []HiddenIndex
+	for _, collection := range collections {
+		if !m.matchNamespace(collection.Name) {
+			continue
+		}
+
+		cursor, err := m.db.Collection(collection.Name).Indexes().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var specs []struct {
+			Name   string `bson:"name"`
+			Hidden bool   `bson:"hidden"`
+		}
+		if err := cursor.All(ctx, &specs); err != nil {
+			return nil, err
+		}
+
+		for _, spec := range specs {
+			if spec.Hidden {
+				hidden = append(hidden, HiddenIndex{Collection: collection.Name, Name: spec.Name})
+			}
+		}
+	}
+
+	return hidden, nil
+}
+
+const duplicateSampleSize = 10
+
+// ErrDuplicateKeys returned when unique index can not be created because of existing duplicates.
+var ErrDuplicateKeys = errors.New("migrate: duplicate keys for unique index")
+
+// DuplicateGroup describes documents sharing the same key of prospective unique index.
+type DuplicateGroup struct {
+	// Key contains values of indexed fields.
+	Key bson.D
+	// Count is a number of documents with such key.
+	Count int
+	// IDs contains sample of "_id" of such documents.
+	IDs []interface{}
+}
+
+// DuplicateResolver resolves duplicates, e.g. by removing or updating redundant documents.
+type DuplicateResolver func(ctx context.Context, coll *mongo.Collection, group DuplicateGroup) error
+
+// FindDuplicates aggregates documents of coll sharing the same values of keys fields.
+// Only documents matching filter are considered, pass partial filter expression of index here. Nil filter matches all documents.
+// At most limit groups are returned, non-positive limit means no limit.
+func FindDuplicates(ctx context.Context, coll *mongo.Collection, keys bson.D, filter interface{}, limit int) ([]DuplicateGroup, error) {
+	groupKey := make(bson.D, 0, len(keys))
+	for i, key := range keys {
+		groupKey = append(groupKey, bson.E{Key: fmt.Sprintf("k%d", i), Value: "$" + key.Key})
+	}
+
+	var pipeline mongo.Pipeline
+	if filter != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: groupKey},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+		}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "count", Value: 1},
+			{Key: "ids", Value: bson.D{{Key: "$slice", Value: bson.A{"$ids", duplicateSampleSize}}}},
+		}}},
+	)
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		ID    bson.D        `bson:"_id"`
+		Count int           `bson:"count"`
+		IDs   []interface{} `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	groups := make([]DuplicateGroup, 0, len(results))
+	for _, result := range results {
+		// fields missing in documents are omitted from group key, so restore field names by positions
+		values := make(map[string]interface{}, len(result.ID))
+		for _, e := range result.ID {
+			values[e.Key] = e.Value
+		}
+		key := make(bson.D, 0, len(keys))
+		for i := range keys {
+			key = append(key, bson.E{Key: keys[i].Key, Value: values[fmt.Sprintf("k%d", i)]})
+		}
+		groups = append(groups, DuplicateGroup{Key: key, Count: result.Count, IDs: result.IDs})
+	}
+
+	return groups, nil
+}
+
+// CreateUniqueIndex creates unique index described by model after checking for duplicates of indexed keys,
+// avoiding failed index builds on live databases.
+// If duplicates are found, they are passed to resolver one group at a time and check is repeated.
+// If resolver is nil, ErrDuplicateKeys with sample of conflicting keys is returned.
+// Model keys must be a bson.D.
+func CreateUniqueIndex(ctx context.Context, coll *mongo.Collection, model mongo.IndexModel, resolver DuplicateResolver) error {
+	if err := checkNamespace(ctx, coll.Name()); err != nil {
+		return err
+	}
+
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return fmt.Errorf("migrate: unique index keys must be bson.D, got %T", model.Keys)
+	}
+
+	idxOpts := options.Index()
+	if model.Options != nil {
+		opts := *model.Options
+		idxOpts = &opts
+	}
+	idxOpts.SetUnique(true)
+
+	groups, err := FindDuplicates(ctx, coll, keys, idxOpts.PartialFilterExpression, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(groups) > 0 {
+		if resolver == nil {
+			sample := groups
+			if len(sample) > duplicateSampleSize {
+				sample = sample[:duplicateSampleSize]
+			}
+			return fmt.Errorf("%w: %d conflicting keys in %q, sample: %v", ErrDuplicateKeys, len(groups), coll.Name(), sample)
+		}
+
+		for _, group := range groups {
+			if err := resolver(ctx, coll, group); err != nil {
+				return err
+			}
+		}
+
+		groups, err = FindDuplicates(ctx, coll, keys, idxOpts.PartialFilterExpression, 1)
+		if err != nil {
+			return err
+		}
+		if len(groups) > 0 {
+			return fmt.Errorf("%w: duplicates in %q remain after resolving, e.g. %v", ErrDuplicateKeys, coll.Name(), groups[0].Key)
+		}
+	}
+
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: idxOpts})
+	return err
+}
+
+// partialUniqueSuffix is appended to name of unique index to get name of its partial predecessor, see StagedUniqueIndex.
+const partialUniqueSuffix = "_partial"
+
+// UniqueStage is a stage of unique index rollout performed by StagedUniqueIndex.
+type UniqueStage int
+
+const (
+	// UniqueStagePending means that neither partial nor full unique index exists.
+	UniqueStagePending UniqueStage = iota
+
+	// UniqueStagePartial means that partial unique index covering only new documents exists.
+	UniqueStagePartial
+
+	// UniqueStageFull means that full unique index exists.
+	UniqueStageFull
+)
+
+func (s UniqueStage) String() string {
+	switch s {
+	case UniqueStagePartial:
+		return "partial"
+	case UniqueStageFull:
+		return "full"
+	default:
+		return "pending"
+	}
+}
+
+// UniqueRollout describes progress of unique index rollout, see StagedUniqueIndex.
+type UniqueRollout struct {
+	Stage UniqueStage
+
+	// Duplicates is a number of keys shared by several documents, they must be resolved before full index is built.
+	Duplicates int
+}
+
+// StagedUniqueIndex returns linked migrations introducing unique index on fields which currently have duplicates.
+// Migration with partialVersion builds unique index "<name>_partial" covering only documents newer than existing ones
+// (with greater "_id"), so new duplicates are rejected while legacy ones are kept. Migration with resolveVersion passes
+// legacy duplicates to resolver one group at a time. Migration with fullVersion builds full unique index described
+// by model instead of the partial one, duplicates of legacy keys inserted meanwhile are passed to resolver too.
+// Servers older than 5.0 don't allow two indexes with the same keys, so partial index is dropped before full one
+// is built: duplicates inserted during the build make migration fail and it may be repeated.
+// Documents "_id" must grow monotonically, e.g. be ObjectIDs.
+// Model must have a name set in options and keys of bson.D type. Resolved duplicates are not restored on rollback.
+func StagedUniqueIndex(partialVersion, resolveVersion, fullVersion uint64, collection string, model mongo.IndexModel,
+	resolver DuplicateResolver) ([]Migration, error) {
+	if model.Options == nil || model.Options.Name == nil {
+		return nil, errors.New("migrate: staged unique index requires index name")
+	}
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("migrate: unique index keys must be bson.D, got %T", model.Keys)
+	}
+	if partialVersion >= resolveVersion || resolveVersion >= fullVersion {
+		return nil, fmt.Errorf("migrate: versions %d, %d, %d of staged unique index must ascend",
+			partialVersion, resolveVersion, fullVersion)
+	}
+	if resolver == nil {
+		return nil, errors.New("migrate: staged unique index requires duplicate resolver")
+	}
+	name := *model.Options.Name
+	partialName := name + partialUniqueSuffix
+
+	partial := Migration{
+		Version:     partialVersion,
+		Description: fmt.Sprintf("create partial unique index %s of %s", partialName, collection),
+		Collections: []string{collection},
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createPartialUnique(ctx, db.Collection(collection), keys, partialName)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndex(ctx, db, collection, partialName)
+		},
+	}
+
+	resolve := Migration{
+		Version:     resolveVersion,
+		Description: fmt.Sprintf("resolve duplicates for unique index %s of %s", name, collection),
+		DependsOn:   []uint64{partialVersion},
+		Collections: []string{collection},
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			coll := db.Collection(collection)
+			if err := checkNamespace(ctx, collection); err != nil {
+				return err
+			}
+			groups, err := FindDuplicates(ctx, coll, keys, nil, 0)
+			if err != nil {
+				return err
+			}
+			for _, group := range groups {
+				if err := resolver(ctx, coll, group); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// resolved duplicates can not be restored
+		Down: func(context.Context, *mongo.Database) error { return nil },
+	}
+
+	full := Migration{
+		Version:     fullVersion,
+		Description: fmt.Sprintf("create unique index %s of %s", name, collection),
+		DependsOn:   []uint64{resolveVersion},
+		Collections: []string{collection},
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexIfExists(ctx, db, collection, partialName); err != nil {
+				return err
+			}
+			// new documents may duplicate keys of legacy ones, they're resolved as well
+			return CreateUniqueIndex(ctx, db.Collection(collection), model, resolver)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexIfExists(ctx, db, collection, name); err != nil {
+				return err
+			}
+			return createPartialUnique(ctx, db.Collection(collection), keys, partialName)
+		},
+	}
+
+	return []Migration{partial, resolve, full}, nil
+}
+
+// UniqueIndexRollout reports stage of unique index rollout performed by StagedUniqueIndex with provided model.
+func UniqueIndexRollout(ctx context.Context, coll *mongo.Collection, model mongo.IndexModel) (UniqueRollout, error) {
+	if model.Options == nil || model.Options.Name == nil {
+		return UniqueRollout{}, errors.New("migrate: staged unique index requires index name")
+	}
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return UniqueRollout{}, fmt.Errorf("migrate: unique index keys must be bson.D, got %T", model.Keys)
+	}
+	name := *model.Options.Name
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return UniqueRollout{}, err
+	}
+	var specs []struct {
+		Name string `bson:"name"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return UniqueRollout{}, err
+	}
+
+	var rollout UniqueRollout
+	for _, spec := range specs {
+		switch spec.Name {
+		case name:
+			// full index guarantees absence of duplicates
+			return UniqueRollout{Stage: UniqueStageFull}, nil
+		case name + partialUniqueSuffix:
+			rollout.Stage = UniqueStagePartial
+		}
+	}
+
+	groups, err := FindDuplicates(ctx, coll, keys, nil, 0)
+	if err != nil {
+		return UniqueRollout{}, err
+	}
+	rollout.Duplicates = len(groups)
+
+	return rollout, nil
+}
+
+// createPartialUnique creates unique index covering only documents with "_id" greater than existing ones.
+func createPartialUnique(ctx context.Context, coll *mongo.Collection, keys bson.D, name string) error {
+	if err := checkNamespace(ctx, coll.Name()); err != nil {
+		return err
+	}
+
+	var last struct {
+		ID interface{} `bson:"_id"`
+	}
+	err := coll.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetProjection(bson.D{{Key: "_id", Value: 1}})).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: true}}}}
+	if last.ID != nil {
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last.ID}}}}
+	}
+
+	opts := options.Index().SetName(name).SetUnique(true).SetPartialFilterExpression(filter)
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts}); err != nil {
+		return fmt.Errorf("migrate: create partial unique index %q of %q: %w", name, coll.Name(), err)
+	}
+	return nil
+}
+
+func dropIndex(ctx context.Context, db *mongo.Database, collection, name string) error {
+	if err := checkNamespace(ctx, collection); err != nil {
+		return err
+	}
+
+	return RunCommand(ctx, db, bson.D{{Key: "dropIndexes", Value: collection}, {Key: "index", Value: name}})
+}
+
+// indexNotFoundCode is a code of server error returned for drop of missing index.
+const indexNotFoundCode = 27
+
+// dropIndexIfExists drops index ignoring its absence, so interrupted migration may be repeated.
+func dropIndexIfExists(ctx context.Context, db *mongo.Database, collection, name string) error {
+	err := dropIndex(ctx, db, collection, name)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestStagedIndexDrop(t *testing.T) {
+	model := mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}}, Options: options.Index().SetName("a_1")}
+	migrations, err := StagedIndexDrop(1, 2, "test", model)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Errorf("Unexpected migrations: %v", migrations)
+		return
+	}
+	if len(migrations[1].DependsOn) != 1 || migrations[1].DependsOn[0] != 1 {
+		t.Errorf("Unexpected drop migration dependencies: %v", migrations[1].DependsOn)
+	}
+
+	if _, err := StagedIndexDrop(2, 1, "test", model); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+	if _, err := StagedIndexDrop(1, 2, "test", mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}}}); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+}
+
+func TestStagedUniqueIndex(t *testing.T) {
+	model := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName("email_1")}
+	resolver := func(context.Context, *mongo.Collection, DuplicateGroup) error { return nil }
+	migrations, err := StagedUniqueIndex(1, 2, 3, "users", model, resolver)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(migrations) != 3 || migrations[0].Version != 1 || migrations[1].Version != 2 || migrations[2].Version != 3 {
+		t.Errorf("Unexpected migrations: %v", migrations)
+		return
+	}
+	if err := ValidateMigrations(migrations); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := StagedUniqueIndex(1, 3, 2, "users", model, resolver); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+	if _, err := StagedUniqueIndex(1, 2, 3, "users", model, nil); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+	if _, err := StagedUniqueIndex(1, 2, 3, "users", mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}}, resolver); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+}
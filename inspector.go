@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Inspector observes migrations state of database without registered migrations, e.g. in monitoring services
+// and dashboards which do not link migrations code of application. It never writes to database.
+type Inspector struct {
+	m *Migrate
+}
+
+// NewInspector returns inspector of database with bookkeeping collections of default names.
+func NewInspector(db *mongo.Database) *Inspector {
+	m := NewMigrate(db)
+	m.versionStore = collectionVersions{m: m, readOnly: true}
+	return &Inspector{m: m}
+}
+
+// SetMigrationsCollection replaces name of collection with migrations history, see Migrate.SetMigrationsCollection.
+func (i *Inspector) SetMigrationsCollection(name string) {
+	i.m.SetMigrationsCollection(name)
+}
+
+// SetAuditCollection replaces name of collection with audit records, see Migrate.SetAuditCollection.
+func (i *Inspector) SetAuditCollection(name string) {
+	i.m.SetAuditCollection(name)
+}
+
+// SetCollectionAffixes renames bookkeeping collections, see Migrate.SetCollectionAffixes.
+func (i *Inspector) SetCollectionAffixes(prefix, suffix string) {
+	i.m.SetCollectionAffixes(prefix, suffix)
+}
+
+// SetVersionStore replaces storage of migrations history, see Migrate.SetVersionStore.
+// Nil store resets it to migrations collection.
+func (i *Inspector) SetVersionStore(store VersionStore) {
+	if store == nil {
+		store = collectionVersions{m: i.m, readOnly: true}
+	}
+	i.m.SetVersionStore(store)
+}
+
+// Version returns current database version and its description.
+func (i *Inspector) Version(ctx context.Context) (uint64, string, error) {
+	return i.m.Version(ctx)
+}
+
+// History returns all version records in order of recording, including dirty ones.
+func (i *Inspector) History(ctx context.Context) ([]VersionRecord, error) {
+	return i.m.Versions().List(ctx)
+}
+
+// Runs returns up to limit audit records of runs, the newest first, see Migrate.Runs.
+func (i *Inspector) Runs(ctx context.Context, limit int) ([]RunRecord, error) {
+	return i.m.Runs(ctx, limit)
+}
+
+// Skew returns difference between provided version, e.g. head version reported by deployed application,
+// and current database version. It is positive if database is behind and negative if it is ahead.
+func (i *Inspector) Skew(ctx context.Context, version uint64) (int64, error) {
+	current, _, err := i.m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(version) - int64(current), nil
+}
+
+// MetaStats reports footprint of bookkeeping collections, see Migrate.MetaStats.
+func (i *Inspector) MetaStats(ctx context.Context) ([]CollectionStats, error) {
+	return i.m.MetaStats(ctx)
+}
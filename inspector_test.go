@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInspectorSkew(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVersionStore()
+	if err := store.Append(ctx, VersionRecord{Version: 1}, VersionRecord{Version: 3, Description: "third"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	inspector := NewInspector(nil)
+	inspector.SetVersionStore(store)
+
+	version, description, err := inspector.Version(ctx)
+	if err != nil || version != 3 || description != "third" {
+		t.Errorf("Unexpected version: %d %s %v", version, description, err)
+		return
+	}
+	for head, expected := range map[uint64]int64{5: 2, 3: 0, 1: -2} {
+		skew, err := inspector.Skew(ctx, head)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if skew != expected {
+			t.Errorf("Unexpected skew for head %d: %d", head, skew)
+		}
+	}
+
+	history, err := inspector.History(ctx)
+	if err != nil || len(history) != 2 {
+		t.Errorf("Unexpected history: %+v %v", history, err)
+	}
+}
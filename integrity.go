@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const orphansSampleSize = 10
+
+// ErrOrphans returned when documents referencing missing documents are found.
+var ErrOrphans = errors.New("migrate: orphaned references found")
+
+// Reference describes a "foreign key" relation between collections.
+type Reference struct {
+	// Collection and LocalField point to referencing field.
+	Collection string
+	LocalField string
+
+	// Foreign and ForeignField point to referenced field. ForeignField is "_id" by default.
+	Foreign      string
+	ForeignField string
+}
+
+func (r Reference) String() string {
+	foreignField := r.ForeignField
+	if foreignField == "" {
+		foreignField = "_id"
+	}
+	return fmt.Sprintf("%s.%s -> %s.%s", r.Collection, r.LocalField, r.Foreign, foreignField)
+}
+
+// Orphan describes document referencing missing document.
+type Orphan struct {
+	ID    interface{} `bson:"_id"`
+	Value interface{} `bson:"value"`
+}
+
+// FindOrphans returns documents of ref.Collection which ref.LocalField value does not match any ref.ForeignField of ref.Foreign.
+// Documents without local field or with null value are not considered as orphans.
+// At most limit orphans are returned, non-positive limit means no limit.
+func FindOrphans(ctx context.Context, db *mongo.Database, ref Reference, limit int) ([]Orphan, error) {
+	if err := checkNamespace(ctx, ref.Collection, ref.Foreign); err != nil {
+		return nil, err
+	}
+
+	foreignField := ref.ForeignField
+	if foreignField == "" {
+		foreignField = "_id"
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: ref.LocalField, Value: bson.D{{Key: "$ne", Value: nil}}}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: ref.Foreign},
+			{Key: "localField", Value: ref.LocalField},
+			{Key: "foreignField", Value: foreignField},
+			{Key: "as", Value: "_referenced"},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "_referenced", Value: bson.A{}}}}},
+		{{Key: "$project", Value: bson.D{{Key: "value", Value: "$" + ref.LocalField}}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+
+	cursor, err := db.Collection(ref.Collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: find orphans of %s: %w", ref, err)
+	}
+
+	var orphans []Orphan
+	if err := cursor.All(ctx, &orphans); err != nil {
+		return nil, fmt.Errorf("migrate: find orphans of %s: %w", ref, err)
+	}
+
+	return orphans, nil
+}
+
+// CheckReferences returns ErrOrphans with sample of orphaned documents if any of refs is broken.
+// Use it as a preflight check in migrations which assume referential integrity.
+func CheckReferences(ctx context.Context, db *mongo.Database, refs ...Reference) error {
+	var broken []string
+	for _, ref := range refs {
+		orphans, err := FindOrphans(ctx, db, ref, orphansSampleSize)
+		if err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			continue
+		}
+
+		ids := make([]string, 0, len(orphans))
+		for _, orphan := range orphans {
+			ids = append(ids, fmt.Sprint(orphan.ID))
+		}
+		broken = append(broken, fmt.Sprintf("%s: [%s]", ref, strings.Join(ids, ", ")))
+	}
+
+	if len(broken) > 0 {
+		return fmt.Errorf("%w: %s", ErrOrphans, strings.Join(broken, "; "))
+	}
+	return nil
+}
+
+// ReferencesCheck returns tracked data-quality migration which fails if any of refs is broken.
+// Reverting it does nothing.
+func ReferencesCheck(version uint64, description string, refs ...Reference) Migration {
+	return Migration{
+		Version:     version,
+		Description: description,
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return CheckReferences(ctx, db, refs...)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	}
+}
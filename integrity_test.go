@@ -0,0 +1,14 @@
+package migrate
+
+import "testing"
+
+func TestReferenceString(t *testing.T) {
+	ref := Reference{Collection: "orders", LocalField: "user_id", Foreign: "users"}
+	if s := ref.String(); s != "orders.user_id -> users._id" {
+		t.Errorf("Unexpected reference string: %s", s)
+	}
+	ref.ForeignField = "login"
+	if s := ref.String(); s != "orders.user_id -> users.login" {
+		t.Errorf("Unexpected reference string: %s", s)
+	}
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sequentialVersionLimit separates sequential versions (1, 2, 3...) from timestamp ones (e.g. 20240102150405).
+const sequentialVersionLimit = 1_000_000
+
+// LintCode identifies kind of LintFinding.
+type LintCode string
+
+const (
+	// LintMissingDown reports reversible migration without "down" callback, see Migration.Irreversible.
+	LintMissingDown LintCode = "missing-down"
+
+	// LintIrreversibleDown reports irreversible migration with "down" callback.
+	LintIrreversibleDown LintCode = "irreversible-down"
+
+	// LintMissingDescription reports migration without description.
+	LintMissingDescription LintCode = "missing-description"
+
+	// LintVersionGap reports sequential version which does not follow previous one, e.g. lost migration file.
+	LintVersionGap LintCode = "version-gap"
+
+	// LintMixedVersions reports timestamp version following sequential ones or vice versa.
+	LintMixedVersions LintCode = "mixed-versions"
+
+	// LintDuplicateDependency reports dependency listed more than once.
+	LintDuplicateDependency LintCode = "duplicate-dependency"
+
+	// LintForbiddenDependency reports dependency which is forbidden in environment allowed for dependent migration,
+	// so dependent migration can't be applied there.
+	LintForbiddenDependency LintCode = "forbidden-dependency"
+
+	// LintUnverifiedDestructive reports destructive migration without verify callback.
+	LintUnverifiedDestructive LintCode = "unverified-destructive"
+)
+
+// LintFinding is a problem of migration set found by Lint.
+type LintFinding struct {
+	Code    LintCode
+	Version uint64
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%d: %s (%s)", f.Version, f.Message, f.Code)
+}
+
+// Lint performs static checks of migration set which point to likely mistakes rather than invalid
+// configuration (see ValidateMigrations): missing "down" callbacks of reversible migrations, missing descriptions,
+// suspicious version gaps, conflicting dependencies and destructive migrations without verify callback.
+// Findings are returned in versions order, so CI tests may fail on them or skip accepted codes.
+func Lint(migrations []Migration) []LintFinding {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	migrationSort(sorted)
+
+	byVersion := make(map[uint64]Migration, len(sorted))
+	for _, migration := range sorted {
+		byVersion[migration.Version] = migration
+	}
+
+	var findings []LintFinding
+	add := func(code LintCode, version uint64, format string, args ...interface{}) {
+		findings = append(findings, LintFinding{Code: code, Version: version, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, migration := range sorted {
+		switch {
+		case migration.Irreversible && migration.Down != nil:
+			add(LintIrreversibleDown, migration.Version, "irreversible migration has down callback")
+		case !migration.Irreversible && migration.Down == nil:
+			add(LintMissingDown, migration.Version, "down callback is not set, mark migration irreversible if intended")
+		}
+		if strings.TrimSpace(migration.Description) == "" {
+			add(LintMissingDescription, migration.Version, "description is empty")
+		}
+		if migration.Destructive && migration.Verify == nil {
+			add(LintUnverifiedDestructive, migration.Version, "destructive migration has no verify callback")
+		}
+
+		if i > 0 {
+			prev := sorted[i-1].Version
+			switch {
+			case (prev < sequentialVersionLimit) != (migration.Version < sequentialVersionLimit):
+				add(LintMixedVersions, migration.Version, "version style differs from previous version %d", prev)
+			case migration.Version < sequentialVersionLimit && migration.Version > prev+1:
+				add(LintVersionGap, migration.Version, "previous version is %d", prev)
+			}
+		}
+
+		seen := make(map[uint64]bool, len(migration.DependsOn))
+		for _, dep := range migration.DependsOn {
+			if seen[dep] {
+				add(LintDuplicateDependency, migration.Version, "dependency %d is listed more than once", dep)
+				continue
+			}
+			seen[dep] = true
+
+			depMigration, ok := byVersion[dep]
+			if !ok {
+				continue
+			}
+			for _, environment := range forbiddenOnly(depMigration.ForbiddenEnvironments, migration.ForbiddenEnvironments) {
+				add(LintForbiddenDependency, migration.Version, "dependency %d is forbidden in environment %q", dep, environment)
+			}
+		}
+	}
+
+	return findings
+}
+
+// forbiddenOnly returns environments of forbidden which are not listed in allowedFrom.
+func forbiddenOnly(forbidden, allowedFrom []string) []string {
+	var envs []string
+	for _, environment := range forbidden {
+		listed := false
+		for _, other := range allowedFrom {
+			if strings.EqualFold(environment, other) {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			envs = append(envs, environment)
+		}
+	}
+
+	return envs
+}
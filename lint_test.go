@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestLint(t *testing.T) {
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+	verify := func(ctx context.Context, db *mongo.Database) error { return nil }
+
+	findings := Lint([]Migration{
+		{Version: 4, Description: "wipe", Up: noop, Down: noop, Destructive: true, ForbiddenEnvironments: []string{"prod"}},
+		{Version: 1, Description: "init", Up: noop, Down: noop},
+		{Version: 2, Description: " ", Up: noop},
+		{Version: 5, Description: "drop", Up: noop, Irreversible: true, Destructive: true, Verify: verify, DependsOn: []uint64{4, 4}},
+		{Version: 20240102150405, Description: "seal", Up: noop, Down: noop, Irreversible: true},
+	})
+
+	expected := []LintFinding{
+		{Code: LintMissingDown, Version: 2},
+		{Code: LintMissingDescription, Version: 2},
+		{Code: LintUnverifiedDestructive, Version: 4},
+		{Code: LintVersionGap, Version: 4},
+		{Code: LintForbiddenDependency, Version: 5},
+		{Code: LintDuplicateDependency, Version: 5},
+		{Code: LintIrreversibleDown, Version: 20240102150405},
+		{Code: LintMixedVersions, Version: 20240102150405},
+	}
+	if len(findings) != len(expected) {
+		t.Errorf("Unexpected findings: %v", findings)
+		return
+	}
+	for i, finding := range findings {
+		if finding.Code != expected[i].Code || finding.Version != expected[i].Version || finding.Message == "" {
+			t.Errorf("Unexpected finding %d: %v", i, finding)
+		}
+	}
+}
+
+func TestLintClean(t *testing.T) {
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+
+	findings := Lint([]Migration{
+		{Version: 20240101000000, Description: "init", Up: noop, Down: noop},
+		{Version: 20240301000000, Description: "seal", Up: noop, Irreversible: true, DependsOn: []uint64{20240101000000}},
+	})
+	if len(findings) != 0 {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+}
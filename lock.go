@@ -0,0 +1,261 @@
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultLocksCollection = "migrations_lock"
+	lockPollInterval       = 500 * time.Millisecond
+	collectionLockPrefix   = "collection:"
+	runLockName            = "run"
+)
+
+// ErrLocked returned when lock is held by another process.
+var ErrLocked = errors.New("migrate: locked by another process")
+
+// ErrLockLost is a cause of run context cancellation when held lock can't be renewed before it expires,
+// see context.Cause.
+var ErrLockLost = errors.New("migrate: lock lost")
+
+// FaultLockRenewal consulted before each renewal of held locks. Injected error fails renewal,
+// so run is canceled with ErrLockLost before locks expire.
+const FaultLockRenewal FaultPoint = "lock-renewal"
+
+// lockRecord is a lease document. Lease is free if it does not exist or expired.
+type lockRecord struct {
+	Name      string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func newLockOwner() string {
+	host, _ := os.Hostname()
+	var suffix [8]byte
+	_, _ = rand.Read(suffix[:])
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(suffix[:]))
+}
+
+// SetLocksCollection replaces name of collection for storing locks.
+// By default, it is "migrations_lock".
+func (m *Migrate) SetLocksCollection(name string) {
+	m.locksCollection = name
+}
+
+// SetCollectionLocks enables advisory locks for collections declared by Migration.Collections.
+// Before migration is performed, locks for all its collections are acquired, waiting while they are held by others.
+// So separate migrators (e.g. different services or tracks sharing database) serialize migrations
+// touching the same collections while others run concurrently. Migrations of one run are still performed
+// one by one, locks don't make them concurrent. Locks are leases for ttl which are renewed while migration runs.
+// Zero ttl disables collection locks.
+func (m *Migrate) SetCollectionLocks(ttl time.Duration) {
+	m.collectionLockTTL = ttl
+}
+
+// SetRunLock enables lock which prevents concurrent runs of Up and Down by different processes,
+// e.g. replicas of service starting at the same time. Lock is a lease for ttl renewed while run continues,
+// so lock of crashed process expires. If lease can't be renewed before it expires, run context is canceled
+// with ErrLockLost cause, so run stops before other process takes the lock. If wait is true, run waits for lock release,
+// otherwise ErrLocked is returned, so caller may skip migrations. Zero ttl disables run lock.
+func (m *Migrate) SetRunLock(ttl time.Duration, wait bool) {
+	m.runLockTTL = ttl
+	m.runLockWait = wait
+}
+
+// acquireLease takes lease for owner or prolongs own one. It returns false if lease is held by other owner.
+func (m *Migrate) acquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.D{
+		{Key: "_id", Value: name},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "owner", Value: owner}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "expires_at", Value: now.Add(ttl)},
+	}}}
+
+	_, err := m.db.Collection(m.locksCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		// lease exists and held by other owner, so upsert tried to insert document with the same id
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("migrate: acquire lock %q: %w", name, err)
+	}
+
+	return true, nil
+}
+
+func (m *Migrate) releaseLease(ctx context.Context, name, owner string) error {
+	filter := bson.D{{Key: "_id", Value: name}, {Key: "owner", Value: owner}}
+	if _, err := m.db.Collection(m.locksCollection).DeleteOne(ctx, filter); err != nil {
+		return fmt.Errorf("migrate: release lock %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// heldLocks is a set of leases renewed in background until released.
+// Each set has its own owner, so goroutines of one process holding the same lease serialize as well.
+type heldLocks struct {
+	m      *Migrate
+	owner  string
+	names  []string
+	cancel context.CancelCauseFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// holdLocks acquires leases in sorted order to avoid deadlocks between processes.
+// If wait is false and any of leases is held by other owner, ErrLocked is returned.
+// Returned context is canceled with ErrLockLost if any of leases can't be renewed before it expires.
+func (m *Migrate) holdLocks(ctx context.Context, names []string, ttl time.Duration, wait bool) (context.Context, *heldLocks, error) {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	held := &heldLocks{m: m, owner: newLockOwner(), stop: make(chan struct{})}
+	for _, name := range sorted {
+		for {
+			ok, err := m.acquireLease(ctx, name, held.owner, ttl)
+			if err == nil && !ok && !wait {
+				err = fmt.Errorf("%w: %q", ErrLocked, name)
+			}
+			if err != nil {
+				held.release(ctx)
+				return nil, nil, err
+			}
+			if ok {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				held.release(context.Background())
+				return nil, nil, ctx.Err()
+			case <-time.After(lockPollInterval):
+			}
+		}
+		held.names = append(held.names, name)
+	}
+
+	ctx, held.cancel = context.WithCancelCause(ctx)
+	held.wg.Add(1)
+	go held.renew(ttl, time.Now().Add(ttl))
+	return ctx, held, nil
+}
+
+// renew prolongs leases acquired before expires until released. Each attempt is bounded by expiration,
+// so hung request doesn't delay cancellation of run after leases expired.
+func (h *heldLocks) renew(ttl time.Duration, expires time.Time) {
+	defer h.wg.Done()
+
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = ttl
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+
+		renewed := time.Now()
+		failed := ""
+		err := h.m.injectFault(FaultLockRenewal, 0)
+		for _, name := range h.names {
+			if err != nil {
+				failed = name
+				break
+			}
+
+			renewCtx, cancel := context.WithDeadline(context.Background(), expires)
+			ok, renewErr := h.m.acquireLease(renewCtx, name, h.owner, ttl)
+			cancel()
+			if renewErr != nil || !ok {
+				h.m.report(MsgLockRenewalFailed, name, renewErr)
+				failed = name
+			}
+			if renewErr == nil && !ok {
+				// lease expired and was taken by other owner
+				expires = renewed
+			}
+		}
+		if failed == "" {
+			expires = renewed.Add(ttl)
+			continue
+		}
+
+		// stop run if lease may expire before the next attempt
+		if !renewed.Add(interval).Before(expires) {
+			lost := fmt.Errorf("%w: %q", ErrLockLost, failed)
+			if err != nil {
+				lost = fmt.Errorf("%w: %v", lost, err)
+			}
+			h.m.report(MsgLockRenewalStopped, lost)
+			h.cancel(lost)
+			return
+		}
+	}
+}
+
+// release stops renewal and removes leases. Errors are only logged: unreleased lease expires anyway.
+// Pass context which is not canceled yet, otherwise leases are left until expiration.
+func (h *heldLocks) release(ctx context.Context) {
+	select {
+	case <-h.stop:
+	default:
+		close(h.stop)
+	}
+	h.wg.Wait()
+	if h.cancel != nil {
+		h.cancel(nil)
+	}
+
+	for _, name := range h.names {
+		if err := h.m.releaseLease(ctx, name, h.owner); err != nil {
+			h.m.report(MsgLockReleaseFailed, err)
+		}
+	}
+}
+
+// lockRun acquires run lock if enabled. Returned context is canceled if lock is lost, see holdLocks.
+func (m *Migrate) lockRun(ctx context.Context) (context.Context, *heldLocks, error) {
+	if m.runLockTTL <= 0 {
+		return ctx, nil, nil
+	}
+
+	return m.holdLocks(ctx, []string{m.trackID(runLockName)}, m.runLockTTL, m.runLockWait)
+}
+
+// lockCollections acquires advisory locks for collections declared by migration if enabled.
+// Returned context is canceled if any of locks is lost, see holdLocks.
+func (m *Migrate) lockCollections(ctx context.Context, migration Migration) (context.Context, *heldLocks, error) {
+	if m.collectionLockTTL <= 0 || len(migration.Collections) == 0 {
+		return ctx, nil, nil
+	}
+
+	names := make([]string, 0, len(migration.Collections))
+	for _, collection := range migration.Collections {
+		names = append(names, collectionLockPrefix+collection)
+	}
+
+	return m.holdLocks(ctx, names, m.collectionLockTTL, true)
+}
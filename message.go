@@ -0,0 +1,272 @@
+package migrate
+
+import (
+	"fmt"
+	"time"
+)
+
+// messageTimeFormat is a format of time arguments in message texts.
+const messageTimeFormat = "2006-01-02 15:04:05"
+
+// MessageCode identifies kind of user-facing message. Codes are stable, so platforms may map messages
+// into their own UIs and runbooks instead of parsing text.
+type MessageCode string
+
+// Codes of messages reported by migrator. Comments list arguments of message in order.
+const (
+	// MsgMigratedUp reports applied migration: version, description.
+	MsgMigratedUp MessageCode = "migrated-up"
+	// MsgMigratedDown reports reverted migration: version, description.
+	MsgMigratedDown MessageCode = "migrated-down"
+	// MsgRollbackTruncated reports rollback truncated to fit max duration: reverted, planned.
+	MsgRollbackTruncated MessageCode = "rollback-truncated"
+	// MsgRevertRetry reports failed reversion which is retried: version, attempt, attempts, error.
+	MsgRevertRetry MessageCode = "revert-retry"
+	// MsgForced reports version forced without running migrations: version.
+	MsgForced MessageCode = "forced"
+	// MsgRepaired reports removed dirty state: version, description.
+	MsgRepaired MessageCode = "repaired"
+	// MsgSchemaUpgraded reports upgraded layout of bookkeeping collections: schema version, description.
+	MsgSchemaUpgraded MessageCode = "schema-upgraded"
+	// MsgSavepointSkipped reports savepoint passed by previous attempt: savepoint name, version.
+	MsgSavepointSkipped MessageCode = "savepoint-skipped"
+	// MsgSeedApplied reports applied seed: seed name.
+	MsgSeedApplied MessageCode = "seed-applied"
+	// MsgViewRefreshed reports materialized view rebuilt from scratch: view name, duration.
+	MsgViewRefreshed MessageCode = "view-refreshed"
+	// MsgIndexHidden reports index hidden by staged drop: index name, collection, drop version.
+	MsgIndexHidden MessageCode = "index-hidden"
+	// MsgCollectionScan reports critical query switched from index to collection scan: query name, collection, version.
+	MsgCollectionScan MessageCode = "collection-scan"
+	// MsgDatabaseBehind reports pending migrations: version, head version, head description, pending.
+	MsgDatabaseBehind MessageCode = "database-behind"
+	// MsgVersion reports database version: version, description, head version, pending.
+	MsgVersion MessageCode = "version"
+
+	// MsgRunPaused reports run paused by run control: version.
+	MsgRunPaused MessageCode = "run-paused"
+	// MsgRunResumed reports run resumed by run control: version.
+	MsgRunResumed MessageCode = "run-resumed"
+	// MsgPauseSent reports pause requested by PauseRun.
+	MsgPauseSent MessageCode = "pause-sent"
+	// MsgResumeSent reports resume requested by ResumeRun for paused run: waiter, version it continues from.
+	MsgResumeSent MessageCode = "resume-sent"
+	// MsgResumeSentIdle reports resume requested by ResumeRun while none of runs was paused.
+	MsgResumeSentIdle MessageCode = "resume-sent-idle"
+	// MsgPauseRequested reports requested pause: time of request.
+	MsgPauseRequested MessageCode = "pause-requested"
+	// MsgPauseWaiting reports run waiting for resume: time of request, waiter, version.
+	MsgPauseWaiting MessageCode = "pause-waiting"
+	// MsgRunInterrupted reports run stopped by signal: signal, time, version.
+	MsgRunInterrupted MessageCode = "run-interrupted"
+	// MsgSignalStopping reports the first received signal: signal.
+	MsgSignalStopping MessageCode = "signal-stopping"
+	// MsgSignalAborting reports the second received signal: signal.
+	MsgSignalAborting MessageCode = "signal-aborting"
+	// MsgFailoverResume reports run aborted by failover which is resumed: attempt, attempts, error.
+	MsgFailoverResume MessageCode = "failover-resume"
+	// MsgFailoverResuming reports version which resumed run starts from: version.
+	MsgFailoverResuming MessageCode = "failover-resuming"
+
+	// MsgAuditTimeFailed reports failed read of cluster time for audit record: error.
+	MsgAuditTimeFailed MessageCode = "audit-time-failed"
+	// MsgAuditFailed reports failed write of audit record of migration: version, error.
+	MsgAuditFailed MessageCode = "audit-failed"
+	// MsgRunAuditFailed reports failed write of audit record of run: error.
+	MsgRunAuditFailed MessageCode = "run-audit-failed"
+	// MsgProfilingFailed reports failed start of profiling: error.
+	MsgProfilingFailed MessageCode = "profiling-failed"
+	// MsgProfilingRestoreFailed reports failed restore of profiling level: error.
+	MsgProfilingRestoreFailed MessageCode = "profiling-restore-failed"
+	// MsgProfileReadFailed reports failed read of profiled operations: error.
+	MsgProfileReadFailed MessageCode = "profile-read-failed"
+	// MsgLockRenewalStopped reports stopped renewal of locks: error.
+	MsgLockRenewalStopped MessageCode = "lock-renewal-stopped"
+	// MsgLockRenewalFailed reports failed renewal of lock: lock name, error.
+	MsgLockRenewalFailed MessageCode = "lock-renewal-failed"
+	// MsgLockReleaseFailed reports failed release of lock: error.
+	MsgLockReleaseFailed MessageCode = "lock-release-failed"
+	// MsgControlFailed reports failed update of run control document: error.
+	MsgControlFailed MessageCode = "control-failed"
+	// MsgInterruptionFailed reports failed record of run interruption: error.
+	MsgInterruptionFailed MessageCode = "interruption-failed"
+
+	// Codes of messages printed by mongo-migrate tool. Rows of summary table separate columns by tabs.
+
+	// MsgNothingToMigrate reports empty plan of interactive run.
+	MsgNothingToMigrate MessageCode = "nothing-to-migrate"
+	// MsgPlan reports plan of interactive run: number of migrations.
+	MsgPlan MessageCode = "plan"
+	// MsgSummaryHeader is a header of summary table of interactive run.
+	MsgSummaryHeader MessageCode = "summary-header"
+	// MsgStepDone is a row of summary table for performed migration: version, duration, description.
+	MsgStepDone MessageCode = "step-done"
+	// MsgStepFailed is a row of summary table for failed migration: version, duration, description.
+	MsgStepFailed MessageCode = "step-failed"
+	// MsgStepSkipped is a row of summary table for not performed migration: version, description.
+	MsgStepSkipped MessageCode = "step-skipped"
+	// MsgTotal reports duration of interactive run: duration.
+	MsgTotal MessageCode = "total"
+	// MsgHiddenIndexes is a header of list of indexes hidden by staged drops.
+	MsgHiddenIndexes MessageCode = "hidden-indexes"
+	// MsgMaterializedViews is a header of list of materialized views with their freshness.
+	MsgMaterializedViews MessageCode = "materialized-views"
+	// MsgWatchReadFailed reports failed read of watched migration files: error.
+	MsgWatchReadFailed MessageCode = "watch-read-failed"
+	// MsgWatchLoadFailed reports failed load of watched migration files: error.
+	MsgWatchLoadFailed MessageCode = "watch-load-failed"
+	// MsgWatchRevertFailed reports failed revert of changed migrations: error.
+	MsgWatchRevertFailed MessageCode = "watch-revert-failed"
+	// MsgWatchApplyFailed reports failed apply of watched migrations: error.
+	MsgWatchApplyFailed MessageCode = "watch-apply-failed"
+	// MsgWatchReverting reports revert of changed migration: changed version, target version.
+	MsgWatchReverting MessageCode = "watch-reverting"
+	// MsgSchemasEqual reports empty schema diff, nothing is generated.
+	MsgSchemasEqual MessageCode = "schemas-equal"
+	// MsgFileCreated reports created file: path.
+	MsgFileCreated MessageCode = "file-created"
+	// MsgFileSkipped reports existing file kept untouched: path.
+	MsgFileSkipped MessageCode = "file-skipped"
+	// MsgUnknownCommand reports unknown command of the tool: command.
+	MsgUnknownCommand MessageCode = "unknown-command"
+	// MsgCommandFailed reports failed command of the tool: command, error.
+	MsgCommandFailed MessageCode = "command-failed"
+	// MsgUsage is a header of usage of the tool.
+	MsgUsage MessageCode = "usage"
+	// MsgUsageCommand is a line of usage describing command: command, description.
+	MsgUsageCommand MessageCode = "usage-command"
+	// MsgUsageExitCodes is a line of usage listing exit codes of the tool.
+	MsgUsageExitCodes MessageCode = "usage-exit-codes"
+)
+
+// messageFormats are default English formats of message texts.
+var messageFormats = map[MessageCode]string{
+	MsgMigratedUp:        "Migrated UP: %d %s",
+	MsgMigratedDown:      "Migrated DOWN: %d %s",
+	MsgRollbackTruncated: "Rollback truncated: %d of %d migrations reverted",
+	MsgRevertRetry:       "Revert of %d failed (attempt %d of %d): %v",
+	MsgForced:            "Forced version %d",
+	MsgRepaired:          "Repaired dirty migration %d %s",
+	MsgSchemaUpgraded:    "Upgraded bookkeeping schema to version %d: %s",
+	MsgSavepointSkipped:  "Savepoint %q of %d already passed, skipping",
+	MsgSeedApplied:       "Seed %q applied",
+	MsgViewRefreshed:     "Materialized view %q rebuilt in %s",
+	MsgIndexHidden:       "Index %q of %q hidden, it will be dropped by version %d",
+	MsgCollectionScan:    "Query %q on %q switched from index to collection scan after version %d",
+	MsgDatabaseBehind:    "Database is behind: version %d, head %d %s, %d pending migrations",
+	MsgVersion:           "%d %s (head %d, pending %d)",
+
+	MsgRunPaused:        "Run paused before migration %d",
+	MsgRunResumed:       "Run resumed before migration %d",
+	MsgPauseSent:        "Pause requested, runs stop before their next migration",
+	MsgResumeSent:       "Resume requested, %s continues from migration %d",
+	MsgResumeSentIdle:   "Resume requested, none of runs is paused",
+	MsgPauseRequested:   "Pause requested at %s",
+	MsgPauseWaiting:     "Pause requested at %s, %s waits before migration %d",
+	MsgRunInterrupted:   "Last run interrupted by %s at %s before migration %d",
+	MsgSignalStopping:   "Received %v, stopping after current migration",
+	MsgSignalAborting:   "Received %v again, aborting",
+	MsgFailoverResume:   "Run aborted by failover (resume %d of %d): %v",
+	MsgFailoverResuming: "Resuming run from recorded version %d",
+
+	MsgAuditTimeFailed:        "Failed to read cluster time for audit record: %v",
+	MsgAuditFailed:            "Failed to write audit record of migration %d: %v",
+	MsgRunAuditFailed:         "Failed to write audit record of run: %v",
+	MsgProfilingFailed:        "Failed to start profiling: %v",
+	MsgProfilingRestoreFailed: "Failed to restore profiling level: %v",
+	MsgProfileReadFailed:      "Failed to read profiled operations: %v",
+	MsgLockRenewalStopped:     "Locks renewal stopped: %v",
+	MsgLockRenewalFailed:      "Lock %q renewal failed: %v",
+	MsgLockReleaseFailed:      "Lock release failed: %v",
+	MsgControlFailed:          "Run control update failed: %v",
+	MsgInterruptionFailed:     "Record interruption failed: %v",
+
+	MsgNothingToMigrate:  "Nothing to migrate",
+	MsgPlan:              "Plan: %d migrations",
+	MsgSummaryHeader:     "VERSION\tRESULT\tDURATION\tDESCRIPTION",
+	MsgStepDone:          "%d\tdone\t%s\t%s",
+	MsgStepFailed:        "%d\tfailed\t%s\t%s",
+	MsgStepSkipped:       "%d\tskipped\t-\t%s",
+	MsgTotal:             "Total: %s",
+	MsgHiddenIndexes:     "Hidden indexes pending drop:",
+	MsgMaterializedViews: "Materialized views:",
+	MsgWatchReadFailed:   "Read migration files failed: %v",
+	MsgWatchLoadFailed:   "Load migrations failed: %v",
+	MsgWatchRevertFailed: "Revert changed migrations failed: %v",
+	MsgWatchApplyFailed:  "Apply migrations failed: %v",
+	MsgWatchReverting:    "Migration %d changed, reverting to %d",
+	MsgSchemasEqual:      "schemas are equal, nothing to generate",
+	MsgFileCreated:       "create %s",
+	MsgFileSkipped:       "skip %s: already exists",
+	MsgUnknownCommand:    "unknown command %q",
+	MsgCommandFailed:     "%s: %v",
+	MsgUsage:             "Usage: mongo-migrate <command> [flags]\nCommands:",
+	MsgUsageCommand:      "  %-10s %s",
+	MsgUsageExitCodes:    "Exit codes: 0 ok, 1 error, 2 dirty database, 3 pending migrations, 4 locked by another process",
+}
+
+// Message is a user-facing message of migrator.
+type Message struct {
+	Code MessageCode
+
+	// Format is a default English format of message text, Args are its arguments in order documented for Code.
+	Format string
+	Args   []any
+}
+
+// NewMessage returns message with provided code, its default format and args.
+func NewMessage(code MessageCode, args ...any) Message {
+	return Message{Code: code, Format: messageFormats[code], Args: args}
+}
+
+// String renders message text in English. Time arguments are rendered without time zone.
+func (msg Message) String() string {
+	args := make([]any, len(msg.Args))
+	for i, arg := range msg.Args {
+		if t, ok := arg.(time.Time); ok {
+			arg = t.Format(messageTimeFormat)
+		}
+		args[i] = arg
+	}
+
+	return fmt.Sprintf(msg.Format, args...)
+}
+
+// Localizer renders message text, e.g. in other language. Returning false falls back to English text.
+type Localizer func(msg Message) (text string, ok bool)
+
+// MessageHandler receives messages of migrator, e.g. to show them in UI.
+type MessageHandler func(msg Message)
+
+// SetMessageHandler sets handler which receives messages of migrator. Messages are passed to logger
+// set by SetLogger as well.
+func (m *Migrate) SetMessageHandler(handler MessageHandler) {
+	m.messageHandler = handler
+}
+
+// SetLocalizer sets localizer of message texts passed to logger, see Migrate.Text.
+func (m *Migrate) SetLocalizer(localizer Localizer) {
+	m.localizer = localizer
+}
+
+// Text renders message text using localizer, see SetLocalizer.
+func (m *Migrate) Text(msg Message) string {
+	if m.localizer != nil {
+		if text, ok := m.localizer(msg); ok {
+			return text
+		}
+	}
+
+	return msg.String()
+}
+
+// report passes message to message handler and logger.
+func (m *Migrate) report(code MessageCode, args ...any) {
+	msg := NewMessage(code, args...)
+	if m.messageHandler != nil {
+		m.messageHandler(msg)
+	}
+	if m.log != nil {
+		m.log.Printf("%s", m.Text(msg))
+	}
+}
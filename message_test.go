@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type linesLogger struct {
+	lines []string
+}
+
+func (l *linesLogger) Printf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestMessageFormats(t *testing.T) {
+	for code, format := range messageFormats {
+		if format == "" {
+			t.Errorf("Empty format of %q", code)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var (
+		log  linesLogger
+		msgs []Message
+	)
+	m := NewMigrate(nil)
+	m.SetLogger(&log)
+	m.SetMessageHandler(func(msg Message) {
+		msgs = append(msgs, msg)
+	})
+	m.SetLocalizer(func(msg Message) (string, bool) {
+		if msg.Code != MsgMigratedUp {
+			return "", false
+		}
+		return fmt.Sprintf("Migriert: %d", msg.Args[0]), true
+	})
+
+	m.report(MsgMigratedUp, uint64(1), "init")
+	m.report(MsgForced, uint64(2))
+	if len(msgs) != 2 || msgs[0].Code != MsgMigratedUp || msgs[0].Args[1] != "init" {
+		t.Errorf("Unexpected messages: %+v", msgs)
+		return
+	}
+	if len(log.lines) != 2 || log.lines[0] != "Migriert: 1" || log.lines[1] != "Forced version 2" {
+		t.Errorf("Unexpected log lines: %q", log.lines)
+	}
+}
+
+func TestRunControlMessages(t *testing.T) {
+	requested := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msgs := RunControlRecord{Paused: true, RequestedAt: requested, Waiter: "host:1", WaitingVersion: 3}.Messages()
+	if len(msgs) != 1 || msgs[0].Code != MsgPauseWaiting {
+		t.Errorf("Unexpected messages: %+v", msgs)
+		return
+	}
+	if text := msgs[0].String(); text != "Pause requested at 2024-01-02 03:04:05, host:1 waits before migration 3" {
+		t.Errorf("Unexpected text: %s", text)
+	}
+
+	if msgs := (RunControlRecord{}).Messages(); len(msgs) != 0 {
+		t.Errorf("Unexpected messages: %+v", msgs)
+	}
+}
+
+func TestMigrationState(t *testing.T) {
+	for status, expected := range map[MigrationStatus]MigrationState{
+		{}:                           StatePending,
+		{Applied: true}:              StateApplied,
+		{Missing: true}:              StateMissing,
+		{Applied: true, Dirty: true}: StateDirty,
+	} {
+		if state := status.State(); state != expected {
+			t.Errorf("Unexpected state of %+v: %s", status, state)
+		}
+	}
+}
+
+func TestMessageErrorArg(t *testing.T) {
+	msg := NewMessage(MsgLockRenewalFailed, "run", errors.New("timeout"))
+	if text := msg.String(); text != `Lock "run" renewal failed: timeout` {
+		t.Errorf("Unexpected text: %s", text)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -17,37 +21,107 @@ type collectionSpecification struct {
 	Type string `bson:"type"`
 }
 
-type versionRecord struct {
+// VersionRecord is a document of migrations collection. Each migration applying ("up" and "down") adds a new one.
+type VersionRecord struct {
 	Version     uint64    `bson:"version"`
 	Description string    `bson:"description,omitempty"`
 	Timestamp   time.Time `bson:"timestamp"`
+	Checksum    string    `bson:"checksum,omitempty"`
+
+	// Dirty is set for migration which is in progress or failed halfway, see DirtyVersion.
+	Dirty bool `bson:"dirty,omitempty"`
+
+	// OutOfOrder is set for migration applied after newer ones (see SetMissedMigrations),
+	// such record does not change current version.
+	OutOfOrder bool `bson:"out_of_order,omitempty"`
 }
 
 const defaultMigrationsCollection = "migrations"
 
+const defaultHistoryBatchSize = 1000
+
 // AllAvailable used in "Up" or "Down" methods to run all available migrations.
 const AllAvailable = -1
 
 // Migrate is type for performing migrations in provided database.
-// Database versioned using dedicated collection.
+// Database versioned using dedicated collection (other storage may be set by SetVersionStore).
 // Each migration applying ("up" and "down") adds new document to collection.
 // This document consists migration version, migration description and timestamp.
 // Current database version determined as version in latest added document (biggest "_id") from collection mentioned above.
 type Migrate struct {
-	db                   *mongo.Database
-	migrations           []Migration
-	migrationsCollection string
-	log                  Logger
+	db                    *mongo.Database
+	migrations            []Migration
+	migrationsCollection  string
+	checkpointsCollection string
+	locksCollection       string
+	viewsCollection       string
+	auditCollection       string
+	audit                 bool
+	transactions          bool
+	lockOwner             string
+	collectionLockTTL     time.Duration
+	runLockTTL            time.Duration
+	runLockWait           bool
+	runControl            bool
+	hooks                 Hooks
+	structuredLog         StructuredLogger
+	secrets               SecretResolver
+	stopSignal            atomic.Pointer[os.Signal]
+	historyBatchSize      int
+	faultInjector         FaultInjector
+	criticalQueries       []CriticalQuery
+	queryPlanPolicy       QueryPlanPolicy
+	commandPolicy         CommandPolicy
+	bsonRegistry          *bsoncodec.Registry
+	bsonOptions           *options.BSONOptions
+	monitor               *commandMonitor
+	namespaceFilter       *NamespaceFilter
+	runInfo               RunInfo
+	contextValues         map[any]any
+	views                 []MaterializedView
+	seeds                 []Seed
+	pendingHandler        PendingHandler
+	missedMigrations      MissedMigrationsPolicy
+	failoverRetry         FailoverRetry
+	profileThreshold      time.Duration
+	profileLimit          int
+	storageLimit          StorageLimit
+	versionStore          VersionStore
+	idGenerator           IDGenerator
+	track                 string
+	log                   Logger
+	messageHandler        MessageHandler
+	localizer             Localizer
 }
 
 func NewMigrate(db *mongo.Database, migrations ...Migration) *Migrate {
 	internalMigrations := make([]Migration, len(migrations))
 	copy(internalMigrations, migrations)
 	return &Migrate{
-		db:                   db,
-		migrations:           internalMigrations,
-		migrationsCollection: defaultMigrationsCollection,
+		db:                    db,
+		migrations:            internalMigrations,
+		migrationsCollection:  defaultMigrationsCollection,
+		checkpointsCollection: defaultCheckpointsCollection,
+		locksCollection:       defaultLocksCollection,
+		viewsCollection:       defaultViewsCollection,
+		auditCollection:       defaultAuditCollection,
+		lockOwner:             newLockOwner(),
+		historyBatchSize:      defaultHistoryBatchSize,
+		monitor:               &commandMonitor{},
+	}
+}
+
+// NewMigrateChecked acts like NewMigrate but validates database and migrations eagerly (see ValidateMigrations),
+// so configuration mistakes are reported at startup rather than in the middle of run.
+func NewMigrateChecked(db *mongo.Database, migrations ...Migration) (*Migrate, error) {
+	if db == nil {
+		return nil, errors.New("migrate: database is not set")
+	}
+	if err := ValidateMigrations(migrations); err != nil {
+		return nil, err
 	}
+
+	return NewMigrate(db, migrations...), nil
 }
 
 // SetMigrationsCollection replaces name of collection for storing migration information.
@@ -56,6 +130,28 @@ func (m *Migrate) SetMigrationsCollection(name string) {
 	m.migrationsCollection = name
 }
 
+// SetCollectionAffixes renames all collections used by migrator for bookkeeping (migrations history, checkpoints,
+// locks, audit and materialized views metadata) to their default names surrounded by prefix and suffix,
+// e.g. prefix "billing_" gives "billing_migrations", "billing_migrations_lock" and so on.
+// It allows multiple applications sharing a database to keep bookkeeping separated.
+// Collection names set explicitly afterwards take precedence.
+func (m *Migrate) SetCollectionAffixes(prefix, suffix string) {
+	m.migrationsCollection = prefix + defaultMigrationsCollection + suffix
+	m.checkpointsCollection = prefix + defaultCheckpointsCollection + suffix
+	m.locksCollection = prefix + defaultLocksCollection + suffix
+	m.auditCollection = prefix + defaultAuditCollection + suffix
+	m.viewsCollection = prefix + defaultViewsCollection + suffix
+}
+
+// SetHistoryBatchSize sets how many version documents are written by one call of VersionStore.Append in SetVersions.
+// By default, it is 1000. Non-positive values reset it to default.
+func (m *Migrate) SetHistoryBatchSize(n int) {
+	if n <= 0 {
+		n = defaultHistoryBatchSize
+	}
+	m.historyBatchSize = n
+}
+
 func (m *Migrate) isCollectionExist(ctx context.Context, name string) (isExist bool, err error) {
 	collections, err := m.getCollections(ctx)
 	if err != nil {
@@ -70,23 +166,6 @@ func (m *Migrate) isCollectionExist(ctx context.Context, name string) (isExist b
 	return false, nil
 }
 
-func (m *Migrate) createCollectionIfNotExist(ctx context.Context, name string) error {
-	exist, err := m.isCollectionExist(ctx, name)
-	if err != nil {
-		return err
-	}
-	if exist {
-		return nil
-	}
-
-	command := bson.D{bson.E{Key: "create", Value: name}}
-	if err = m.db.RunCommand(ctx, command).Err(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (m *Migrate) getCollections(ctx context.Context) (collections []collectionSpecification, err error) {
 	cursor, err := m.db.ListCollections(ctx, bson.D{})
 	if err != nil {
@@ -128,43 +207,106 @@ func (m *Migrate) getCollections(ctx context.Context) (collections []collectionS
 
 // Version returns current database version and comment.
 func (m *Migrate) Version(ctx context.Context) (uint64, string, error) {
-	if err := m.createCollectionIfNotExist(ctx, m.migrationsCollection); err != nil {
+	rec, err := m.currentRecord(ctx)
+	if err != nil {
 		return 0, "", err
 	}
 
-	filter := bson.D{{}}
-	sort := bson.D{bson.E{Key: "_id", Value: -1}}
-	opts := options.FindOne().SetSort(sort)
+	return rec.Version, rec.Description, nil
+}
 
-	// find record with the greatest id (assuming it`s latest also)
-	result := m.db.Collection(m.migrationsCollection).FindOne(ctx, filter, opts)
-	err := result.Err()
-	switch {
-	case errors.Is(err, mongo.ErrNoDocuments):
-		return 0, "", nil
-	case err != nil:
-		return 0, "", err
+// VersionInfo describes current database version related to registered migrations.
+type VersionInfo struct {
+	// Current is the latest record of migrations collection. It is zero value if no migrations were applied.
+	Current VersionRecord
+
+	// Head is the newest registered version, HeadDescription is its description.
+	Head            uint64
+	HeadDescription string
+
+	// Pending is a number of registered migrations which will be performed by "Up" with AllAvailable.
+	Pending int
+}
+
+// Behind reports if database version is older than the newest registered one.
+func (v VersionInfo) Behind() bool {
+	return v.Pending > 0
+}
+
+// Message returns MsgVersion message describing database version.
+func (v VersionInfo) Message() Message {
+	return NewMessage(MsgVersion, v.Current.Version, v.Current.Description, v.Head, v.Pending)
+}
+
+// CurrentVersion returns full record of current database version together with registered head version.
+func (m *Migrate) CurrentVersion(ctx context.Context) (VersionInfo, error) {
+	rec, err := m.currentRecord(ctx)
+	if err != nil {
+		return VersionInfo{}, err
 	}
 
-	var rec versionRecord
-	if err := result.Decode(&rec); err != nil {
-		return 0, "", err
+	info := VersionInfo{Current: rec}
+	for _, migration := range m.migrations {
+		if migration.Version >= info.Head {
+			info.Head = migration.Version
+			info.HeadDescription = migration.Description
+		}
+		if migration.Version > rec.Version && migration.Up != nil {
+			info.Pending++
+		}
 	}
 
-	return rec.Version, rec.Description, nil
+	return info, nil
+}
+
+func (m *Migrate) currentRecord(ctx context.Context) (VersionRecord, error) {
+	return m.versions().Current(ctx)
 }
 
 // SetVersion forcibly changes database version to provided one.
 func (m *Migrate) SetVersion(ctx context.Context, version uint64, description string) error {
-	rec := versionRecord{
-		Version:     version,
+	return m.setVersion(ctx, Migration{Version: version, Description: description})
+}
+
+// setVersion records migration as current version together with its checksum.
+func (m *Migrate) setVersion(ctx context.Context, migration Migration) error {
+	return m.versions().Append(ctx, newVersionRecord(migration))
+}
+
+func newVersionRecord(migration Migration) VersionRecord {
+	return VersionRecord{
+		Version:     migration.Version,
 		Timestamp:   time.Now().UTC(),
-		Description: description,
+		Description: migration.Description,
+		Checksum:    migration.Checksum,
 	}
+}
 
-	_, err := m.db.Collection(m.migrationsCollection).InsertOne(ctx, rec)
-	if err != nil {
-		return err
+// SetVersions writes version documents for provided migrations in given order, so the last one becomes current version.
+// Documents are written using InsertMany in batches (see SetHistoryBatchSize), which makes it suitable
+// for importing history from other tools or baselining database with many versions at once.
+// "up" and "down" callbacks of provided migrations are not called.
+func (m *Migrate) SetVersions(ctx context.Context, migrations ...Migration) error {
+	now := time.Now().UTC()
+	for start := 0; start < len(migrations); start += m.historyBatchSize {
+		end := start + m.historyBatchSize
+		if end > len(migrations) {
+			end = len(migrations)
+		}
+
+		batch := make([]VersionRecord, 0, end-start)
+		for _, migration := range migrations[start:end] {
+			batch = append(batch, VersionRecord{
+				Version:     migration.Version,
+				Timestamp:   now,
+				Description: migration.Description,
+				Checksum:    migration.Checksum,
+			})
+		}
+
+		if err := m.versions().Append(ctx, batch...); err != nil {
+			return fmt.Errorf("migrate: write versions batch %d-%d: %w", start, end, err)
+		}
 	}
 
 	return nil
@@ -173,89 +315,430 @@ func (m *Migrate) SetVersion(ctx context.Context, version uint64, description st
 // Up performs "up" migrations to latest available version.
 // If n<=0 all "up" migrations with newer versions will be performed.
 // If n>0 only n migrations with newer version will be performed.
-func (m *Migrate) Up(ctx context.Context, n int) error {
+// Materialized views are refreshed after migrations, see SetMaterializedViews.
+// History is checked before run and drift of applied migrations fails it, see Validate.
+// Run aborted by primary election may be resumed automatically, see SetFailoverRetry.
+func (m *Migrate) Up(ctx context.Context, n int) (err error) {
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "up", N: n})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+	if err := m.upWithFailover(ctx, n, false); err != nil {
+		return err
+	}
+	return m.RefreshViews(ctx)
+}
+
+// up performs versioned "up" migrations of Up under already acquired run lock.
+// Replay allows dirty migration to be performed again, see SetFailoverRetry and ResumeDirty.
+func (m *Migrate) up(ctx context.Context, n int, replay bool) (applied int, err error) {
+	if err := m.UpgradeSchema(ctx); err != nil {
+		return 0, err
+	}
+	if !replay {
+		if err := m.checkDirty(ctx); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.Validate(ctx); err != nil {
+		return 0, err
+	}
+
 	currentVersion, _, err := m.Version(ctx)
 	if err != nil {
+		return 0, err
+	}
+	plan, err := m.upPlan(ctx, currentVersion, n)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkEnvironment(plan); err != nil {
+		return 0, err
+	}
+	for _, migration := range plan {
+		if err := m.applyUp(ctx, migration, migration.Version < currentVersion); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// applyUp applies migration. Out of order migration is older than current version, see SetMissedMigrations.
+func (m *Migrate) applyUp(ctx context.Context, migration Migration, outOfOrder bool) error {
+	// do not start migration if run was canceled between migrations
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if n <= 0 || n > len(m.migrations) {
-		n = len(m.migrations)
+	if err := m.checkInterrupted(ctx, migration.Version); err != nil {
+		return err
+	}
+	if err := m.awaitResume(ctx, migration.Version); err != nil {
+		return err
 	}
-	migrationSort(m.migrations)
 
-	for i, p := 0, 0; i < len(m.migrations) && p < n; i++ {
-		migration := m.migrations[i]
-		if migration.Version <= currentVersion || migration.Up == nil {
-			continue
+	return m.observe(ctx, migration, directionUp, func() error { return m.performUp(ctx, migration, outOfOrder) })
+}
+
+// performUp applies migration recording its version.
+func (m *Migrate) performUp(ctx context.Context, migration Migration, outOfOrder bool) error {
+	ctx, locks, err := m.lockCollections(ctx, migration)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+
+	if err := m.checkStorage(ctx, migration); err != nil {
+		return err
+	}
+	plans, err := m.queryPlans(ctx)
+	if err != nil {
+		return err
+	}
+
+	var before primitive.Timestamp
+	if migration.Verify != nil {
+		if before, err = m.clusterTime(ctx); err != nil {
+			return err
 		}
-		p++
-		if err := migration.Up(ctx, m.db); err != nil {
+	}
+	dirty, err := m.markDirty(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	err = m.inTransaction(ctx, func(ctx context.Context) error {
+		if err := m.call(ctx, migration, migration.Up, directionUp); err != nil {
 			return err
 		}
-		if err := m.SetVersion(ctx, migration.Version, migration.Description); err != nil {
+		if migration.Verify != nil {
+			verifyCtx := m.runContext(ctx, migration, directionUp)
+			state, _ := runFromContext(verifyCtx)
+			state.before = before
+			if err := m.verify(verifyCtx, migration); err != nil {
+				return err
+			}
+		}
+		if err := m.injectFault(FaultAfterUp, migration.Version); err != nil {
 			return err
 		}
-
-		m.printUp(migration.Version, migration.Description)
+		rec := newVersionRecord(migration)
+		rec.OutOfOrder = outOfOrder
+		if err := m.commitVersion(ctx, dirty, rec); err != nil {
+			return err
+		}
+		return m.clearCheckpoints(ctx, migration.Version, directionUp)
+	})
+	if err != nil {
+		return err
 	}
-	return nil
+
+	m.printUp(migration.Version, migration.Description)
+	return m.checkQueryPlans(ctx, migration.Version, plans)
 }
 
 // Down performs "down" migration to the oldest available version.
 // If n<=0 all "down" migrations with older version will be performed.
 // If n>0 only n migrations with older version will be performed.
 func (m *Migrate) Down(ctx context.Context, n int) error {
-	currentVersion, _, err := m.Version(ctx)
+	return m.DownWithOptions(ctx, DownOptions{N: n})
+}
+
+// DownOptions configures "down" migration performed by DownWithOptions.
+type DownOptions struct {
+	// N limits number of reverted migrations in the same way as n argument of Down.
+	N int
+
+	// MaxDuration limits duration of the whole rollback. Planned reversions are truncated
+	// to ones which are expected to complete in this window according to Migration.Estimate.
+	// Zero means no limit.
+	MaxDuration time.Duration
+
+	// DefaultEstimate is used for migrations without Estimate when MaxDuration is set.
+	DefaultEstimate time.Duration
+}
+
+// ErrPlanTruncated returned by DownWithOptions when not all requested migrations
+// were reverted because they would not fit into DownOptions.MaxDuration.
+var ErrPlanTruncated = errors.New("migrate: plan truncated to fit max duration")
+
+// DownWithOptions performs "down" migration like Down but allows to limit rollback duration.
+// Reversions which would overrun DownOptions.MaxDuration are not started, in this case
+// database stays at the version of the last successfully reverted migration and ErrPlanTruncated is returned.
+func (m *Migrate) DownWithOptions(ctx context.Context, opts DownOptions) (err error) {
+	started := time.Now()
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "down", N: opts.N})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
 	if err != nil {
 		return err
 	}
-	if n <= 0 || n > len(m.migrations) {
-		n = len(m.migrations)
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+	if err := m.UpgradeSchema(ctx); err != nil {
+		return err
+	}
+	if err := m.checkDirty(ctx); err != nil {
+		return err
+	}
+
+	currentVersion, _, err := m.Version(ctx)
+	if err != nil {
+		return err
 	}
 	migrationSort(m.migrations)
 
-	for i, p := len(m.migrations)-1, 0; i >= 0 && p < n; i-- {
+	plan := planDown(m.migrations, currentVersion, opts.N)
+	fits := len(plan)
+	if opts.MaxDuration > 0 {
+		fits = fitDuration(m.migrations, plan, opts.MaxDuration, opts.DefaultEstimate)
+	}
+	if err := m.checkEnvironment(m.migrationsAt(plan[:fits])); err != nil {
+		return err
+	}
+
+	for p, i := range plan[:fits] {
 		migration := m.migrations[i]
-		if migration.Version > currentVersion || migration.Down == nil {
-			continue
+		if opts.MaxDuration > 0 {
+			estimate := estimateOf(migration, opts.DefaultEstimate)
+			if time.Since(started)+estimate > opts.MaxDuration {
+				fits = p
+				break
+			}
 		}
-		p++
-		if err := migration.Down(ctx, m.db); err != nil {
+
+		if err := m.applyDown(ctx, i); err != nil {
 			return err
 		}
+	}
 
-		var prevMigration Migration
-		if i == 0 {
-			prevMigration = Migration{Version: 0}
-		} else {
-			prevMigration = m.migrations[i-1]
+	if fits < len(plan) {
+		m.report(MsgRollbackTruncated, fits, len(plan))
+		return fmt.Errorf("%w: %d of %d migrations reverted", ErrPlanTruncated, fits, len(plan))
+	}
+	return nil
+}
+
+// DownToOptions configures "down" migration performed by DownTo.
+type DownToOptions struct {
+	// Confirm is called before each reversion. Returning false stops rollback with ErrRollbackAborted.
+	Confirm func(ctx context.Context, migration Migration) (bool, error)
+
+	// Retries is a number of additional attempts for failed reversion.
+	Retries int
+
+	// RetryDelay is a pause between attempts.
+	RetryDelay time.Duration
+}
+
+// ErrRollbackAborted returned by DownTo when reversion was not confirmed.
+var ErrRollbackAborted = errors.New("migrate: rollback aborted")
+
+// DownTo reverts migrations one by one until database reaches target version.
+// Target must be 0 or one of registered versions and all migrations newer than target must have "down" callback.
+// Database version is recorded after each reversion, so interrupted rollback is resumed by calling DownTo again.
+func (m *Migrate) DownTo(ctx context.Context, target uint64, opts DownToOptions) (err error) {
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "down-to", Target: &target})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+	if err := m.UpgradeSchema(ctx); err != nil {
+		return err
+	}
+	if err := m.checkDirty(ctx); err != nil {
+		return err
+	}
+
+	return m.downTo(ctx, target, opts)
+}
+
+// MigrateTo migrates database to target version: newer migrations are reverted, older ones are applied.
+// Target must be 0 or one of registered versions, otherwise ErrUnknownVersion is returned.
+func (m *Migrate) MigrateTo(ctx context.Context, target uint64) (err error) {
+	ctx, finish := m.beginRun(ctx, RunRecord{Operation: "migrate-to", Target: &target})
+	defer func() { finish(err) }()
+
+	ctx, locks, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+
+	if target != 0 && !hasVersion(m.migrations, target) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+	if err := m.UpgradeSchema(ctx); err != nil {
+		return err
+	}
+	if err := m.checkDirty(ctx); err != nil {
+		return err
+	}
+	currentVersion, _, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if target < currentVersion {
+		return m.downTo(ctx, target, DownToOptions{})
+	}
+	if err := m.Validate(ctx); err != nil {
+		return err
+	}
+	migrationSort(m.migrations)
+
+	var plan []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > currentVersion && migration.Version <= target && migration.Up != nil {
+			plan = append(plan, migration)
 		}
-		if err := m.SetVersion(ctx, prevMigration.Version, prevMigration.Description); err != nil {
+	}
+	if err := m.checkEnvironment(plan); err != nil {
+		return err
+	}
+	for _, migration := range plan {
+		if err := m.applyUp(ctx, migration, false); err != nil {
 			return err
 		}
+	}
+	return m.RefreshViews(ctx)
+}
+
+func (m *Migrate) downTo(ctx context.Context, target uint64, opts DownToOptions) error {
+	currentVersion, _, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	migrationSort(m.migrations)
+
+	plan, err := planDownTo(m.migrations, currentVersion, target)
+	if err != nil {
+		return err
+	}
+	if err := m.checkEnvironment(m.migrationsAt(plan)); err != nil {
+		return err
+	}
+
+	for _, i := range plan {
+		migration := m.migrations[i]
+		if opts.Confirm != nil {
+			ok, err := opts.Confirm(ctx, migration)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%w: at version %d", ErrRollbackAborted, migration.Version)
+			}
+		}
 
-		m.printDown(migration.Version, migration.Description)
+		for attempt := 0; ; attempt++ {
+			err = m.applyDown(ctx, i)
+			if err == nil || attempt >= opts.Retries {
+				break
+			}
+
+			m.report(MsgRevertRetry, migration.Version, attempt+1, opts.Retries+1, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.RetryDelay):
+			}
+		}
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// applyDown reverts migration with provided index of sorted migrations list.
+func (m *Migrate) applyDown(ctx context.Context, i int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	migration := m.migrations[i]
+	if err := m.checkInterrupted(ctx, migration.Version); err != nil {
+		return err
+	}
+	if err := m.awaitResume(ctx, migration.Version); err != nil {
+		return err
+	}
+
+	return m.observe(ctx, migration, directionDown, func() error { return m.performDown(ctx, i) })
+}
+
+// performDown reverts migration with provided index of sorted migrations list recording previous version.
+func (m *Migrate) performDown(ctx context.Context, i int) error {
+	migration := m.migrations[i]
+	ctx, locks, err := m.lockCollections(ctx, migration)
+	if err != nil {
+		return err
+	}
+	if locks != nil {
+		defer locks.release(context.Background())
+	}
+
+	var prevMigration Migration
+	if i == 0 {
+		prevMigration = Migration{Version: 0}
+	} else {
+		prevMigration = m.migrations[i-1]
+	}
+	plans, err := m.queryPlans(ctx)
+	if err != nil {
+		return err
+	}
+	dirty, err := m.markDirty(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	err = m.inTransaction(ctx, func(ctx context.Context) error {
+		if err := m.call(ctx, migration, migration.Down, directionDown); err != nil {
+			return err
+		}
+		if err := m.injectFault(FaultAfterDown, migration.Version); err != nil {
+			return err
+		}
+		if err := m.commitVersion(ctx, dirty, newVersionRecord(prevMigration)); err != nil {
+			return err
+		}
+		return m.clearCheckpoints(ctx, migration.Version, directionDown)
+	})
+	if err != nil {
+		return err
+	}
+
+	m.printDown(migration.Version, migration.Description)
+	return m.checkQueryPlans(ctx, prevMigration.Version, plans)
+}
+
 // SetLogger sets a logger to print the migration process
 func (m *Migrate) SetLogger(log Logger) {
 	m.log = log
 }
 
 func (m *Migrate) printUp(migrationVersion uint64, migrationDescription string) {
-	m.printf("Migrated UP: %d %s", migrationVersion, migrationDescription)
+	m.report(MsgMigratedUp, migrationVersion, migrationDescription)
 }
 
 func (m *Migrate) printDown(migrationVersion uint64, migrationDescription string) {
-	m.printf("Migrated DOWN: %d %s", migrationVersion, migrationDescription)
-}
-
-func (m *Migrate) printf(msg string, args ...any) {
-	if m.log == nil {
-		return
-	}
-
-	m.log.Printf(msg, args...)
+	m.report(MsgMigratedDown, migrationVersion, migrationDescription)
 }
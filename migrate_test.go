@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSetCollectionAffixes(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCollectionAffixes("billing_", "_v2")
+	m.SetLocksCollection("billing_locks")
+
+	expected := []string{
+		"billing_migrations_v2",
+		"billing_migrations_checkpoints_v2",
+		"billing_locks",
+		"billing_migrations_audit_v2",
+		"billing_migrations_views_v2",
+	}
+	if actual := m.BookkeepingCollections(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected collections: %v", actual)
+	}
+	if !m.isBookkeeping("billing_migrations_v2") || m.isBookkeeping("migrations") {
+		t.Errorf("Unexpected bookkeeping check result")
+	}
+}
+
+func TestApplyCanceled(t *testing.T) {
+	called := false
+	fn := func(context.Context, *mongo.Database) error {
+		called = true
+		return nil
+	}
+	m := NewMigrate(nil, Migration{Version: 1, Up: fn, Down: fn})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.applyUp(ctx, m.migrations[0], false); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := m.applyDown(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if called {
+		t.Errorf("Migration unexpectedly called with canceled context")
+	}
+}
+
+func TestMigrateToUnknownVersion(t *testing.T) {
+	fn := func(context.Context, *mongo.Database) error { return nil }
+	m := NewMigrate(nil, Migration{Version: 1, Up: fn, Down: fn}, Migration{Version: 3, Up: fn, Down: fn})
+
+	if err := m.MigrateTo(context.Background(), 2); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package migratetest
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+const defaultShadowSampleSize = 1000
+
+// ShadowOptions configures ShadowCompare.
+type ShadowOptions struct {
+	// Seed fills fresh database with fixtures before migrations are applied.
+	Seed func(ctx context.Context, db *mongo.Database) error
+
+	// SampleSize limits number of compared documents per collection, taken in "_id" order. By default, it is 1000.
+	SampleSize int
+
+	// IgnoreIDs excludes "_id" field of documents from compared state.
+	IgnoreIDs bool
+
+	// Exclude lists collections which are not compared, e.g. collections bookkeeping runs of migrations.
+	// By default, bookkeeping collections of migrator are excluded (see Migrate.BookkeepingCollections),
+	// they contain timestamps which always differ.
+	Exclude []string
+}
+
+// ShadowCompare applies original and rewritten (e.g. squashed) migrations to two scratch databases returned by newDB
+// and fails test if resulting schema (collections and indexes) or data samples differ.
+// Run it before deleting original migration files to validate that rewritten migrations are faithful.
+func ShadowCompare(t testing.TB, newDB func(t testing.TB) *mongo.Database, original, rewritten []migrate.Migration, opts ShadowOptions) {
+	t.Helper()
+
+	ctx := context.Background()
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultShadowSampleSize
+	}
+	if opts.Exclude == nil {
+		opts.Exclude = migrate.NewMigrate(nil).BookkeepingCollections()
+	}
+
+	snapOpts := snapshotOptions{ignoreIDs: opts.IgnoreIDs, sampleSize: opts.SampleSize, exclude: map[string]bool{}}
+	for _, name := range opts.Exclude {
+		snapOpts.exclude[name] = true
+	}
+
+	expected, err := applyShadow(ctx, newDB(t), original, opts.Seed, snapOpts)
+	if err != nil {
+		t.Fatalf("Apply original migrations failed: %v", err)
+	}
+
+	actual, err := applyShadow(ctx, newDB(t), rewritten, opts.Seed, snapOpts)
+	if err != nil {
+		t.Fatalf("Apply rewritten migrations failed: %v", err)
+	}
+
+	if diff := diffStates(expected, actual); diff != "" {
+		t.Errorf("State after rewritten migrations differs from original: %s", diff)
+	}
+}
+
+func applyShadow(ctx context.Context, db *mongo.Database, migrations []migrate.Migration,
+	seed func(ctx context.Context, db *mongo.Database) error, opts snapshotOptions,
+) (map[string]string, error) {
+	if seed != nil {
+		if err := seed(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := migrate.NewMigrate(db, migrations...).Up(ctx, migrate.AllAvailable); err != nil {
+		return nil, err
+	}
+
+	return snapshot(ctx, db, opts)
+}
@@ -0,0 +1,140 @@
+// Package migratetest provides utilities for testing migrations.
+package migratetest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+const defaultShuffleRounds = 5
+
+// ShuffleOptions configures Shuffle.
+type ShuffleOptions struct {
+	// Rounds is a number of random orders checked in addition to versions order. By default, it is 5.
+	Rounds int
+
+	// Seed used to generate random orders. Failed orders are reported with this seed so they may be reproduced.
+	Seed int64
+
+	// IgnoreIDs excludes "_id" field of documents from compared state.
+	// Use it when migrations insert documents with generated ids.
+	IgnoreIDs bool
+}
+
+// Shuffle applies "up" migrations in versions order and in several random orders respecting Migration.DependsOn.
+// Each order is applied against a fresh database returned by newDB.
+// Test fails if resulting states (collections, indexes and documents) of databases differ,
+// which reveals hidden ordering assumptions between migrations.
+func Shuffle(t testing.TB, newDB func(t testing.TB) *mongo.Database, migrations []migrate.Migration, opts ShuffleOptions) {
+	t.Helper()
+
+	ctx := context.Background()
+	if opts.Rounds <= 0 {
+		opts.Rounds = defaultShuffleRounds
+	}
+
+	sorted := make([]migrate.Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	expected, err := applyOrder(ctx, newDB(t), sorted, opts)
+	if err != nil {
+		t.Fatalf("Apply in versions order failed: %v", err)
+	}
+
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	for round := 0; round < opts.Rounds; round++ {
+		order, err := randomOrder(sorted, rnd)
+		if err != nil {
+			t.Fatalf("Build random order failed: %v", err)
+		}
+
+		actual, err := applyOrder(ctx, newDB(t), order, opts)
+		if err != nil {
+			t.Errorf("Apply in order %v (seed %d, round %d) failed: %v", versions(order), opts.Seed, round, err)
+			continue
+		}
+
+		if diff := diffStates(expected, actual); diff != "" {
+			t.Errorf("State after order %v (seed %d, round %d) differs from versions order: %s",
+				versions(order), opts.Seed, round, diff)
+		}
+	}
+}
+
+func applyOrder(ctx context.Context, db *mongo.Database, order []migrate.Migration, opts ShuffleOptions) (map[string]string, error) {
+	for _, migration := range order {
+		if migration.Up == nil {
+			continue
+		}
+		if err := migration.Up(ctx, db); err != nil {
+			return nil, fmt.Errorf("migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return snapshot(ctx, db, snapshotOptions{ignoreIDs: opts.IgnoreIDs})
+}
+
+// randomOrder returns random topological order of migrations according to their dependencies.
+func randomOrder(migrations []migrate.Migration, rnd *rand.Rand) ([]migrate.Migration, error) {
+	byVersion := make(map[uint64]migrate.Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	pending := make(map[uint64]int, len(migrations))
+	dependents := make(map[uint64][]uint64)
+	for _, migration := range migrations {
+		for _, dep := range migration.DependsOn {
+			if _, ok := byVersion[dep]; !ok {
+				return nil, fmt.Errorf("migration %d depends on unknown version %d", migration.Version, dep)
+			}
+			pending[migration.Version]++
+			dependents[dep] = append(dependents[dep], migration.Version)
+		}
+	}
+
+	var ready []uint64
+	for _, migration := range migrations {
+		if pending[migration.Version] == 0 {
+			ready = append(ready, migration.Version)
+		}
+	}
+
+	order := make([]migrate.Migration, 0, len(migrations))
+	for len(ready) > 0 {
+		idx := rnd.Intn(len(ready))
+		version := ready[idx]
+		ready = append(ready[:idx], ready[idx+1:]...)
+		order = append(order, byVersion[version])
+
+		for _, dependent := range dependents[version] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(migrations) {
+		return nil, fmt.Errorf("dependency cycle between migrations")
+	}
+	return order, nil
+}
+
+func versions(migrations []migrate.Migration) []uint64 {
+	ret := make([]uint64, 0, len(migrations))
+	for _, migration := range migrations {
+		ret = append(ret, migration.Version)
+	}
+	return ret
+}
@@ -0,0 +1,103 @@
+//go:build integration
+
+package migratetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+var (
+	client *mongo.Client
+	dbName string
+)
+
+func TestMain(m *testing.M) {
+	addr, err := url.Parse(os.Getenv("MONGO_URL"))
+	if err != nil {
+		panic(err)
+	}
+	opt := options.Client().ApplyURI(addr.String())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	client, err = mongo.Connect(ctx, opt)
+	if err != nil {
+		panic(err)
+	}
+	dbName = strings.TrimLeft(addr.Path, "/")
+	os.Exit(m.Run())
+}
+
+func TestShuffle(t *testing.T) {
+	var n int
+	newDB := func(t testing.TB) *mongo.Database {
+		n++
+		db := client.Database(fmt.Sprintf("%s-shuffle-%d", dbName, n))
+		t.Cleanup(func() {
+			_ = db.Drop(context.Background())
+		})
+		return db
+	}
+
+	Shuffle(t, newDB, []migrate.Migration{
+		{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").InsertOne(ctx, bson.D{{Key: "a", Value: 1}})
+			return err
+		}},
+		{Version: 2, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").InsertOne(ctx, bson.D{{Key: "b", Value: 2}})
+			return err
+		}},
+		{Version: 3, DependsOn: []uint64{1}, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").UpdateMany(ctx, bson.D{{Key: "a", Value: 1}}, bson.D{{Key: "$set", Value: bson.D{{Key: "c", Value: 3}}}})
+			return err
+		}},
+	}, ShuffleOptions{IgnoreIDs: true})
+}
+
+func TestShadowCompare(t *testing.T) {
+	var n int
+	newDB := func(t testing.TB) *mongo.Database {
+		n++
+		db := client.Database(fmt.Sprintf("%s-shadow-%d", dbName, n))
+		t.Cleanup(func() {
+			_ = db.Drop(context.Background())
+		})
+		return db
+	}
+
+	original := []migrate.Migration{
+		{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").UpdateMany(ctx, bson.D{}, bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}})
+			return err
+		}},
+		{Version: 2, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").UpdateMany(ctx, bson.D{}, bson.D{{Key: "$inc", Value: bson.D{{Key: "a", Value: 1}}}})
+			return err
+		}},
+	}
+	squashed := []migrate.Migration{
+		{Version: 2, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").UpdateMany(ctx, bson.D{}, bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 2}}}})
+			return err
+		}},
+	}
+
+	ShadowCompare(t, newDB, original, squashed, ShadowOptions{
+		Seed: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("test").InsertMany(ctx, []interface{}{bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "_id", Value: 2}}})
+			return err
+		},
+	})
+}
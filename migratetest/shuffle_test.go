@@ -0,0 +1,56 @@
+package migratetest
+
+import (
+	"math/rand"
+	"testing"
+
+	migrate "github.com/xakep666/mongo-migrate"
+)
+
+func TestRandomOrder(t *testing.T) {
+	migrations := []migrate.Migration{
+		{Version: 1},
+		{Version: 2, DependsOn: []uint64{1}},
+		{Version: 3},
+		{Version: 4, DependsOn: []uint64{2, 3}},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		order, err := randomOrder(migrations, rnd)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if len(order) != len(migrations) {
+			t.Errorf("Unexpected order length: %v", len(order))
+			return
+		}
+		applied := map[uint64]bool{}
+		for _, migration := range order {
+			for _, dep := range migration.DependsOn {
+				if !applied[dep] {
+					t.Errorf("Migration %d ordered before its dependency %d", migration.Version, dep)
+					return
+				}
+			}
+			applied[migration.Version] = true
+		}
+	}
+}
+
+func TestRandomOrderCycle(t *testing.T) {
+	migrations := []migrate.Migration{
+		{Version: 1, DependsOn: []uint64{2}},
+		{Version: 2, DependsOn: []uint64{1}},
+	}
+	if _, err := randomOrder(migrations, rand.New(rand.NewSource(1))); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+
+	migrations = []migrate.Migration{
+		{Version: 1, DependsOn: []uint64{3}},
+	}
+	if _, err := randomOrder(migrations, rand.New(rand.NewSource(1))); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+}
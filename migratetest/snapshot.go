@@ -0,0 +1,143 @@
+package migratetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type snapshotOptions struct {
+	ignoreIDs  bool
+	sampleSize int
+	exclude    map[string]bool
+}
+
+// snapshot returns canonical representation of each collection contents keyed by collection name.
+func snapshot(ctx context.Context, db *mongo.Database, opts snapshotOptions) (map[string]string, error) {
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]string, len(names))
+	for _, name := range names {
+		if opts.exclude[name] {
+			continue
+		}
+
+		indexes, err := collectionIndexes(ctx, db.Collection(name))
+		if err != nil {
+			return nil, err
+		}
+
+		documents, err := collectionDocuments(ctx, db.Collection(name), opts)
+		if err != nil {
+			return nil, err
+		}
+
+		state[name] = fmt.Sprintf("indexes: %v, documents: %v", indexes, documents)
+	}
+	return state, nil
+}
+
+func collectionIndexes(ctx context.Context, coll *mongo.Collection) ([]string, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []bson.M
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		// these fields depend on database name and server version but not on applied migrations
+		delete(spec, "ns")
+		delete(spec, "v")
+
+		canonical, err := bson.MarshalExtJSON(spec, true, false)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, string(canonical))
+	}
+
+	sort.Strings(indexes)
+	return indexes, nil
+}
+
+func collectionDocuments(ctx context.Context, coll *mongo.Collection, opts snapshotOptions) ([]string, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if opts.sampleSize > 0 {
+		findOpts.SetLimit(int64(opts.sampleSize))
+	}
+
+	cursor, err := coll.Find(ctx, bson.D{}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	documents := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if opts.ignoreIDs {
+			doc = withoutID(doc)
+		}
+
+		canonical, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, string(canonical))
+	}
+
+	sort.Strings(documents)
+	return documents, nil
+}
+
+func withoutID(doc bson.D) bson.D {
+	ret := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != "_id" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func diffStates(expected, actual map[string]string) string {
+	names := make([]string, 0, len(expected)+len(actual))
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e, eok := expected[name]
+		a, aok := actual[name]
+		switch {
+		case !aok:
+			return fmt.Sprintf("collection %q is missing", name)
+		case !eok:
+			return fmt.Sprintf("unexpected collection %q", name)
+		case e != a:
+			return fmt.Sprintf("collection %q: expected %s, got %s", name, e, a)
+		}
+	}
+	return ""
+}
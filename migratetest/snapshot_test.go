@@ -0,0 +1,18 @@
+package migratetest
+
+import "testing"
+
+func TestDiffStates(t *testing.T) {
+	if diff := diffStates(map[string]string{"a": "1"}, map[string]string{"a": "1"}); diff != "" {
+		t.Errorf("Unexpected diff: %v", diff)
+	}
+	if diff := diffStates(map[string]string{"a": "1"}, map[string]string{"a": "2"}); diff == "" {
+		t.Errorf("Unexpected empty diff")
+	}
+	if diff := diffStates(map[string]string{"a": "1"}, map[string]string{}); diff == "" {
+		t.Errorf("Unexpected empty diff")
+	}
+	if diff := diffStates(map[string]string{}, map[string]string{"b": "1"}); diff == "" {
+		t.Errorf("Unexpected empty diff")
+	}
+}
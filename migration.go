@@ -2,9 +2,13 @@ package migrate
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // MigrationFunc used to define actions to be performed for a migration.
@@ -20,11 +24,61 @@ type MigrationFunc func(ctx context.Context, db *mongo.Database) error
 // - up: callback which will be called in "up" migration process
 //
 // - down: callback which will be called in "down" migration process for reverting changes
+//
+// - estimate: expected duration of migration, used to plan time-bounded runs
+//
+// - dependsOn: versions of migrations which must be applied before this one.
+// Regular runs always apply migrations in versions order, dependencies are used by tools which reorder migrations.
+//
+// - collections: names of collections touched by migration, used for advisory locks and ownership map
+//
+// - owner: team or service owning collections touched by migration, used for ownership map
+//
+// - verify: callback which will be called after "up" callback to check invariants, see AggregateBefore
+//
+// - verifyReadPreference: read preference of database passed to verify callback, e.g.
+// readpref.SecondaryPreferred(readpref.WithMaxStaleness(90*time.Second)) to offload primary.
+// Nil means read preference of migrator database. Transactions allow only primary reads.
+//
+// - readPreference: read preference of database passed to "up" and "down" callbacks, e.g. readpref.SecondaryPreferred()
+// for read-heavy analysis migrations. Writes always go to primary, as well as migrator bookkeeping.
+// Nil means read preference of migrator database. Transactions allow only primary reads.
+//
+// - declarative: "up" callback issues commands only via RunCommand, so it may be rendered by DryRun
+//
+// - checksum: fingerprint of migration content recorded when migration is applied, used to detect edits
+// of applied migrations (see Validate). File migrations get hash of "up" file, empty value disables the check.
+//
+// - growth: data which migration is expected to write, e.g. copy or backfill of collection.
+// It's checked against storage limit before migration starts, see SetStorageLimit.
+//
+// - forbiddenEnvironments: environments (see RunInfo.Environment) where migration must never run, e.g. "prod"
+// for test data wipe. Run which plans such migration fails with ErrForbiddenEnvironment before performing anything.
+// Migration with restrictions is refused also when environment is not set.
+//
+// - irreversible: migration can't be reverted by design, so missing "down" callback is intended, see Lint
+//
+// - destructive: migration drops or overwrites data, so it should have "verify" callback, see Lint
 type Migration struct {
 	Version     uint64
 	Description string
 	Up          MigrationFunc
 	Down        MigrationFunc
+	Estimate    time.Duration
+	DependsOn   []uint64
+	Collections []string
+	Owner       string
+	Verify      VerifyFunc
+	Declarative bool
+	Checksum    string
+	Growth      []StorageGrowth
+
+	ForbiddenEnvironments []string
+	ReadPreference        *readpref.ReadPref
+	VerifyReadPreference  *readpref.ReadPref
+
+	Irreversible bool
+	Destructive  bool
 }
 
 func migrationSort(migrations []Migration) {
@@ -41,3 +95,124 @@ func hasVersion(migrations []Migration, version uint64) bool {
 	}
 	return false
 }
+
+// ErrUnknownVersion returned when target version is not registered.
+var ErrUnknownVersion = errors.New("migrate: version is not registered")
+
+// ErrInvalidMigration returned by ValidateMigrations for misconfigured migration.
+var ErrInvalidMigration = errors.New("migrate: invalid migration")
+
+// ValidateMigrations checks migration set for configuration mistakes: reserved zero or duplicate versions,
+// missing callbacks, dependencies on unknown or newer migrations and invalid options.
+// All found problems are reported in one error.
+func ValidateMigrations(migrations []Migration) error {
+	var errs []error
+	invalid := func(version uint64, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w %d: %s", ErrInvalidMigration, version, fmt.Sprintf(format, args...)))
+	}
+
+	seen := make(map[uint64]bool, len(migrations))
+	for _, migration := range migrations {
+		switch {
+		case migration.Version == 0:
+			invalid(migration.Version, "version 0 is reserved for empty database")
+		case seen[migration.Version]:
+			invalid(migration.Version, "duplicate version")
+		}
+		seen[migration.Version] = true
+
+		if migration.Up == nil && migration.Down == nil {
+			invalid(migration.Version, "neither up nor down callback set")
+		}
+		if migration.Estimate < 0 {
+			invalid(migration.Version, "negative estimate %s", migration.Estimate)
+		}
+		for _, name := range migration.Collections {
+			if name == "" {
+				invalid(migration.Version, "empty collection name")
+			}
+		}
+		for _, growth := range migration.Growth {
+			if growth.Collection == "" {
+				invalid(migration.Version, "empty collection name of growth")
+			}
+		}
+		for _, name := range migration.ForbiddenEnvironments {
+			if name == "" {
+				invalid(migration.Version, "empty forbidden environment name")
+			}
+		}
+	}
+
+	for _, migration := range migrations {
+		for _, dep := range migration.DependsOn {
+			switch {
+			case dep >= migration.Version:
+				invalid(migration.Version, "depends on not older migration %d", dep)
+			case !seen[dep]:
+				invalid(migration.Version, "depends on unknown migration %d", dep)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// planDown returns indexes of sorted migrations which should be reverted in order of reverting.
+func planDown(migrations []Migration, currentVersion uint64, n int) []int {
+	if n <= 0 || n > len(migrations) {
+		n = len(migrations)
+	}
+
+	var plan []int
+	for i := len(migrations) - 1; i >= 0 && len(plan) < n; i-- {
+		migration := migrations[i]
+		if migration.Version > currentVersion || migration.Down == nil {
+			continue
+		}
+		plan = append(plan, i)
+	}
+	return plan
+}
+
+// planDownTo returns indexes of sorted migrations which should be reverted to reach target version.
+func planDownTo(migrations []Migration, currentVersion, target uint64) ([]int, error) {
+	if target != 0 && !hasVersion(migrations, target) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+	if target > currentVersion {
+		return nil, fmt.Errorf("migrate: target version %d is newer than current %d", target, currentVersion)
+	}
+
+	var plan []int
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version > currentVersion || migration.Version <= target {
+			continue
+		}
+		if migration.Down == nil {
+			return nil, fmt.Errorf("migrate: migration %d can not be reverted", migration.Version)
+		}
+		plan = append(plan, i)
+	}
+	return plan, nil
+}
+
+func estimateOf(migration Migration, defaultEstimate time.Duration) time.Duration {
+	if migration.Estimate > 0 {
+		return migration.Estimate
+	}
+	return defaultEstimate
+}
+
+// fitDuration returns how many first steps of plan are expected to complete in maxDuration.
+func fitDuration(migrations []Migration, plan []int, maxDuration, defaultEstimate time.Duration) int {
+	var total time.Duration
+	for p, i := range plan {
+		total += estimateOf(migrations[i], defaultEstimate)
+		if total > maxDuration {
+			return p
+		}
+	}
+	return len(plan)
+}
@@ -5,13 +5,16 @@ package migrate
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -628,3 +631,2145 @@ func TestMultipleDownMigration(t *testing.T) {
 		return
 	}
 }
+
+func TestSetVersions(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrate := NewMigrate(db)
+	migrate.SetHistoryBatchSize(2)
+	err := migrate.SetVersions(ctx,
+		Migration{Version: 1, Description: "one"},
+		Migration{Version: 2, Description: "two"},
+		Migration{Version: 3, Description: "three"},
+		Migration{Version: 4, Description: "four"},
+		Migration{Version: 5, Description: "five"},
+	)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, description, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 5 || description != "five" {
+		t.Errorf("Unexpected version/description %v %v", version, description)
+		return
+	}
+	count, err := db.Collection(defaultMigrationsCollection).CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 5 {
+		t.Errorf("Unexpected version documents count: %v", count)
+		return
+	}
+}
+
+func TestDownWithMaxDuration(t *testing.T) {
+	defer cleanup(db)
+	var cnt int
+	ctx := context.Background()
+	noop := func(ctx context.Context, db *mongo.Database) error {
+		return nil
+	}
+	down := func(ctx context.Context, db *mongo.Database) error {
+		cnt++
+		return nil
+	}
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: noop, Down: down, Estimate: time.Hour},
+		Migration{Version: 2, Description: "world", Up: noop, Down: down, Estimate: time.Minute},
+		Migration{Version: 3, Description: "next", Up: noop, Down: down, Estimate: time.Minute},
+	)
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	err := migrate.DownWithOptions(ctx, DownOptions{N: AllAvailable, MaxDuration: 10 * time.Minute})
+	if !errors.Is(err, ErrPlanTruncated) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, description, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 1 || description != "hello" {
+		t.Errorf("Unexpected version/description: %v %v", version, description)
+		return
+	}
+	if cnt != 2 {
+		t.Errorf("Unexpected revert call count: %v", cnt)
+		return
+	}
+}
+
+func TestUpMigrationWithInjectedFault(t *testing.T) {
+	defer cleanup(db)
+	expectedErr := errors.New("injected error")
+	ctx := context.Background()
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{"hello", "world"}})
+			if err != nil {
+				return err
+			}
+			return nil
+		}},
+	)
+	migrate.SetFaultInjector(FailAt(FaultAfterUp, 1, expectedErr))
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, _, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 0 {
+		t.Errorf("Unexpected version: %v", version)
+		return
+	}
+	result := db.Collection(testCollection).FindOne(ctx, bson.D{{"hello", "world"}})
+	if err := result.Err(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+}
+
+func TestUpMigrationSavepoints(t *testing.T) {
+	defer cleanup(db)
+	expectedErr := errors.New("normal error")
+	var steps []string
+	fail := true
+	ctx := context.Background()
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{"first", "second", "third"} {
+				name := name
+				err := Savepoint(ctx, name, func(ctx context.Context) error {
+					if name == "third" && fail {
+						return expectedErr
+					}
+					steps = append(steps, name)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	)
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	fail = false
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrDirty) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.ResumeDirty(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if strings.Join(steps, ",") != "first,second,third" {
+		t.Errorf("Unexpected performed steps: %v", steps)
+		return
+	}
+	count, err := db.Collection(defaultCheckpointsCollection).CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 0 {
+		t.Errorf("Unexpected checkpoints count: %v", count)
+		return
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	noop := func(ctx context.Context, db *mongo.Database) error {
+		return nil
+	}
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: noop},
+		Migration{Version: 2, Description: "world", Up: noop},
+		Migration{Version: 3, Description: "next", Up: noop},
+	)
+	if err := migrate.Up(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	info, err := migrate.CurrentVersion(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if info.Current.Version != 1 || info.Current.Description != "hello" || info.Current.Timestamp.IsZero() {
+		t.Errorf("Unexpected current version: %+v", info.Current)
+		return
+	}
+	if info.Head != 3 || info.HeadDescription != "next" || info.Pending != 2 || !info.Behind() {
+		t.Errorf("Unexpected version info: %+v", info)
+		return
+	}
+}
+
+func TestVersionsFromContext(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	var applied bool
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		}},
+		Migration{Version: 2, Description: "world", Up: func(ctx context.Context, db *mongo.Database) error {
+			versions, ok := VersionsFromContext(ctx)
+			if !ok {
+				return errors.New("versions not found in context")
+			}
+			var err error
+			_, applied, err = versions.Applied(ctx, 1)
+			return err
+		}},
+	)
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !applied {
+		t.Errorf("Version 1 unexpectedly not found in history")
+		return
+	}
+}
+
+func TestStagedIndexDropMigrations(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	model := mongo.IndexModel{Keys: bson.D{{"hello", 1}}, Options: options.Index().SetName("test_idx")}
+	if _, err := db.Collection(testCollection).Indexes().CreateOne(ctx, model); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrations, err := StagedIndexDrop(1, 2, testCollection, model)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrate := NewMigrate(db, migrations...)
+	if err := migrate.Up(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	hidden, err := migrate.HiddenIndexes(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(hidden) != 1 || hidden[0].Collection != testCollection || hidden[0].Name != "test_idx" {
+		t.Errorf("Unexpected hidden indexes: %v", hidden)
+		return
+	}
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	hidden, err = migrate.HiddenIndexes(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(hidden) != 0 {
+		t.Errorf("Unexpected hidden indexes: %v", hidden)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	hidden, err = migrate.HiddenIndexes(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(hidden) != 0 {
+		t.Errorf("Unexpected hidden indexes: %v", hidden)
+		return
+	}
+}
+
+func TestQueryPlanRegression(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: func(ctx context.Context, db *mongo.Database) error {
+			opt := options.Index().SetName("test_idx")
+			keys := bson.D{{"hello", 1}}
+			model := mongo.IndexModel{Keys: keys, Options: opt}
+			_, err := db.Collection(testCollection).Indexes().CreateOne(ctx, model)
+			return err
+		}, Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).Indexes().DropOne(ctx, "test_idx")
+			return err
+		}},
+	)
+	migrate.SetQueryPlanCheck(QueryPlanFail, CriticalQuery{
+		Name:       "by hello",
+		Collection: testCollection,
+		Filter:     bson.D{{"hello", "world"}},
+	})
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); !errors.Is(err, ErrQueryPlanRegression) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+}
+
+func TestQueryPlanAlreadyCollScan(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	if _, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{"hello", "world"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		}, Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		}},
+	)
+	migrate.SetQueryPlanCheck(QueryPlanFail, CriticalQuery{
+		Name:       "by hello",
+		Collection: testCollection,
+		Filter:     bson.D{{"hello", "world"}},
+	})
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+}
+
+func TestPreflightTransform(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	coll := db.Collection(testCollection)
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.D{{"_id", 1}, {"a", "short"}},
+		bson.D{{"_id", 2}, {"a", strings.Repeat("long", 100)}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	oversized, err := PreflightTransform(ctx, coll, nil, func(doc bson.Raw) (interface{}, error) {
+		a := doc.Lookup("a").StringValue()
+		return bson.D{{"_id", doc.Lookup("_id")}, {"a", a}, {"b", a}}, nil
+	}, PreflightOptions{MaxSize: 500})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(oversized) != 1 || oversized[0].ID.Int32() != 2 {
+		t.Errorf("Unexpected oversized documents: %v", oversized)
+		return
+	}
+}
+
+func TestCreateUniqueIndexWithDuplicates(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	coll := db.Collection(testCollection)
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.D{{"_id", 1}, {"hello", "world"}},
+		bson.D{{"_id", 2}, {"hello", "world"}},
+		bson.D{{"_id", 3}, {"hello", "there"}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	model := mongo.IndexModel{Keys: bson.D{{"hello", 1}}, Options: options.Index().SetName("test_idx")}
+	if err := CreateUniqueIndex(ctx, coll, model, nil); !errors.Is(err, ErrDuplicateKeys) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	err = CreateUniqueIndex(ctx, coll, model, func(ctx context.Context, coll *mongo.Collection, group DuplicateGroup) error {
+		if group.Count != 2 || len(group.IDs) != 2 {
+			return fmt.Errorf("unexpected group: %v", group)
+		}
+		_, err := coll.DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", group.IDs[1:]}}}})
+		return err
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	count, err := coll.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("Unexpected documents count: %v", count)
+		return
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	if _, err := db.Collection("users").InsertOne(ctx, bson.D{{"_id", 1}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	_, err := db.Collection("orders").InsertMany(ctx, []interface{}{
+		bson.D{{"_id", 10}, {"user_id", 1}},
+		bson.D{{"_id", 11}, {"user_id", 2}},
+		bson.D{{"_id", 12}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	ref := Reference{Collection: "orders", LocalField: "user_id", Foreign: "users"}
+	orphans, err := FindOrphans(ctx, db, ref, 0)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(orphans) != 1 || orphans[0].ID != int32(11) {
+		t.Errorf("Unexpected orphans: %v", orphans)
+		return
+	}
+	migrate := NewMigrate(db, ReferencesCheck(1, "check orders", ref))
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrOrphans) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+}
+
+func TestDownToWithConfirmation(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	noop := func(ctx context.Context, db *mongo.Database) error {
+		return nil
+	}
+	failures := 1
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Up: noop, Down: noop},
+		Migration{Version: 2, Description: "world", Up: noop, Down: noop},
+		Migration{Version: 3, Description: "next", Up: noop, Down: func(ctx context.Context, db *mongo.Database) error {
+			if failures > 0 {
+				failures--
+				return errors.New("temporary error")
+			}
+			return nil
+		}},
+	)
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	var confirmed []uint64
+	err := migrate.DownTo(ctx, 1, DownToOptions{
+		Retries: 1,
+		Confirm: func(ctx context.Context, migration Migration) (bool, error) {
+			confirmed = append(confirmed, migration.Version)
+			return migration.Version != 2, nil
+		},
+	})
+	if !errors.Is(err, ErrRollbackAborted) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, _, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 2 {
+		t.Errorf("Unexpected version: %v", version)
+		return
+	}
+	if err := migrate.DownTo(ctx, 1, DownToOptions{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, _, err = migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 1 || len(confirmed) != 2 {
+		t.Errorf("Unexpected version/confirmations: %v %v", version, confirmed)
+		return
+	}
+}
+
+func TestFileMigrations(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrations, err := NewFileMigrations(fstest.MapFS{
+		"1_create.up.json":   {Data: []byte(`{"create": "` + testCollection + `"}`)},
+		"1_create.down.json": {Data: []byte(`{"drop": "` + testCollection + `"}`)},
+	}, ".")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrate := NewMigrate(db, migrations...)
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	exist, err := migrate.isCollectionExist(ctx, testCollection)
+	if err != nil || !exist {
+		t.Errorf("Unexpected collection existence: %v %v", exist, err)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	exist, err = migrate.isCollectionExist(ctx, testCollection)
+	if err != nil || exist {
+		t.Errorf("Unexpected collection existence: %v %v", exist, err)
+		return
+	}
+}
+
+func TestNamespaceFilterForFileMigrations(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrations, err := NewFileMigrations(fstest.MapFS{
+		"1_create.up.json": {Data: []byte(`{"create": "vendor_cache"}`)},
+	}, ".")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrate := NewMigrate(db, migrations...)
+	if err := migrate.SetNamespaceFilter(NamespaceFilter{Exclude: []string{"vendor_*"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+}
+
+func TestMetaStats(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrate := NewMigrate(db)
+	if err := migrate.SetVersion(ctx, 1, "hello"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.SetVersion(ctx, 2, "world"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	stats, err := migrate.MetaStats(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(stats) != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+		return
+	}
+	if !stats[0].Exists || stats[0].Count != 2 || stats[0].Indexes != 1 || stats[0].Oldest.IsZero() || stats[0].Newest.Before(stats[0].Oldest) {
+		t.Errorf("Unexpected migrations collection stats: %+v", stats[0])
+	}
+	if stats[1].Exists {
+		t.Errorf("Unexpected checkpoints collection stats: %+v", stats[1])
+	}
+}
+
+func TestRunLockLost(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	var cause error
+	migrate := NewMigrate(db, Migration{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+		select {
+		case <-ctx.Done():
+			cause = context.Cause(ctx)
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}})
+	migrate.SetRunLock(300*time.Millisecond, false)
+	migrate.SetFaultInjector(FailAt(FaultLockRenewal, 0, errors.New("network")))
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !errors.Is(cause, ErrLockLost) {
+		t.Errorf("Unexpected cause: %v", cause)
+	}
+}
+
+func TestCollectionLocks(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	holder := NewMigrate(db)
+	_, locks, err := holder.holdLocks(ctx, []string{collectionLockPrefix + testCollection}, time.Minute, false)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if _, _, err := NewMigrate(db).holdLocks(ctx, []string{collectionLockPrefix + testCollection}, time.Minute, false); !errors.Is(err, ErrLocked) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	// the same migrator does not share lease between acquisitions
+	if _, _, err := holder.holdLocks(ctx, []string{collectionLockPrefix + testCollection}, time.Minute, false); !errors.Is(err, ErrLocked) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "hello", Collections: []string{testCollection}, Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{"hello", "world"}})
+			return err
+		}},
+	)
+	migrate.SetCollectionLocks(time.Minute)
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := migrate.Up(waitCtx, AllAvailable); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	locks.release(ctx)
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	count, err := db.Collection(defaultLocksCollection).CountDocuments(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$ne", Value: schemaRecordID}}}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 0 {
+		t.Errorf("Unexpected locks count: %v", count)
+		return
+	}
+}
+
+func TestVerifyPreserved(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	var before primitive.Timestamp
+	if err := db.RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Decode(&struct {
+		OperationTime *primitive.Timestamp `bson:"operationTime"`
+	}{&before}); err != nil || before.IsZero() {
+		t.Skip("snapshot reads are not supported")
+	}
+
+	if _, err := db.Collection(testCollection).InsertMany(ctx, []interface{}{
+		bson.D{{Key: "amount", Value: 10}},
+		bson.D{{Key: "amount", Value: 20}},
+	}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "split amounts",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).UpdateMany(ctx, bson.D{}, bson.D{{Key: "$set", Value: bson.D{{Key: "amount", Value: 15}}}})
+			return err
+		},
+		Down:   func(ctx context.Context, db *mongo.Database) error { return nil },
+		Verify: VerifySumPreserved(testCollection, "amount"),
+	}, Migration{
+		Version:     2,
+		Description: "lose document",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).DeleteOne(ctx, bson.D{})
+			return err
+		},
+		Down:   func(ctx context.Context, db *mongo.Database) error { return nil },
+		Verify: VerifyCountPreserved(testCollection, nil),
+	})
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, _, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 1 {
+		t.Errorf("Unexpected version: %v", version)
+	}
+}
+
+func TestConvertCapped(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	if err := db.CreateCollection(ctx, testCollection, options.CreateCollection().SetCapped(true).SetSizeInBytes(4096)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{Key: "n", Value: i}}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+	if _, err := db.Collection(testCollection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "n", Value: 1}}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := ConvertCapped(ctx, db, testCollection, CappedConversion{BatchSize: 3}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	var stats struct {
+		Capped bool  `bson:"capped"`
+		Count  int64 `bson:"count"`
+		Index  int   `bson:"nindexes"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "collStats", Value: testCollection}}).Decode(&stats); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if stats.Capped || stats.Count != 10 || stats.Index != 2 {
+		t.Errorf("Unexpected collection stats: %+v", stats)
+	}
+}
+
+func TestConvertCappedResume(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	// state of conversion interrupted between renames
+	backup, staging := testCollection+"_capped_backup", testCollection+"_converting"
+	if err := db.CreateCollection(ctx, backup, options.CreateCollection().SetCapped(true).SetSizeInBytes(4096)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if _, err := db.Collection(staging).InsertMany(ctx, []interface{}{bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := ConvertCapped(ctx, db, testCollection, CappedConversion{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	count, err := db.Collection(testCollection).CountDocuments(ctx, bson.D{})
+	if err != nil || count != 2 {
+		t.Errorf("Unexpected count: %d %v", count, err)
+		return
+	}
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{backup, staging}}}}})
+	if err != nil || len(names) != 0 {
+		t.Errorf("Unexpected collections left: %v %v", names, err)
+	}
+}
+
+func TestMaterializedViews(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	if _, err := db.Collection(testCollection).InsertMany(ctx, []interface{}{
+		bson.D{{Key: "customer", Value: "a"}, {Key: "amount", Value: 1}},
+		bson.D{{Key: "customer", Value: "a"}, {Key: "amount", Value: 2}},
+		bson.D{{Key: "customer", Value: "b"}, {Key: "amount", Value: 5}},
+	}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	migrate := NewMigrate(db)
+	migrate.SetMaterializedViews(MaterializedView{
+		Name:   "totals",
+		Source: testCollection,
+		Pipeline: mongo.Pipeline{{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$customer"},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+		}}}},
+		MaxAge: time.Hour,
+	})
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	count, err := db.Collection("totals").CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("Unexpected number of view documents: %d", count)
+	}
+
+	views, err := migrate.Views(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(views) != 1 || views[0].Name != "totals" || views[0].RefreshedAt.IsZero() {
+		t.Errorf("Unexpected views: %+v", views)
+		return
+	}
+
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	again, err := migrate.Views(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !again[0].RefreshedAt.Equal(views[0].RefreshedAt) {
+		t.Errorf("Fresh view unexpectedly refreshed: %+v", again)
+		return
+	}
+
+	// documents not produced by pipeline anymore are removed from view
+	if _, err := db.Collection(testCollection).DeleteMany(ctx, bson.D{{Key: "customer", Value: "b"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.RefreshView(ctx, migrate.views[0]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	count, err = db.Collection("totals").CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 1 {
+		t.Errorf("Unexpected number of view documents after refresh: %d", count)
+	}
+}
+
+func TestMaterializedViewOn(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	if _, err := db.Collection(testCollection).InsertMany(ctx, []interface{}{
+		bson.D{{Key: "customer", Value: "a"}, {Key: "amount", Value: 1}},
+		bson.D{{Key: "customer", Value: "b"}, {Key: "amount", Value: 5}},
+	}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	migrate := NewMigrate(db)
+	view := MaterializedView{
+		Name:   "totals",
+		Source: testCollection,
+		Pipeline: mongo.Pipeline{
+			{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$customer"}, {Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}}}}},
+			{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "customer", Value: "$_id"}, {Key: "total", Value: 1}}}},
+		},
+		On: []string{"customer"},
+	}
+	// the first refresh creates unique index, the next one copies it from target
+	for i := 0; i < 2; i++ {
+		if err := migrate.RefreshView(ctx, view); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	count, err := db.Collection("totals").CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("Unexpected number of view documents: %d", count)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"migrations/1_create_users.up.json": {Data: []byte(`{"create": "users"}`)},
+	}
+	migrations, err := NewFileMigrations(fsys, "migrations")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrations = append(migrations, Migration{
+		Version:     2,
+		Description: "custom",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return errors.New("must not be called")
+		},
+	})
+
+	migrate := NewMigrate(db, migrations...)
+	steps, err := migrate.DryRun(ctx, AllAvailable)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(steps) != 2 || len(steps[0].Commands) != 1 || steps[1].Declarative {
+		t.Errorf("Unexpected steps: %+v", steps)
+		return
+	}
+
+	exist, err := migrate.isCollectionExist(ctx, "users")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if exist {
+		t.Errorf("Dry run unexpectedly executed command")
+	}
+}
+
+func TestAuditCommandCapture(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	migrate := NewMigrate(nil, Migration{
+		Version:     1,
+		Description: "insert",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{Key: "hello", Value: "world"}})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return errors.New("irreversible")
+		},
+	})
+	migrate.SetAudit(true)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URL")).SetMonitor(migrate.CommandMonitor()))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+	migrate.db = client.Database(db.Name())
+
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); err == nil {
+		t.Errorf("Unexpected nil error")
+		return
+	}
+
+	records, err := migrate.AuditRecords(ctx, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(records) != 2 || records[0].Down || !records[1].Down || records[1].Error != "irreversible" {
+		t.Errorf("Unexpected audit records: %+v", records)
+		return
+	}
+	if len(records[0].Commands) != 1 || records[0].Commands[0].Name != "insert" ||
+		records[0].Commands[0].Namespace != db.Name()+"."+testCollection {
+		t.Errorf("Unexpected captured commands: %+v", records[0].Commands)
+	}
+	// standalone servers do not report cluster time
+	if records[0].EndClusterTime.Before(records[0].StartClusterTime) {
+		t.Errorf("Unexpected cluster times: %v - %v", records[0].StartClusterTime, records[0].EndClusterTime)
+	}
+}
+
+func TestRunLock(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	started, proceed := make(chan struct{}), make(chan struct{})
+	first := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "slow",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			close(started)
+			<-proceed
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+	first.SetRunLock(time.Minute, false)
+	second := NewMigrate(db, first.migrations...)
+	second.SetRunLock(time.Minute, false)
+
+	errs := make(chan error, 1)
+	go func() { errs <- first.Up(ctx, AllAvailable) }()
+	<-started
+	if err := second.Up(ctx, AllAvailable); !errors.Is(err, ErrLocked) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	close(proceed)
+	if err := <-errs; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := second.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error after lock release: %v", err)
+	}
+}
+
+func TestPacedDelete(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	coll := db.Collection(testCollection)
+	for i := 0; i < 7; i++ {
+		if _, err := coll.InsertOne(ctx, bson.D{{Key: "expired", Value: i < 5}}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	var batches int
+	deleted, err := PacedDelete(ctx, coll, bson.D{{Key: "expired", Value: true}}, PacedDeleteOptions{
+		BatchSize: 2,
+		Pause:     time.Millisecond,
+		Progress:  func(int64) { batches++ },
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if deleted != 5 || batches != 3 {
+		t.Errorf("Unexpected deleted count %d in %d batches", deleted, batches)
+	}
+
+	left, err := coll.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if left != 2 {
+		t.Errorf("Unexpected number of left documents: %d", left)
+	}
+}
+
+func TestTransactionalMigration(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "partial",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection(testCollection).InsertOne(ctx, bson.D{{Key: "hello", Value: "world"}}); err != nil {
+				return err
+			}
+			return errors.New("failed after insert")
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+	migrate.SetTransactions(true)
+	if err := migrate.checkTransactions(ctx); errors.Is(err, ErrTransactionsUnsupported) {
+		t.Skip("transactions are not supported")
+	}
+	// collections can't be created implicitly in transactions by old servers
+	if err := db.CreateCollection(ctx, testCollection); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := migrate.Up(ctx, AllAvailable); err == nil {
+		t.Errorf("Unexpected nil error")
+		return
+	}
+
+	count, err := db.Collection(testCollection).CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 0 {
+		t.Errorf("Partial changes were not rolled back")
+	}
+	version, _, err := migrate.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 0 {
+		t.Errorf("Unexpected version: %v", version)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	for _, name := range []string{testCollection, "copy"} {
+		if _, err := db.Collection(name).InsertMany(ctx, []interface{}{
+			bson.D{{Key: "amount", Value: 10}},
+			bson.D{{Key: "amount", Value: 2.5}},
+		}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	rec := Reconciliation{Source: testCollection, Target: "copy", SumFields: []string{"amount"}}
+	report, err := Reconcile(ctx, db, rec)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	expected, _ := primitive.ParseDecimal128("12.5")
+	if report.SourceCount != 2 || !decimalEqual(report.Sums[0].Source, expected) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if _, err := db.Collection("copy").UpdateOne(ctx, bson.D{}, bson.D{{Key: "$inc", Value: bson.D{{Key: "amount", Value: 1}}}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	migrate := NewMigrate(db, ReconciliationCheck(1, "reconcile copy", rec))
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrReconciliationMismatch) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	var applied []string
+	record := func(step string) MigrationFunc {
+		return func(ctx context.Context, db *mongo.Database) error {
+			applied = append(applied, step)
+			return nil
+		}
+	}
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "one", Up: record("up 1"), Down: record("down 1")},
+		Migration{Version: 2, Description: "two", Up: record("up 2"), Down: record("down 2")},
+		Migration{Version: 3, Description: "three", Up: record("up 3"), Down: record("down 3")},
+	)
+
+	for _, target := range []uint64{2, 3, 1, 1, 0} {
+		if err := migrate.MigrateTo(ctx, target); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		version, _, err := migrate.Version(ctx)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if version != target {
+			t.Errorf("Unexpected version %d, expected %d", version, target)
+			return
+		}
+	}
+
+	expected := "up 1, up 2, up 3, down 3, down 2, down 1"
+	if actual := strings.Join(applied, ", "); actual != expected {
+		t.Errorf("Unexpected applied migrations: %s", actual)
+	}
+}
+
+func TestStatusAndPlan(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	noop := func(context.Context, *mongo.Database) error { return nil }
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "one", Up: noop, Down: noop},
+		Migration{Version: 2, Description: "two", Up: noop, Down: noop},
+		Migration{Version: 3, Description: "three", Up: noop, Down: noop},
+	)
+	if err := migrate.Up(ctx, 2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	plan, err := migrate.Plan(ctx, AllAvailable)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(plan) != 1 || plan[0].Version != 3 {
+		t.Errorf("Unexpected plan: %+v", plan)
+		return
+	}
+
+	// version 2 becomes unknown for migrator
+	status, err := NewMigrate(db, migrate.migrations[0], migrate.migrations[2]).Status(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(status) != 3 {
+		t.Errorf("Unexpected status: %+v", status)
+		return
+	}
+	if !status[0].Applied || status[0].AppliedAt.IsZero() || status[0].Missing {
+		t.Errorf("Unexpected status of version 1: %+v", status[0])
+	}
+	if !status[1].Applied || !status[1].Missing || status[1].Description != "two" {
+		t.Errorf("Unexpected status of version 2: %+v", status[1])
+	}
+	if status[2].Applied || !status[2].AppliedAt.IsZero() {
+		t.Errorf("Unexpected status of version 3: %+v", status[2])
+	}
+}
+
+func TestRunControl(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	started := make(chan struct{})
+	noop := func(context.Context, *mongo.Database) error { return nil }
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Up: func(context.Context, *mongo.Database) error { close(started); return nil }, Down: noop},
+		Migration{Version: 2, Up: noop, Down: noop},
+	)
+	migrate.SetRunControl(true)
+
+	if err := migrate.PauseRun(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- migrate.Up(ctx, AllAvailable) }()
+
+	var control RunControlRecord
+	for i := 0; i < 20 && control.Waiter == ""; i++ {
+		time.Sleep(lockPollInterval)
+		var err error
+		if control, err = migrate.RunControl(ctx); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+	if !control.Paused || control.WaitingVersion != 1 {
+		t.Errorf("Unexpected run control: %+v", control)
+		return
+	}
+	select {
+	case <-started:
+		t.Errorf("Migration started while paused")
+		return
+	default:
+	}
+
+	if err := migrate.ResumeRun(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if control, _ = migrate.RunControl(ctx); control.Paused || control.Waiter != "" {
+		t.Errorf("Unexpected run control after resume: %+v", control)
+	}
+}
+
+func TestChecksumDrift(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	noop := func(context.Context, *mongo.Database) error { return nil }
+	migrations := []Migration{
+		{Version: 1, Description: "one", Up: noop, Down: noop, Checksum: "one"},
+		{Version: 2, Description: "two", Up: noop, Down: noop, Checksum: "two"},
+	}
+	if err := NewMigrate(db, migrations...).Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	edited := append([]Migration(nil), migrations...)
+	edited[0].Checksum = "edited"
+	edited = append(edited, Migration{Version: 3, Up: noop})
+	if err := NewMigrate(db, edited...).Up(ctx, AllAvailable); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := NewMigrate(db, migrations[1]).Validate(ctx); !errors.Is(err, ErrMissingMigration) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	version, _, err := NewMigrate(db).Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 2 {
+		t.Errorf("Unexpected version %d", version)
+	}
+}
+
+func TestDirtyState(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	expectedErr := errors.New("normal error")
+	noop := func(context.Context, *mongo.Database) error { return nil }
+	fail := true
+	migrate := NewMigrate(db,
+		Migration{Version: 1, Description: "one", Up: noop, Down: noop},
+		Migration{Version: 2, Description: "two", Up: func(context.Context, *mongo.Database) error {
+			if fail {
+				return expectedErr
+			}
+			return nil
+		}, Down: noop},
+	)
+
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	rec, dirty, err := migrate.DirtyVersion(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !dirty || rec.Version != 2 {
+		t.Errorf("Unexpected dirty state: %v %+v", dirty, rec)
+		return
+	}
+	if version, _, _ := migrate.Version(ctx); version != 1 {
+		t.Errorf("Unexpected version %d", version)
+		return
+	}
+
+	fail = false
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, ErrDirty) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	// migration without checkpoints can't be continued
+	if err := migrate.ResumeDirty(ctx, AllAvailable); !errors.Is(err, ErrDirty) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Repair(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if _, dirty, _ := migrate.DirtyVersion(ctx); dirty {
+		t.Errorf("Unexpected dirty state after successful run")
+		return
+	}
+	count, err := db.Collection(defaultMigrationsCollection).CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("Unexpected version documents count: %v", count)
+		return
+	}
+
+	if err := migrate.Force(ctx, 5); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Force(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version, _, _ := migrate.Version(ctx); version != 1 {
+		t.Errorf("Unexpected version after force %d", version)
+	}
+}
+
+func TestBatchResume(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	coll := db.Collection(testCollection)
+	for i := 0; i < 10; i++ {
+		if _, err := coll.InsertOne(ctx, bson.D{{Key: "_id", Value: i}, {Key: "n", Value: i}}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	expectedErr := errors.New("interrupted")
+	var transformed []int32
+	fail := true
+	migrate := NewMigrate(db, Migration{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := Batch(ctx, db.Collection(testCollection), nil, func(doc bson.Raw) (interface{}, error) {
+			id := doc.Lookup("_id").Int32()
+			if fail && id == 6 {
+				return nil, expectedErr
+			}
+			transformed = append(transformed, id)
+			return bson.D{{Key: "_id", Value: id}, {Key: "n", Value: id * 2}}, nil
+		}, BatchOptions{Size: 3, Parallelism: 1})
+		return err
+	}})
+
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	fail = false
+	if err := migrate.ResumeDirty(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	// the first two batches are not processed again
+	if len(transformed) != 10 {
+		t.Errorf("Unexpected transformed documents: %v", transformed)
+	}
+	count, err := coll.CountDocuments(ctx, bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$n", bson.D{{Key: "$multiply", Value: bson.A{"$_id", 2}}}}}}}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 10 {
+		t.Errorf("Unexpected number of transformed documents: %d", count)
+	}
+}
+
+func TestBatchFaultMidBatch(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+	coll := db.Collection(testCollection)
+	for i := 0; i < 6; i++ {
+		if _, err := coll.InsertOne(ctx, bson.D{{Key: "_id", Value: i}, {Key: "n", Value: i}}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+
+	var transformed []int32
+	migrate := NewMigrate(db, Migration{Version: 1, Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := Batch(ctx, db.Collection(testCollection), nil, func(doc bson.Raw) (interface{}, error) {
+			id := doc.Lookup("_id").Int32()
+			transformed = append(transformed, id)
+			return bson.D{{Key: "_id", Value: id}, {Key: "n", Value: id * 2}}, nil
+		}, BatchOptions{Size: 3, Parallelism: 1})
+		return err
+	}})
+
+	// the second batch is written but its checkpoint is not saved
+	expectedErr := errors.New("interrupted")
+	batches := 0
+	migrate.SetFaultInjector(func(point FaultPoint, version uint64) error {
+		if point != FaultMidBatch {
+			return nil
+		}
+		batches++
+		if batches == 2 {
+			return expectedErr
+		}
+		return nil
+	})
+	if err := migrate.Up(ctx, AllAvailable); !errors.Is(err, expectedErr) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.ResumeDirty(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	// the second batch is processed again
+	if len(transformed) != 9 {
+		t.Errorf("Unexpected transformed documents: %v", transformed)
+	}
+	count, err := coll.CountDocuments(ctx, bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$n", bson.D{{Key: "$multiply", Value: bson.A{"$_id", 2}}}}}}}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 6 {
+		t.Errorf("Unexpected number of transformed documents: %d", count)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "noop",
+		Up:          func(ctx context.Context, db *mongo.Database) error { return nil },
+		Down:        func(ctx context.Context, db *mongo.Database) error { return errors.New("irreversible") },
+	})
+	migrate.SetAudit(true)
+
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); err == nil {
+		t.Errorf("Unexpected nil error")
+		return
+	}
+
+	runs, err := migrate.Runs(ctx, 0)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(runs) != 2 || runs[0].Operation != "down" || runs[0].Error == "" ||
+		runs[1].Operation != "up" || runs[1].Error != "" || runs[1].FinishedAt.IsZero() {
+		t.Errorf("Unexpected runs: %+v", runs)
+		return
+	}
+	if runs[1].Config.Database != db.Name() || runs[1].Config.Head != 1 || !runs[1].Config.Audit {
+		t.Errorf("Unexpected config of run: %+v", runs[1].Config)
+	}
+
+	records, err := migrate.AuditRecords(ctx, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(records) != 2 || records[0].RunID != runs[1].ID || records[1].RunID != runs[0].ID {
+		t.Errorf("Unexpected audit records: %+v", records)
+	}
+}
+
+func TestRunsULID(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "noop",
+		Up:          func(ctx context.Context, db *mongo.Database) error { return nil },
+		Down:        func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+	migrate.SetAudit(true)
+	migrate.SetIDGenerator(NewULID)
+
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	runs, err := migrate.Runs(ctx, 0)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(runs) != 1 {
+		t.Errorf("Unexpected runs: %+v", runs)
+		return
+	}
+	if id, ok := runs[0].ID.(string); !ok || len(id) != 26 {
+		t.Errorf("Unexpected run id: %v", runs[0].ID)
+		return
+	}
+
+	records, err := migrate.AuditRecords(ctx, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(records) != 1 || records[0].RunID != runs[0].ID {
+		t.Errorf("Unexpected audit records: %+v", records)
+		return
+	}
+	if id, ok := records[0].ID.(string); !ok || len(id) != 26 {
+		t.Errorf("Unexpected audit record id: %v", records[0].ID)
+	}
+}
+
+func TestTracks(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+	core := NewMigrate(db,
+		Migration{Version: 1, Description: "core init", Up: noop, Down: noop},
+		Migration{Version: 2, Description: "core index", Up: noop, Down: noop},
+	)
+	core.SetAudit(true)
+	core.SetRunLock(time.Minute, false)
+	analytics := NewMigrate(db, Migration{Version: 1, Description: "analytics init", Up: noop, Down: noop})
+	analytics.SetTrack("analytics")
+	analytics.SetAudit(true)
+	analytics.SetRunLock(time.Minute, false)
+
+	if err := core.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := analytics.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	coreVersion, _, err := core.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	analyticsVersion, description, err := analytics.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if coreVersion != 2 || analyticsVersion != 1 || description != "analytics init" {
+		t.Errorf("Unexpected versions: core %d, analytics %d %s", coreVersion, analyticsVersion, description)
+		return
+	}
+
+	records, err := analytics.AuditRecords(ctx, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(records) != 1 || records[0].Track != "analytics" || records[0].Description != "analytics init" {
+		t.Errorf("Unexpected audit records: %+v", records)
+		return
+	}
+	runs, err := core.Runs(ctx, 0)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(runs) != 1 || runs[0].Track != "" {
+		t.Errorf("Unexpected runs: %+v", runs)
+	}
+}
+
+func TestCollectionVersionStore(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	history := db.Collection("admin_history")
+	defer history.Drop(ctx)
+
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "noop",
+		Up:          func(ctx context.Context, db *mongo.Database) error { return nil },
+		Down:        func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+	migrate.SetVersionStore(NewCollectionVersionStore(history))
+
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	count, err := history.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if count != 1 {
+		t.Errorf("Unexpected count of version records: %d", count)
+		return
+	}
+	exist, err := migrate.isCollectionExist(ctx, defaultMigrationsCollection)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if exist {
+		t.Errorf("Migrations collection unexpectedly created")
+	}
+}
+
+func TestUpgradeSchema(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	migrate := NewMigrate(db, Migration{
+		Version:     1,
+		Description: "noop",
+		Up:          func(ctx context.Context, db *mongo.Database) error { return nil },
+		Down:        func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+	if err := migrate.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	current, supported, err := migrate.SchemaVersion(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if current != supported || supported == 0 {
+		t.Errorf("Unexpected schema version: %d, supported %d", current, supported)
+		return
+	}
+	indexes, err := db.Collection(defaultMigrationsCollection).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(indexes) != 2 {
+		t.Errorf("Unexpected indexes of migrations collection: %+v", indexes)
+		return
+	}
+
+	_, err = db.Collection(defaultLocksCollection).UpdateByID(ctx, schemaRecordID,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "version", Value: supported + 1}}}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := migrate.Down(ctx, AllAvailable); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestVerifyRestore(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	migrations := []Migration{
+		{Version: 1, Description: "first", Up: func(ctx context.Context, db *mongo.Database) error { return nil }},
+		{Version: 2, Description: "second", Up: func(ctx context.Context, db *mongo.Database) error { return nil }},
+	}
+	if err := NewMigrate(db, migrations...).Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if err := NewMigrate(db, migrations...).VerifyRestore(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	// binary knows only the first migration, so restored database is too new for it
+	if err := NewMigrate(db, migrations[0]).VerifyRestore(ctx); !errors.Is(err, ErrMissingMigration) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMissedMigrations(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	applied := map[uint64]bool{}
+	migration := func(version uint64) Migration {
+		return Migration{Version: version, Description: "noop", Up: func(ctx context.Context, db *mongo.Database) error {
+			applied[version] = true
+			return nil
+		}}
+	}
+	if err := NewMigrate(db, migration(1), migration(3)).Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	// migration 2 came from merged branch
+	merged := NewMigrate(db, migration(1), migration(2), migration(3))
+	merged.SetMissedMigrations(MissedMigrationsFail)
+	if err := merged.Up(ctx, AllAvailable); !errors.Is(err, ErrMissedMigrations) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	merged.SetMissedMigrations(MissedMigrationsApply)
+	if err := merged.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !applied[2] {
+		t.Errorf("Missed migration was not applied")
+		return
+	}
+	version, _, err := merged.Version(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if version != 3 {
+		t.Errorf("Unexpected version: %d", version)
+	}
+}
+
+func TestInspector(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	inspector := NewInspector(db)
+	if version, _, err := inspector.Version(ctx); err != nil || version != 0 {
+		t.Errorf("Unexpected version: %d %v", version, err)
+		return
+	}
+	exist, err := NewMigrate(db).isCollectionExist(ctx, defaultMigrationsCollection)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if exist {
+		t.Errorf("Inspector unexpectedly created migrations collection")
+		return
+	}
+
+	if err := NewMigrate(db).SetVersion(ctx, 2, "second"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	skew, err := inspector.Skew(ctx, 3)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if skew != 1 {
+		t.Errorf("Unexpected skew: %d", skew)
+	}
+}
+
+func TestForbiddenEnvironment(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	applied := map[uint64]bool{}
+	migration := func(version uint64, forbidden ...string) Migration {
+		return Migration{Version: version, Description: "noop", ForbiddenEnvironments: forbidden,
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				applied[version] = true
+				return nil
+			}}
+	}
+	m := NewMigrate(db, migration(1), migration(2, "prod"))
+	m.SetRunInfo(RunInfo{Environment: "prod"})
+	if err := m.Up(ctx, AllAvailable); !errors.Is(err, ErrForbiddenEnvironment) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if applied[1] {
+		t.Errorf("Migration was applied in spite of forbidden one in plan")
+		return
+	}
+
+	if err := m.Up(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if !applied[1] {
+		t.Errorf("Allowed migration was not applied")
+	}
+}
+
+func TestApplyAll(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	m := NewMigrate(db, Migration{Version: 1, Description: "users", Up: func(ctx context.Context, db *mongo.Database) error {
+		return db.CreateCollection(ctx, "users")
+	}})
+	m.SetAudit(true)
+	m.SetRunInfo(RunInfo{Environment: "dev"})
+	m.SetSeeds(Seed{Name: "admin", Environments: []string{"dev"}, Apply: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("users").UpdateOne(ctx, bson.D{{Key: "_id", Value: "admin"}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "role", Value: "admin"}}}}, options.Update().SetUpsert(true))
+		return err
+	}})
+
+	plan, err := m.PlanAll(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(plan.Migrations) != 1 || len(plan.Seeds) != 1 {
+		t.Errorf("Unexpected plan: %+v", plan)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := m.ApplyAll(ctx); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+	}
+	count, err := db.Collection("users").CountDocuments(ctx, bson.D{})
+	if err != nil || count != 1 {
+		t.Errorf("Unexpected seeded documents: %d %v", count, err)
+		return
+	}
+	runs, err := m.Runs(ctx, 0)
+	if err != nil || len(runs) != 2 || runs[0].Operation != "apply-all" {
+		t.Errorf("Unexpected runs: %+v %v", runs, err)
+	}
+}
+
+func TestFailoverRetry(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	calls := map[uint64]int{}
+	migration := func(version uint64) Migration {
+		return Migration{Version: version, Description: "noop", Up: func(ctx context.Context, db *mongo.Database) error {
+			calls[version]++
+			return nil
+		}}
+	}
+	m := NewMigrate(db, migration(1), migration(2), migration(3))
+	stepDown := mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}
+	failed := false
+	m.SetFaultInjector(func(point FaultPoint, version uint64) error {
+		if point == FaultAfterUp && version == 2 && !failed {
+			failed = true
+			return stepDown
+		}
+		return nil
+	})
+	m.SetFailoverRetry(FailoverRetry{Attempts: 1, Wait: 10 * time.Second})
+
+	if err := m.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if calls[1] != 1 || calls[2] != 2 || calls[3] != 1 {
+		t.Errorf("Unexpected calls: %v", calls)
+		return
+	}
+	version, _, err := m.Version(ctx)
+	if err != nil || version != 3 {
+		t.Errorf("Unexpected version: %d %v", version, err)
+	}
+}
+
+func TestFailoverRetryCount(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	calls := map[uint64]int{}
+	migration := func(version uint64) Migration {
+		return Migration{Version: version, Description: "noop", Up: func(ctx context.Context, db *mongo.Database) error {
+			calls[version]++
+			return nil
+		}}
+	}
+	m := NewMigrate(db, migration(1), migration(2), migration(3), migration(4))
+	failed := false
+	m.SetFaultInjector(func(point FaultPoint, version uint64) error {
+		if point == FaultAfterUp && version == 2 && !failed {
+			failed = true
+			return mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}
+		}
+		return nil
+	})
+	m.SetFailoverRetry(FailoverRetry{Attempts: 1})
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if calls[1] != 1 || calls[2] != 2 || calls[3] != 0 {
+		t.Errorf("Unexpected calls: %v", calls)
+		return
+	}
+	version, _, err := m.Version(ctx)
+	if err != nil || version != 2 {
+		t.Errorf("Unexpected version: %d %v", version, err)
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	m := NewMigrate(db, Migration{Version: 1, Description: "fill", Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("profiled").InsertOne(ctx, bson.D{{Key: "a", Value: 1}})
+		return err
+	}})
+	m.SetAudit(true)
+	// zero slowms makes profiler record every operation
+	m.SetProfiling(time.Nanosecond, 5)
+	if err := m.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	records, err := m.AuditRecords(ctx, 1)
+	if err != nil || len(records) != 1 {
+		t.Errorf("Unexpected audit records: %+v %v", records, err)
+		return
+	}
+	ops := records[0].SlowOperations
+	if len(ops) == 0 || ops[0].Namespace != db.Name()+".profiled" {
+		t.Errorf("Unexpected slow operations: %+v", ops)
+		return
+	}
+
+	var level struct {
+		Was int32 `bson:"was"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "profile", Value: -1}}).Decode(&level); err != nil || level.Was != 0 {
+		t.Errorf("Profiling level was not restored: %d %v", level.Was, err)
+	}
+}
+
+func TestStorageLimit(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	docs := make([]interface{}, 0, 100)
+	for i := 0; i < 100; i++ {
+		docs = append(docs, bson.D{{Key: "payload", Value: strings.Repeat("x", 1000)}})
+	}
+	if _, err := db.Collection("source").InsertMany(ctx, docs); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	called := false
+	m := NewMigrate(db, Migration{Version: 1, Description: "copy source",
+		Growth: []StorageGrowth{{Collection: "source"}},
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			called = true
+			return nil
+		}})
+	m.SetStorageLimit(StorageLimit{MaxDataSize: 150 * 1000})
+	if err := m.Up(ctx, AllAvailable); !errors.Is(err, ErrStorageLimit) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if called {
+		t.Errorf("Migration started in spite of storage limit")
+		return
+	}
+
+	projection, err := ProjectStorage(ctx, db, StorageGrowth{Collection: "source", Filter: bson.D{}, Factor: 0.5})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if projection.Growth < 50*1000 || projection.Growth > 60*1000 {
+		t.Errorf("Unexpected growth: %d", projection.Growth)
+	}
+}
+
+func TestRemapValues(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	coll := db.Collection("orders")
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.D{{Key: "_id", Value: 1}, {Key: "status", Value: "N"}},
+		bson.D{{Key: "_id", Value: 2}, {Key: "status", Value: "P"}},
+		bson.D{{Key: "_id", Value: 3}, {Key: "status", Value: "X"}},
+		bson.D{{Key: "_id", Value: 4}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	mapping := map[string]string{"N": "new", "P": "paid"}
+	if _, err := RemapValues(ctx, coll, "status", mapping, RemapOptions[string]{}); !errors.Is(err, ErrUnmappedValue) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if n, _ := coll.CountDocuments(ctx, bson.D{{Key: "status", Value: "new"}}); n != 0 {
+		t.Errorf("Documents changed in spite of unmapped value")
+		return
+	}
+
+	res, err := RemapValues(ctx, coll, "status", mapping, RemapOptions[string]{Unmapped: UnmappedDefault, Default: "unknown"})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if res.Scanned != 3 || res.Modified != 3 {
+		t.Errorf("Unexpected result: %+v", res)
+		return
+	}
+	for id, expected := range map[int]string{1: "new", 2: "paid", 3: "unknown"} {
+		var doc struct {
+			Status string `bson:"status"`
+		}
+		if err := coll.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc); err != nil || doc.Status != expected {
+			t.Errorf("Unexpected status of %d: %s %v", id, doc.Status, err)
+		}
+	}
+}
+
+func TestStagedUniqueIndexMigrations(t *testing.T) {
+	defer cleanup(db)
+	ctx := context.Background()
+
+	coll := db.Collection(testCollection)
+	_, err := coll.InsertMany(ctx, []interface{}{
+		bson.D{{Key: "email", Value: "a@example.com"}},
+		bson.D{{Key: "email", Value: "a@example.com"}},
+		bson.D{{Key: "email", Value: "b@example.com"}},
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	model := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName("email_1")}
+	keepFirst := func(ctx context.Context, coll *mongo.Collection, group DuplicateGroup) error {
+		_, err := coll.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: group.IDs[1:]}}}})
+		return err
+	}
+	migrations, err := StagedUniqueIndex(1, 2, 3, testCollection, model, keepFirst)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	m := NewMigrate(db, migrations...)
+	if err := m.Up(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	rollout, err := UniqueIndexRollout(ctx, coll, model)
+	if err != nil || rollout.Stage != UniqueStagePartial || rollout.Duplicates != 1 {
+		t.Errorf("Unexpected rollout: %+v %v", rollout, err)
+		return
+	}
+	if _, err := coll.InsertMany(ctx, []interface{}{
+		bson.D{{Key: "email", Value: "c@example.com"}},
+		bson.D{{Key: "email", Value: "c@example.com"}},
+	}); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := m.Up(ctx, AllAvailable); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	rollout, err = UniqueIndexRollout(ctx, coll, model)
+	if err != nil || rollout.Stage != UniqueStageFull {
+		t.Errorf("Unexpected rollout: %+v %v", rollout, err)
+		return
+	}
+
+	if err := m.Down(ctx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	rollout, err = UniqueIndexRollout(ctx, coll, model)
+	if err != nil || rollout.Stage != UniqueStagePartial {
+		t.Errorf("Unexpected rollout: %+v %v", rollout, err)
+	}
+}
@@ -1,8 +1,14 @@
 package migrate
 
 import (
+	"context"
+	"errors"
 	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestMigrationSort(t *testing.T) {
@@ -34,3 +40,100 @@ func TestHasVersion(t *testing.T) {
 		t.Errorf("Unexpectedly found version")
 	}
 }
+
+func TestPlanDown(t *testing.T) {
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+	migrations := []Migration{
+		{Version: 1, Down: noop},
+		{Version: 2},
+		{Version: 3, Down: noop},
+		{Version: 4, Down: noop},
+	}
+	plan := planDown(migrations, 3, AllAvailable)
+	if len(plan) != 2 || plan[0] != 2 || plan[1] != 0 {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+	plan = planDown(migrations, 4, 1)
+	if len(plan) != 1 || plan[0] != 3 {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+}
+
+func TestFitDuration(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Estimate: time.Minute},
+		{Version: 2},
+		{Version: 3, Estimate: 2 * time.Minute},
+	}
+	plan := []int{2, 1, 0}
+	if fits := fitDuration(migrations, plan, 3*time.Minute, 0); fits != 3 {
+		t.Errorf("Unexpected fitted steps: %v", fits)
+	}
+	if fits := fitDuration(migrations, plan, 3*time.Minute, time.Minute); fits != 2 {
+		t.Errorf("Unexpected fitted steps: %v", fits)
+	}
+	if fits := fitDuration(migrations, plan, time.Minute, 0); fits != 0 {
+		t.Errorf("Unexpected fitted steps: %v", fits)
+	}
+}
+
+func TestPlanDownTo(t *testing.T) {
+	noop := func(ctx context.Context, db *mongo.Database) error { return nil }
+	migrations := []Migration{
+		{Version: 1, Down: noop},
+		{Version: 2},
+		{Version: 3, Down: noop},
+		{Version: 4, Down: noop},
+	}
+	plan, err := planDownTo(migrations, 4, 2)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(plan) != 2 || plan[0] != 3 || plan[1] != 2 {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+	if _, err := planDownTo(migrations, 4, 0); err == nil {
+		t.Errorf("Unexpected nil error for irreversible migration")
+	}
+	if _, err := planDownTo(migrations, 4, 5); err == nil {
+		t.Errorf("Unexpected nil error for unknown target")
+	}
+	if _, err := planDownTo(migrations, 2, 3); err == nil {
+		t.Errorf("Unexpected nil error for newer target")
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	fn := func(context.Context, *mongo.Database) error { return nil }
+
+	valid := []Migration{
+		{Version: 1, Up: fn, Down: fn},
+		{Version: 2, Up: fn, DependsOn: []uint64{1}, Estimate: time.Minute},
+	}
+	if err := ValidateMigrations(valid); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	invalid := []Migration{
+		{Version: 0, Up: fn},
+		{Version: 1, Up: fn},
+		{Version: 1, Up: fn},
+		{Version: 2},
+		{Version: 3, Up: fn, DependsOn: []uint64{3}},
+		{Version: 4, Up: fn, DependsOn: []uint64{10}},
+		{Version: 5, Up: fn, Estimate: -time.Second, Collections: []string{""}},
+	}
+	err := ValidateMigrations(invalid)
+	if !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if problems := strings.Count(err.Error(), ErrInvalidMigration.Error()); problems != 7 {
+		t.Errorf("Unexpected number of problems %d: %v", problems, err)
+	}
+
+	if _, err := NewMigrateChecked(nil, valid...); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+}
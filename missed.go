@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissedMigrations returned by Up when registered migrations older than current version were never applied
+// and MissedMigrationsFail policy is set.
+var ErrMissedMigrations = errors.New("migrate: missed migrations")
+
+// MissedMigrationsPolicy defines handling of registered migrations which are older than current version
+// but were never applied, e.g. after merge of branches with migrations added in parallel.
+type MissedMigrationsPolicy int
+
+const (
+	// MissedMigrationsSkip ignores missed migrations. It is the default.
+	MissedMigrationsSkip MissedMigrationsPolicy = iota
+
+	// MissedMigrationsFail makes Up fail with ErrMissedMigrations listing missed versions.
+	MissedMigrationsFail
+
+	// MissedMigrationsApply makes Up apply missed migrations before newer ones.
+	// They're recorded as out of order (see VersionRecord.OutOfOrder), so current version stays the same.
+	MissedMigrationsApply
+)
+
+// SetMissedMigrations sets handling of registered migrations older than current version which were never applied.
+// Migration is considered applied if history has its record, so baseline written by SetVersions does not
+// produce missed migrations, but version set by SetVersion does for all older migrations.
+func (m *Migrate) SetMissedMigrations(policy MissedMigrationsPolicy) {
+	m.missedMigrations = policy
+}
+
+// upPlan returns up to n migrations performed by Up from current version in order of application.
+func (m *Migrate) upPlan(ctx context.Context, current uint64, n int) ([]Migration, error) {
+	if n <= 0 || n > len(m.migrations) {
+		n = len(m.migrations)
+	}
+	migrationSort(m.migrations)
+
+	var plan []Migration
+	if m.missedMigrations != MissedMigrationsSkip {
+		history, err := m.Versions().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		missed := missedMigrations(m.migrations, history, current)
+		if len(missed) > 0 && m.missedMigrations == MissedMigrationsFail {
+			versions := make([]string, 0, len(missed))
+			for _, migration := range missed {
+				versions = append(versions, fmt.Sprintf("%d (%s)", migration.Version, migration.Description))
+			}
+			return nil, fmt.Errorf("%w: %s older than current version %d", ErrMissedMigrations, strings.Join(versions, ", "), current)
+		}
+		plan = missed
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > current && migration.Up != nil {
+			plan = append(plan, migration)
+		}
+	}
+	if len(plan) > n {
+		plan = plan[:n]
+	}
+
+	return plan, nil
+}
+
+// missedMigrations returns sorted migrations older than current version without records in history.
+func missedMigrations(migrations []Migration, history []VersionRecord, current uint64) []Migration {
+	applied := make(map[uint64]bool, len(history))
+	for _, rec := range history {
+		if !rec.Dirty {
+			applied[rec.Version] = true
+		}
+	}
+
+	var missed []Migration
+	for _, migration := range migrations {
+		if migration.Version < current && migration.Up != nil && !applied[migration.Version] {
+			missed = append(missed, migration)
+		}
+	}
+
+	return missed
+}
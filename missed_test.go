@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestUpPlanMissedMigrations(t *testing.T) {
+	ctx := context.Background()
+	noop := func(context.Context, *mongo.Database) error { return nil }
+	m := NewMigrate(nil,
+		Migration{Version: 1, Up: noop},
+		Migration{Version: 2, Up: noop},
+		Migration{Version: 3, Up: noop},
+		Migration{Version: 4, Up: noop},
+	)
+	store := NewMemoryVersionStore()
+	m.SetVersionStore(store)
+	// version 2 came from merged branch after 3 was applied
+	if err := store.Append(ctx, VersionRecord{Version: 1}, VersionRecord{Version: 3}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	for policy, expected := range map[MissedMigrationsPolicy][]uint64{
+		MissedMigrationsSkip:  {4},
+		MissedMigrationsApply: {2, 4},
+	} {
+		m.SetMissedMigrations(policy)
+		plan, err := m.upPlan(ctx, 3, AllAvailable)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if len(plan) != len(expected) {
+			t.Errorf("Unexpected plan for policy %d: %+v", policy, plan)
+			continue
+		}
+		for i, migration := range plan {
+			if migration.Version != expected[i] {
+				t.Errorf("Unexpected plan for policy %d: %+v", policy, plan)
+			}
+		}
+	}
+
+	m.SetMissedMigrations(MissedMigrationsFail)
+	if _, err := m.upPlan(ctx, 3, AllAvailable); !errors.Is(err, ErrMissedMigrations) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestOutOfOrderRecordKeepsCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVersionStore()
+	if err := store.Append(ctx, VersionRecord{Version: 3}, VersionRecord{Version: 2, OutOfOrder: true}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	current, err := store.Current(ctx)
+	if err != nil || current.Version != 3 {
+		t.Errorf("Unexpected current record: %+v %v", current, err)
+		return
+	}
+	if _, found, _ := store.Applied(ctx, 2); !found {
+		t.Errorf("Out of order migration unexpectedly not applied")
+	}
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// commandMonitor observes commands issued by client while migration callback runs.
+type commandMonitor struct {
+	mu       sync.Mutex
+	active   bool
+	denied   []string
+	commands []CommandRecord
+
+	// pending maps request id of started command to its index in commands.
+	pending map[int64]int
+}
+
+func (c *commandMonitor) reset(active bool) {
+	c.active, c.denied, c.commands, c.pending = active, nil, nil, nil
+}
+
+// CommandMonitor returns driver command monitor which must be attached to client options
+// to enforce CommandPolicy for raw Go migrations and capture commands into audit records. Commands issued concurrently with migration
+// by other users of the client are observed too, so client should be dedicated to migrations.
+func (m *Migrate) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: m.commandStarted,
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			m.commandFinished(evt.CommandFinishedEvent, nil)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			m.commandFinished(evt.CommandFinishedEvent, &evt.Failure)
+		},
+	}
+}
+
+func (m *Migrate) commandStarted(_ context.Context, evt *event.CommandStartedEvent) {
+	if m.db != nil && evt.DatabaseName == m.db.Name() {
+		if collection, ok := evt.Command.Index(0).Value().StringValueOK(); ok && m.isBookkeeping(collection) {
+			return
+		}
+	}
+
+	m.monitor.mu.Lock()
+	defer m.monitor.mu.Unlock()
+
+	if !m.monitor.active {
+		return
+	}
+	if !m.commandPolicy.Permits(evt.CommandName) {
+		m.monitor.denied = append(m.monitor.denied, evt.CommandName)
+	}
+	if m.audit {
+		if m.monitor.pending == nil {
+			m.monitor.pending = make(map[int64]int)
+		}
+		m.monitor.pending[evt.RequestID] = len(m.monitor.commands)
+		m.monitor.commands = append(m.monitor.commands, CommandRecord{
+			Name:      evt.CommandName,
+			Namespace: commandNamespace(evt),
+			Filter:    commandFilter(evt.Command),
+		})
+	}
+}
+
+func (m *Migrate) commandFinished(evt event.CommandFinishedEvent, failure *string) {
+	m.monitor.mu.Lock()
+	defer m.monitor.mu.Unlock()
+
+	i, ok := m.monitor.pending[evt.RequestID]
+	if !ok {
+		return
+	}
+	delete(m.monitor.pending, evt.RequestID)
+
+	m.monitor.commands[i].Duration = evt.Duration
+	if failure != nil {
+		m.monitor.commands[i].Error = *failure
+	}
+}
+
+// call runs migration callback observing commands issued by it.
+func (m *Migrate) call(ctx context.Context, migration Migration, fn MigrationFunc, dir direction) error {
+	// cluster time is read before capture of commands starts
+	var startClusterTime primitive.Timestamp
+	if m.audit {
+		startClusterTime = m.auditClusterTime(ctx)
+	}
+	stopProfiling := m.startProfiling(ctx)
+
+	m.monitor.mu.Lock()
+	m.monitor.reset(true)
+	m.monitor.mu.Unlock()
+
+	started := time.Now()
+	err := fn(m.runContext(ctx, migration, dir), m.migrationDB(migration.ReadPreference))
+	duration := time.Since(started)
+
+	m.monitor.mu.Lock()
+	denied, commands := m.monitor.denied, m.monitor.commands
+	m.monitor.reset(false)
+	m.monitor.mu.Unlock()
+	slowOps := stopProfiling(ctx)
+
+	if m.audit {
+		rec := AuditRecord{
+			RunID:       runIDFromContext(ctx),
+			Version:     migration.Version,
+			Description: migration.Description,
+			Down:        dir == directionDown,
+			Timestamp:   started.UTC(),
+			Duration:    duration,
+			Commands:    commands,
+
+			SlowOperations: slowOps,
+
+			StartClusterTime: startClusterTime,
+			EndClusterTime:   m.auditClusterTime(ctx),
+		}
+		if err != nil {
+			rec.Error = err.Error()
+			// failed transaction is aborted, so record is written outside of it
+			ctx = withoutSession(ctx)
+		}
+		m.writeAudit(ctx, rec)
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("%w: migration %d executed %s", ErrCommandDenied, migration.Version, strings.Join(denied, ", "))
+	}
+
+	return nil
+}
+
+// isBookkeeping reports whether collection is used by migrator itself.
+func (m *Migrate) isBookkeeping(collection string) bool {
+	for _, name := range m.BookkeepingCollections() {
+		if name == collection {
+			return true
+		}
+	}
+
+	return false
+}
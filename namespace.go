@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// ErrNamespaceFiltered returned when helper tries to touch collection excluded by NamespaceFilter.
+var ErrNamespaceFiltered = errors.New("migrate: collection is excluded by namespace filter")
+
+// NamespaceFilter selects collections which may be touched by migrator and migration helpers.
+// Collection matches filter if it matches any of include patterns (or include patterns are empty)
+// and does not match any of exclude patterns.
+type NamespaceFilter struct {
+	// Include and Exclude contain glob patterns in path.Match syntax.
+	Include []string
+	Exclude []string
+
+	// IncludeRegexp and ExcludeRegexp are checked in addition to glob patterns if set.
+	IncludeRegexp *regexp.Regexp
+	ExcludeRegexp *regexp.Regexp
+}
+
+// Validate checks syntax of glob patterns.
+func (f NamespaceFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("migrate: bad namespace pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports if collection with provided name matches filter.
+func (f NamespaceFilter) Match(name string) bool {
+	included := len(f.Include) == 0 && f.IncludeRegexp == nil
+	for _, pattern := range f.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			included = true
+			break
+		}
+	}
+	if !included && f.IncludeRegexp != nil {
+		included = f.IncludeRegexp.MatchString(name)
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return f.ExcludeRegexp == nil || !f.ExcludeRegexp.MatchString(name)
+}
+
+// SetNamespaceFilter restricts collections which may be touched by migrator and migration helpers.
+// Helpers called outside of migration process are not restricted.
+func (m *Migrate) SetNamespaceFilter(filter NamespaceFilter) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	m.namespaceFilter = &filter
+	return nil
+}
+
+func (m *Migrate) matchNamespace(name string) bool {
+	return m.namespaceFilter == nil || m.namespaceFilter.Match(name)
+}
+
+// checkNamespaces returns ErrNamespaceFiltered if namespace filter excludes any of collections.
+func (m *Migrate) checkNamespaces(collections ...string) error {
+	for _, collection := range collections {
+		if !m.matchNamespace(collection) {
+			return fmt.Errorf("%w: %q", ErrNamespaceFiltered, collection)
+		}
+	}
+
+	return nil
+}
+
+// checkNamespace returns ErrNamespaceFiltered if namespace filter of running migrator excludes any of collections.
+func checkNamespace(ctx context.Context, collections ...string) error {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return state.migrate.checkNamespaces(collections...)
+}
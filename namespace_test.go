@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestNamespaceFilterMatch(t *testing.T) {
+	filter := NamespaceFilter{
+		Include:       []string{"app_*"},
+		IncludeRegexp: regexp.MustCompile(`^users$`),
+		Exclude:       []string{"app_tmp_*"},
+	}
+	for name, expected := range map[string]bool{
+		"app_orders":   true,
+		"users":        true,
+		"app_tmp_1":    false,
+		"vendor_cache": false,
+	} {
+		if actual := filter.Match(name); actual != expected {
+			t.Errorf("Unexpected match result for %q: %v", name, actual)
+		}
+	}
+
+	if !(NamespaceFilter{}).Match("anything") {
+		t.Errorf("Empty filter unexpectedly does not match")
+	}
+	if (NamespaceFilter{ExcludeRegexp: regexp.MustCompile("^system")}).Match("system_data") {
+		t.Errorf("Excluded collection unexpectedly matches")
+	}
+}
+
+func TestSetNamespaceFilter(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Include: []string{"["}}); err == nil {
+		t.Errorf("Unexpected nil error")
+	}
+	if err := m.SetNamespaceFilter(NamespaceFilter{Exclude: []string{"vendor_*"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+	if err := checkNamespace(ctx, "vendor_cache"); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkNamespace(ctx, "orders"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkNamespace(context.Background(), "vendor_cache"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestNamespaceFilterHelpers(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Exclude: []string{"vendor_*"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+
+	// helpers fail before database is touched
+	calls := map[string]func() error{
+		"Reconcile": func() error {
+			_, err := Reconcile(ctx, nil, Reconciliation{Source: "orders", Target: "vendor_orders"})
+			return err
+		},
+		"FindOrphans": func() error {
+			_, err := FindOrphans(ctx, nil, Reference{Collection: "orders", LocalField: "user", Foreign: "vendor_users"}, 0)
+			return err
+		},
+		"CheckReferences": func() error {
+			return CheckReferences(ctx, nil, Reference{Collection: "vendor_orders", LocalField: "user", Foreign: "users"})
+		},
+		"VerifyCountPreserved": func() error {
+			return VerifyCountPreserved("vendor_orders", nil)(ctx, nil)
+		},
+		"VerifySumPreserved": func() error {
+			return VerifySumPreserved("vendor_orders", "total")(ctx, nil)
+		},
+		"ProjectStorage": func() error {
+			_, err := ProjectStorage(ctx, nil, StorageGrowth{Collection: "vendor_orders"})
+			return err
+		},
+		"ConvertCapped": func() error {
+			// staging collection would match exclude pattern
+			return ConvertCapped(ctx, nil, "vendor", CappedConversion{})
+		},
+		"buildView": func() error {
+			return m.buildView(ctx, MaterializedView{Name: "totals", Source: "vendor_orders"})
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNamespaceFiltered) {
+			t.Errorf("Unexpected error of %s: %v", name, err)
+		}
+	}
+}
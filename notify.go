@@ -0,0 +1,35 @@
+package migrate
+
+import "context"
+
+// PendingHandler is called by Notify when database is behind the newest registered migration.
+type PendingHandler func(ctx context.Context, info VersionInfo)
+
+// SetPendingHandler sets callback called by Notify when database is behind, e.g. to emit metric or alert.
+func (m *Migrate) SetPendingHandler(handler PendingHandler) {
+	m.pendingHandler = handler
+}
+
+// Notify checks whether database is behind the newest registered migration without running migrations.
+// If it is, warning is logged and PendingHandler is called. It's intended for processes which never call Up,
+// e.g. read-only replicas of application, to still detect version skew.
+func (m *Migrate) Notify(ctx context.Context) (VersionInfo, error) {
+	info, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+
+	m.notify(ctx, info)
+	return info, nil
+}
+
+func (m *Migrate) notify(ctx context.Context, info VersionInfo) {
+	if !info.Behind() {
+		return
+	}
+
+	m.report(MsgDatabaseBehind, info.Current.Version, info.Head, info.HeadDescription, info.Pending)
+	if m.pendingHandler != nil {
+		m.pendingHandler(ctx, info)
+	}
+}
@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+type testLogger struct {
+	lines int
+}
+
+func (l *testLogger) Printf(string, ...any) {
+	l.lines++
+}
+
+func TestNotify(t *testing.T) {
+	var (
+		log    testLogger
+		called []VersionInfo
+	)
+	m := NewMigrate(nil)
+	m.SetLogger(&log)
+	m.SetPendingHandler(func(_ context.Context, info VersionInfo) {
+		called = append(called, info)
+	})
+
+	m.notify(context.Background(), VersionInfo{Current: VersionRecord{Version: 2}, Head: 2})
+	if len(called) != 0 || log.lines != 0 {
+		t.Errorf("Unexpected notification for up-to-date database")
+	}
+
+	m.notify(context.Background(), VersionInfo{Current: VersionRecord{Version: 1}, Head: 3, Pending: 2})
+	if len(called) != 1 || called[0].Pending != 2 || log.lines != 1 {
+		t.Errorf("Unexpected notifications: %+v", called)
+	}
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+)
+
+// CollectionOwnership describes migrations touching collection according to Migration.Collections.
+type CollectionOwnership struct {
+	Collection string
+
+	// Owner is an owner of the newest migration touching collection which has owner set.
+	Owner string
+
+	// LastApplied is a version of the newest applied migration touching collection, 0 if none of them applied.
+	LastApplied uint64
+
+	// Versions are versions of all registered migrations touching collection in ascending order.
+	Versions []uint64
+}
+
+// Ownership returns map of collections declared by registered migrations sorted by collection name.
+// It's intended for impact analysis: who owns collection and which migrations changed it.
+func (m *Migrate) Ownership(ctx context.Context) ([]CollectionOwnership, error) {
+	current, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ownershipMap(m.migrations, current), nil
+}
+
+func ownershipMap(migrations []Migration, current uint64) []CollectionOwnership {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	migrationSort(sorted)
+
+	byName := make(map[string]*CollectionOwnership)
+	for _, migration := range sorted {
+		for _, collection := range migration.Collections {
+			own, ok := byName[collection]
+			if !ok {
+				own = &CollectionOwnership{Collection: collection}
+				byName[collection] = own
+			}
+			if len(own.Versions) == 0 || own.Versions[len(own.Versions)-1] != migration.Version {
+				own.Versions = append(own.Versions, migration.Version)
+			}
+			if migration.Owner != "" {
+				own.Owner = migration.Owner
+			}
+			if migration.Version <= current {
+				own.LastApplied = migration.Version
+			}
+		}
+	}
+
+	ret := make([]CollectionOwnership, 0, len(byName))
+	for _, own := range byName {
+		ret = append(ret, *own)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Collection < ret[j].Collection })
+	return ret
+}
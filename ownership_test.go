@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnershipMap(t *testing.T) {
+	migrations := []Migration{
+		{Version: 3, Collections: []string{"orders"}},
+		{Version: 1, Collections: []string{"users", "orders"}, Owner: "accounts"},
+		{Version: 2, Collections: []string{"orders"}, Owner: "billing"},
+		{Version: 4, Collections: []string{"payments", "payments"}},
+	}
+
+	expected := []CollectionOwnership{
+		{Collection: "orders", Owner: "billing", LastApplied: 2, Versions: []uint64{1, 2, 3}},
+		{Collection: "payments", Versions: []uint64{4}},
+		{Collection: "users", Owner: "accounts", LastApplied: 1, Versions: []uint64{1}},
+	}
+	if actual := ownershipMap(migrations, 2); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected ownership map: %+v", actual)
+	}
+	if migrations[0].Version != 3 {
+		t.Errorf("Migrations unexpectedly sorted in place")
+	}
+}
@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCommandDenied returned when migration issues command forbidden by CommandPolicy.
+var ErrCommandDenied = errors.New("migrate: command denied by policy")
+
+// CommandPolicy restricts command types migrations may execute, e.g. deny "dropDatabase" and "drop" in production.
+// Names are compared case-insensitively.
+type CommandPolicy struct {
+	// Allow lists permitted commands. Empty list permits all commands not listed in Deny.
+	Allow []string
+
+	// Deny lists forbidden commands. It takes precedence over Allow.
+	Deny []string
+}
+
+// Permits reports whether command with provided name may be executed.
+func (p CommandPolicy) Permits(name string) bool {
+	for _, denied := range p.Deny {
+		if strings.EqualFold(denied, name) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range p.Allow {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetCommandPolicy sets policy of commands which migrations may execute. It's enforced before execution
+// for commands issued via RunCommand (declarative migrations and helpers) and by helpers issuing commands directly,
+// e.g. ConvertCapped and materialized views refresh. For raw Go migrations it's enforced
+// on best-effort basis when CommandMonitor is attached to client: migration fails after forbidden command was executed.
+func (m *Migrate) SetCommandPolicy(policy CommandPolicy) {
+	m.commandPolicy = policy
+}
+
+func (m *Migrate) checkCommand(name string) error {
+	if m.commandPolicy.Permits(name) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", ErrCommandDenied, name)
+}
+
+// checkCommands enforces policy on commands which are issued bypassing RunCommand.
+// All of them are checked before the first one is executed, so denied helper doesn't leave partial changes.
+func (m *Migrate) checkCommands(names ...string) error {
+	for _, name := range names {
+		if err := m.checkCommand(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRunCommands enforces policy of migration run in ctx, if any, like checkCommands.
+func checkRunCommands(ctx context.Context, names ...string) error {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return state.migrate.checkCommands(names...)
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCommandPolicyPermits(t *testing.T) {
+	tests := []struct {
+		policy  CommandPolicy
+		command string
+		permits bool
+	}{
+		{CommandPolicy{}, "drop", true},
+		{CommandPolicy{Deny: []string{"dropDatabase", "drop"}}, "drop", false},
+		{CommandPolicy{Deny: []string{"dropDatabase"}}, "DROPDATABASE", false},
+		{CommandPolicy{Deny: []string{"dropDatabase"}}, "insert", true},
+		{CommandPolicy{Allow: []string{"createIndexes"}}, "createIndexes", true},
+		{CommandPolicy{Allow: []string{"createIndexes"}}, "insert", false},
+		{CommandPolicy{Allow: []string{"drop"}, Deny: []string{"drop"}}, "drop", false},
+	}
+
+	for _, test := range tests {
+		if actual := test.policy.Permits(test.command); actual != test.permits {
+			t.Errorf("Unexpected result for %q with %+v: %t", test.command, test.policy, actual)
+		}
+	}
+}
+
+func TestRunCommandDenied(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCommandPolicy(CommandPolicy{Deny: []string{"drop"}})
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+
+	if err := RunCommand(ctx, nil, bson.D{{Key: "drop", Value: "users"}}); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestConvertCappedDenied(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCommandPolicy(CommandPolicy{Deny: []string{"renameCollection"}})
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+
+	// policy is checked before database is touched
+	if err := ConvertCapped(ctx, nil, "logs", CappedConversion{}); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestBuildViewDenied(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCommandPolicy(CommandPolicy{Allow: []string{"aggregate"}})
+
+	if err := m.buildView(context.Background(), MaterializedView{Name: "totals", Source: "orders"}); !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCommandMonitorDenied(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCommandPolicy(CommandPolicy{Deny: []string{"drop"}})
+	monitor := m.CommandMonitor()
+	drop := func(name string) {
+		command, _ := bson.Marshal(bson.D{{Key: "drop", Value: name}})
+		monitor.Started(context.Background(), &event.CommandStartedEvent{Command: command, CommandName: "drop", DatabaseName: "test"})
+	}
+
+	drop("outside")
+	err := m.call(context.Background(), Migration{Version: 1}, func(ctx context.Context, db *mongo.Database) error {
+		return nil
+	}, directionUp)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	err = m.call(context.Background(), Migration{Version: 2}, func(ctx context.Context, db *mongo.Database) error {
+		drop("users")
+		return nil
+	}, directionUp)
+	if !errors.Is(err, ErrCommandDenied) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// profileLevelSlow makes profiler record operations slower than slowms.
+	profileLevelSlow = 1
+
+	defaultSlowOperationsLimit = 10
+)
+
+// SlowOperation is an operation of migration recorded by database profiler, see SetProfiling.
+type SlowOperation struct {
+	Op           string        `bson:"op"`
+	Namespace    string        `bson:"ns"`
+	Timestamp    time.Time     `bson:"timestamp"`
+	Duration     time.Duration `bson:"duration"`
+	PlanSummary  string        `bson:"plan_summary,omitempty"`
+	KeysExamined int64         `bson:"keys_examined,omitempty"`
+	DocsExamined int64         `bson:"docs_examined,omitempty"`
+
+	// Command is a command of operation in Extended JSON truncated to 256 bytes.
+	Command string `bson:"command,omitempty"`
+}
+
+// profileEntry is a document of system.profile collection.
+type profileEntry struct {
+	Op           string    `bson:"op"`
+	Namespace    string    `bson:"ns"`
+	Timestamp    time.Time `bson:"ts"`
+	Millis       int64     `bson:"millis"`
+	PlanSummary  string    `bson:"planSummary"`
+	KeysExamined int64     `bson:"keysExamined"`
+	DocsExamined int64     `bson:"docsExamined"`
+	Command      bson.Raw  `bson:"command"`
+}
+
+// SetProfiling makes migrator raise profiling level of database while migration callback runs,
+// so operations slower than threshold are recorded by profiler. Up to limit of the slowest ones (default is 10)
+// are attached to audit record of migration, see AuditRecord.SlowOperations. Previous profiling level
+// is restored after callback. Profiling is done only if audit is enabled and covers migrator database only.
+// It is not supported by mongos. Non-positive threshold disables profiling, it is the default.
+func (m *Migrate) SetProfiling(threshold time.Duration, limit int) {
+	if limit <= 0 {
+		limit = defaultSlowOperationsLimit
+	}
+	m.profileThreshold = threshold
+	m.profileLimit = limit
+}
+
+// startProfiling raises profiling level of migrator database if enabled. Returned function restores
+// previous level and returns the slowest operations recorded since start. Errors are only logged.
+func (m *Migrate) startProfiling(ctx context.Context) func(ctx context.Context) []SlowOperation {
+	if !m.audit || m.profileThreshold <= 0 {
+		return func(context.Context) []SlowOperation { return nil }
+	}
+
+	// profile command is not allowed in transactions
+	ctx = withoutSession(ctx)
+	var hello struct {
+		LocalTime time.Time `bson:"localTime"`
+	}
+	// server clock is used, so profiler entries are matched regardless of skew of local clock
+	if err := m.db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		m.report(MsgProfilingFailed, err)
+		return func(context.Context) []SlowOperation { return nil }
+	}
+	var previous struct {
+		Was    int32 `bson:"was"`
+		SlowMs int32 `bson:"slowms"`
+	}
+	profile := bson.D{{Key: "profile", Value: profileLevelSlow}, {Key: "slowms", Value: m.profileThreshold.Milliseconds()}}
+	if err := m.db.RunCommand(ctx, profile).Decode(&previous); err != nil {
+		m.report(MsgProfilingFailed, err)
+		return func(context.Context) []SlowOperation { return nil }
+	}
+
+	return func(ctx context.Context) []SlowOperation {
+		ctx = withoutSession(ctx)
+		restore := bson.D{{Key: "profile", Value: previous.Was}, {Key: "slowms", Value: previous.SlowMs}}
+		if err := m.db.RunCommand(ctx, restore).Err(); err != nil {
+			m.report(MsgProfilingRestoreFailed, err)
+		}
+
+		ops, err := m.slowOperations(ctx, hello.LocalTime)
+		if err != nil {
+			m.report(MsgProfileReadFailed, err)
+		}
+		return ops
+	}
+}
+
+// slowOperations returns the slowest operations recorded by profiler since provided time
+// excluding operations on bookkeeping collections.
+func (m *Migrate) slowOperations(ctx context.Context, since time.Time) ([]SlowOperation, error) {
+	excluded := []string{m.db.Name() + ".system.profile"}
+	for _, name := range m.BookkeepingCollections() {
+		excluded = append(excluded, m.db.Name()+"."+name)
+	}
+	filter := bson.D{
+		{Key: "ts", Value: bson.D{{Key: "$gte", Value: since}}},
+		{Key: "ns", Value: bson.D{{Key: "$nin", Value: excluded}}},
+		{Key: "command.profile", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "millis", Value: -1}}).SetLimit(int64(m.profileLimit))
+	cursor, err := m.db.Collection("system.profile").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []profileEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	ops := make([]SlowOperation, 0, len(entries))
+	for _, entry := range entries {
+		op := SlowOperation{
+			Op:           entry.Op,
+			Namespace:    entry.Namespace,
+			Timestamp:    entry.Timestamp,
+			Duration:     time.Duration(entry.Millis) * time.Millisecond,
+			PlanSummary:  entry.PlanSummary,
+			KeysExamined: entry.KeysExamined,
+			DocsExamined: entry.DocsExamined,
+		}
+		if len(entry.Command) > 0 {
+			if data, err := bson.MarshalExtJSON(entry.Command, false, false); err == nil {
+				if len(data) > auditFilterLimit {
+					data = append(data[:auditFilterLimit:auditFilterLimit], "..."...)
+				}
+				op.Command = string(data)
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
@@ -0,0 +1,157 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CriticalQuery describes query which must be served by an index.
+type CriticalQuery struct {
+	Name       string
+	Collection string
+	Filter     interface{}
+	Sort       interface{}
+}
+
+// QueryPlanPolicy defines reaction on critical query degraded from index to collection scan.
+type QueryPlanPolicy int
+
+const (
+	// QueryPlanWarn only prints degraded queries using logger.
+	QueryPlanWarn QueryPlanPolicy = iota
+
+	// QueryPlanFail stops migration process with ErrQueryPlanRegression.
+	QueryPlanFail
+)
+
+// ErrQueryPlanRegression returned when critical query plan degrades to collection scan after migration.
+var ErrQueryPlanRegression = errors.New("migrate: query plan regression")
+
+// queryPlan is a summary of winning plan of critical query.
+type queryPlan int
+
+const (
+	// planEmpty is a plan of query on missing collection.
+	planEmpty queryPlan = iota
+	planIndexed
+	planCollScan
+)
+
+// SetQueryPlanCheck sets critical queries which are explained before and after each performed migration.
+// If winning plan of any query served by an index before migration contains "COLLSCAN" stage after it,
+// it is reported according to policy. Queries which were served by collection scan already are not reported.
+// Note that migration which caused regression is already recorded as applied at the moment of check,
+// so ErrQueryPlanRegression means that database is at version of this migration and regression
+// should be fixed by the next migration or by reverting this one.
+func (m *Migrate) SetQueryPlanCheck(policy QueryPlanPolicy, queries ...CriticalQuery) {
+	m.queryPlanPolicy = policy
+	m.criticalQueries = queries
+}
+
+// queryPlans explains critical queries before migration.
+func (m *Migrate) queryPlans(ctx context.Context) (map[string]queryPlan, error) {
+	if len(m.criticalQueries) == 0 {
+		return nil, nil
+	}
+
+	plans := make(map[string]queryPlan, len(m.criticalQueries))
+	for _, query := range m.criticalQueries {
+		plan, err := m.explainQuery(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: explain query %q: %w", query.Name, err)
+		}
+		plans[query.Name] = plan
+	}
+
+	return plans, nil
+}
+
+// checkQueryPlans reports critical queries which were served by an index before migration
+// and are served by collection scan after it.
+func (m *Migrate) checkQueryPlans(ctx context.Context, version uint64, before map[string]queryPlan) error {
+	for _, query := range m.criticalQueries {
+		plan, err := m.explainQuery(ctx, query)
+		if err != nil {
+			return fmt.Errorf("migrate: explain query %q: %w", query.Name, err)
+		}
+		if plan != planCollScan || before[query.Name] != planIndexed {
+			continue
+		}
+
+		if m.queryPlanPolicy == QueryPlanFail {
+			return fmt.Errorf("%w: query %q on %q uses collection scan after version %d",
+				ErrQueryPlanRegression, query.Name, query.Collection, version)
+		}
+		m.report(MsgCollectionScan, query.Name, query.Collection, version)
+	}
+
+	return nil
+}
+
+func (m *Migrate) explainQuery(ctx context.Context, query CriticalQuery) (queryPlan, error) {
+	find := bson.D{{Key: "find", Value: query.Collection}}
+	if query.Filter != nil {
+		find = append(find, bson.E{Key: "filter", Value: query.Filter})
+	}
+	if query.Sort != nil {
+		find = append(find, bson.E{Key: "sort", Value: query.Sort})
+	}
+	command := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "queryPlanner"}}
+
+	var result struct {
+		QueryPlanner struct {
+			WinningPlan bson.M `bson:"winningPlan"`
+		} `bson:"queryPlanner"`
+	}
+	// explain is not allowed in transactions
+	if err := m.db.RunCommand(withoutSession(ctx), command).Decode(&result); err != nil {
+		return planEmpty, err
+	}
+
+	return summarizePlan(result.QueryPlanner.WinningPlan), nil
+}
+
+func summarizePlan(plan bson.M) queryPlan {
+	switch {
+	case hasPlanStage(plan, "COLLSCAN"):
+		return planCollScan
+	case hasPlanStage(plan, "EOF"):
+		return planEmpty
+	default:
+		return planIndexed
+	}
+}
+
+// hasPlanStage recursively searches for stage in explained plan.
+func hasPlanStage(plan interface{}, stage string) bool {
+	switch p := plan.(type) {
+	case bson.M:
+		if s, ok := p["stage"].(string); ok && s == stage {
+			return true
+		}
+		for _, v := range p {
+			if hasPlanStage(v, stage) {
+				return true
+			}
+		}
+	case bson.D:
+		for _, e := range p {
+			if s, ok := e.Value.(string); ok && e.Key == "stage" && s == stage {
+				return true
+			}
+			if hasPlanStage(e.Value, stage) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, v := range p {
+			if hasPlanStage(v, stage) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestHasPlanStage(t *testing.T) {
+	plan := bson.M{
+		"stage": "FETCH",
+		"inputStage": bson.M{
+			"stage":     "IXSCAN",
+			"indexName": "a_1",
+		},
+	}
+	if hasPlanStage(plan, "COLLSCAN") {
+		t.Errorf("Unexpectedly found collection scan")
+	}
+
+	plan = bson.M{
+		"stage": "OR",
+		"inputStages": bson.A{
+			bson.M{"stage": "IXSCAN"},
+			bson.M{"stage": "COLLSCAN"},
+		},
+	}
+	if !hasPlanStage(plan, "COLLSCAN") {
+		t.Errorf("Unexpectedly not found collection scan")
+	}
+
+	plan = bson.M{"queryPlan": bson.M{"stage": "COLLSCAN"}}
+	if !hasPlanStage(plan, "COLLSCAN") {
+		t.Errorf("Unexpectedly not found collection scan")
+	}
+}
+
+func TestSummarizePlan(t *testing.T) {
+	if plan := summarizePlan(bson.M{"stage": "EOF"}); plan != planEmpty {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+	if plan := summarizePlan(bson.M{"stage": "FETCH", "inputStage": bson.M{"stage": "IXSCAN"}}); plan != planIndexed {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+	if plan := summarizePlan(bson.M{"stage": "SORT", "inputStage": bson.M{"stage": "COLLSCAN"}}); plan != planCollScan {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+}
@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ErrReconciliationMismatch returned when target collection doesn't match source one.
+var ErrReconciliationMismatch = errors.New("migrate: reconciliation mismatch")
+
+// Reconciliation describes comparison of source collection with its copy before cutover,
+// e.g. before source is dropped or renamed.
+type Reconciliation struct {
+	Source       string
+	SourceFilter interface{}
+	Target       string
+	TargetFilter interface{}
+
+	// SumFields are numeric fields which sums must be equal in both collections. Field names are the same
+	// in source and target.
+	SumFields []string
+
+	// ReadPreference allows to run heavy reconciliation on secondaries, e.g.
+	// readpref.Secondary(readpref.WithMaxStaleness(90*time.Second)). Nil means read preference of database.
+	ReadPreference *readpref.ReadPref
+}
+
+// FieldSum is a sum of numeric field in source and target collections. Values are summed as decimals,
+// so sums of integers are exact and the same doubles stored in both collections give equal sums.
+type FieldSum struct {
+	Field  string
+	Source primitive.Decimal128
+	Target primitive.Decimal128
+}
+
+// ReconciliationReport is a result of Reconcile.
+type ReconciliationReport struct {
+	Source      string
+	Target      string
+	SourceCount int64
+	TargetCount int64
+	Sums        []FieldSum
+}
+
+// Matches reports whether counts and all sums are equal.
+func (r ReconciliationReport) Matches() bool {
+	if r.SourceCount != r.TargetCount {
+		return false
+	}
+	for _, sum := range r.Sums {
+		if !decimalEqual(sum.Source, sum.Target) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String renders mismatched values only.
+func (r ReconciliationReport) String() string {
+	var diffs []string
+	if r.SourceCount != r.TargetCount {
+		diffs = append(diffs, fmt.Sprintf("count %d != %d", r.SourceCount, r.TargetCount))
+	}
+	for _, sum := range r.Sums {
+		if !decimalEqual(sum.Source, sum.Target) {
+			diffs = append(diffs, fmt.Sprintf("sum(%s) %v != %v", sum.Field, sum.Source, sum.Target))
+		}
+	}
+	if len(diffs) == 0 {
+		diffs = append(diffs, "match")
+	}
+
+	return fmt.Sprintf("%s -> %s: %s", r.Source, r.Target, strings.Join(diffs, ", "))
+}
+
+// Reconcile compares number of documents and sums of fields in source and target collections.
+// If they differ, report is returned together with ErrReconciliationMismatch.
+func Reconcile(ctx context.Context, db *mongo.Database, rec Reconciliation) (ReconciliationReport, error) {
+	report := ReconciliationReport{Source: rec.Source, Target: rec.Target}
+	if err := checkNamespace(ctx, rec.Source, rec.Target); err != nil {
+		return report, err
+	}
+
+	opts := options.Collection()
+	if rec.ReadPreference != nil {
+		opts.SetReadPreference(rec.ReadPreference)
+	}
+
+	sourceCount, sourceSums, err := countAndSum(ctx, db.Collection(rec.Source, opts), rec.SourceFilter, rec.SumFields)
+	if err != nil {
+		return report, err
+	}
+	targetCount, targetSums, err := countAndSum(ctx, db.Collection(rec.Target, opts), rec.TargetFilter, rec.SumFields)
+	if err != nil {
+		return report, err
+	}
+
+	report.SourceCount, report.TargetCount = sourceCount, targetCount
+	for i, field := range rec.SumFields {
+		report.Sums = append(report.Sums, FieldSum{Field: field, Source: sourceSums[i], Target: targetSums[i]})
+	}
+	if !report.Matches() {
+		return report, fmt.Errorf("%w: %s", ErrReconciliationMismatch, report)
+	}
+
+	return report, nil
+}
+
+func countAndSum(ctx context.Context, coll *mongo.Collection, filter interface{}, fields []string) (int64, []primitive.Decimal128, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	group := bson.D{{Key: "_id", Value: nil}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}
+	for i, field := range fields {
+		group = append(group, bson.E{
+			Key:   fmt.Sprintf("s%d", i),
+			Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$toDecimal", Value: "$" + field}}}},
+		})
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}, {{Key: "$group", Value: group}}}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, nil, fmt.Errorf("migrate: reconcile %q: %w", coll.Name(), err)
+	}
+	var results []bson.Raw
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, nil, err
+	}
+
+	sums := make([]primitive.Decimal128, len(fields))
+	for i := range sums {
+		sums[i] = decimalZero
+	}
+	if len(results) == 0 {
+		return 0, sums, nil
+	}
+	count, _ := results[0].Lookup("count").AsInt64OK()
+	for i := range fields {
+		if sum, ok := results[0].Lookup(fmt.Sprintf("s%d", i)).Decimal128OK(); ok {
+			sums[i] = sum
+		}
+	}
+
+	return count, sums, nil
+}
+
+// ReconciliationCheck returns tracked migration which fails with diff report if any of reconciliations mismatches.
+// Put it right before cutover migration. Reverting it does nothing.
+func ReconciliationCheck(version uint64, description string, recs ...Reconciliation) Migration {
+	return Migration{
+		Version:     version,
+		Description: description,
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			var errs []error
+			for _, rec := range recs {
+				if _, err := Reconcile(ctx, db, rec); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package migrate
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReconciliationReport(t *testing.T) {
+	decimal := func(s string) primitive.Decimal128 {
+		d, _ := primitive.ParseDecimal128(s)
+		return d
+	}
+	report := ReconciliationReport{
+		Source:      "orders",
+		Target:      "orders_v2",
+		SourceCount: 10,
+		TargetCount: 10,
+		Sums:        []FieldSum{{Field: "amount", Source: decimal("12.5"), Target: decimal("12.50000000000000")}},
+	}
+	if !report.Matches() || report.String() != "orders -> orders_v2: match" {
+		t.Errorf("Unexpected report: %s", report)
+	}
+
+	report.TargetCount = 9
+	report.Sums = append(report.Sums, FieldSum{Field: "qty", Source: decimal("3"), Target: decimal("2")})
+	if report.Matches() {
+		t.Errorf("Unexpected match")
+	}
+	if actual := report.String(); actual != "orders -> orders_v2: count 10 != 9, sum(qty) 3 != 2" {
+		t.Errorf("Unexpected report: %s", actual)
+	}
+}
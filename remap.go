@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrUnmappedValue returned by RemapValues for value missing in mapping when UnmappedFail policy is set.
+var ErrUnmappedValue = errors.New("migrate: value is not mapped")
+
+// UnmappedPolicy defines handling of field values missing in mapping of RemapValues.
+type UnmappedPolicy int
+
+const (
+	// UnmappedFail makes RemapValues fail with ErrUnmappedValue before any document is changed. It is the default.
+	UnmappedFail UnmappedPolicy = iota
+
+	// UnmappedKeep leaves unmapped values unchanged.
+	UnmappedKeep
+
+	// UnmappedDefault replaces unmapped values with RemapOptions.Default.
+	UnmappedDefault
+)
+
+// RemapOptions configures RemapValues.
+type RemapOptions[T comparable] struct {
+	// Unmapped defines handling of values missing in mapping.
+	Unmapped UnmappedPolicy
+
+	// Default replaces unmapped values if Unmapped is UnmappedDefault.
+	Default T
+
+	// Batch configures batched rewrite, see Batch. Default ResumeKey is "remap:<collection>.<field>".
+	Batch BatchOptions
+}
+
+// RemapValues replaces discrete values of field (e.g. enum constants) in documents of coll according to mapping
+// of old values to new ones. Field may be a dotted path through embedded documents, documents without field
+// are left unchanged. Values are decoded to T using codec settings of migrator which performs current migration.
+// Documents are processed like by Batch, so rewrite is resumable inside migration, but only field is updated
+// by "$set" conditioned on its old value: concurrent changes of other fields are kept and document
+// whose field was changed concurrently is left as is. Values which are targets of mapping are
+// considered already remapped and are left unchanged, so chained mappings (a→b, b→c) are not supported.
+func RemapValues[T comparable](ctx context.Context, coll *mongo.Collection, field string, mapping map[T]T, opts RemapOptions[T]) (BatchResult, error) {
+	if field == "" {
+		return BatchResult{}, errors.New("migrate: field to remap is not set")
+	}
+	if opts.Batch.ResumeKey == "" {
+		opts.Batch.ResumeKey = "remap:" + coll.Name() + "." + field
+	}
+	targets := make(map[T]bool, len(mapping))
+	for _, to := range mapping {
+		targets[to] = true
+	}
+
+	if opts.Unmapped == UnmappedFail {
+		if err := checkMapped(ctx, coll, field, mapping, targets); err != nil {
+			return BatchResult{}, err
+		}
+	}
+
+	filter := bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: true}}}}
+	return batchWrite(ctx, coll, filter, remapModel(ctx, field, mapping, targets, opts), opts.Batch)
+}
+
+// remapModel returns modelFunc setting remapped value of field.
+func remapModel[T comparable](ctx context.Context, field string, mapping map[T]T, targets map[T]bool, opts RemapOptions[T]) modelFunc {
+	path := strings.Split(field, ".")
+	return func(doc bson.Raw) (mongo.WriteModel, error) {
+		old := doc.Lookup(path...)
+		var value T
+		if err := unmarshalValue(ctx, old, &value); err != nil {
+			return nil, fmt.Errorf("migrate: decode field %q: %w", field, err)
+		}
+
+		replacement, ok := mapping[value]
+		switch {
+		case ok:
+		case targets[value] || opts.Unmapped == UnmappedKeep:
+			return nil, nil
+		case opts.Unmapped == UnmappedDefault:
+			replacement = opts.Default
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrUnmappedValue, value)
+		}
+		if replacement == value {
+			return nil, nil
+		}
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: doc.Lookup("_id")}, {Key: field, Value: old}}).
+			SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: replacement}}}}), nil
+	}
+}
+
+// checkMapped fails if field of any document has value which is neither source nor target of mapping.
+func checkMapped[T comparable](ctx context.Context, coll *mongo.Collection, field string, mapping map[T]T, targets map[T]bool) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: true}}}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$" + field}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var unmapped []string
+	for cursor.Next(ctx) {
+		var value T
+		if err := unmarshalValue(ctx, cursor.Current.Lookup("_id"), &value); err != nil {
+			return fmt.Errorf("migrate: decode field %q: %w", field, err)
+		}
+		if _, ok := mapping[value]; !ok && !targets[value] {
+			unmapped = append(unmapped, fmt.Sprint(value))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if len(unmapped) > 0 {
+		return fmt.Errorf("%w: field %q of %q has %s", ErrUnmappedValue, field, coll.Name(), strings.Join(unmapped, ", "))
+	}
+
+	return nil
+}
+
+// unmarshalValue decodes value using codec settings of migrator which performs current migration.
+func unmarshalValue(ctx context.Context, value bson.RawValue, v interface{}) error {
+	if registry, _ := codecFromContext(ctx); registry != nil {
+		return value.UnmarshalWithRegistry(registry, v)
+	}
+
+	return value.Unmarshal(v)
+}
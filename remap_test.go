@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRemapModel(t *testing.T) {
+	mapping := map[string]string{"active": "enabled"}
+	targets := map[string]bool{"enabled": true}
+	model := remapModel(context.Background(), "billing.status", mapping, targets, RemapOptions[string]{Unmapped: UnmappedKeep})
+
+	doc, _ := bson.Marshal(bson.D{{Key: "_id", Value: 1}, {Key: "billing", Value: bson.D{{Key: "status", Value: "active"}}}})
+	m, err := model(doc)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	update, ok := m.(*mongo.UpdateOneModel)
+	if !ok {
+		t.Errorf("Unexpected model: %#v", m)
+		return
+	}
+	if !equalBSON(update.Filter, bson.D{{Key: "_id", Value: 1}, {Key: "billing.status", Value: "active"}}) {
+		t.Errorf("Unexpected filter: %v", update.Filter)
+	}
+	if !equalBSON(update.Update, bson.D{{Key: "$set", Value: bson.D{{Key: "billing.status", Value: "enabled"}}}}) {
+		t.Errorf("Unexpected update: %v", update.Update)
+	}
+
+	for _, status := range []string{"enabled", "unknown"} {
+		doc, _ := bson.Marshal(bson.D{{Key: "_id", Value: 1}, {Key: "billing", Value: bson.D{{Key: "status", Value: status}}}})
+		if m, err := model(doc); err != nil || m != nil {
+			t.Errorf("Unexpected model for %q: %v, %v", status, m, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInconsistentHistory returned when migrations history contradicts itself, e.g. after partial restore of backup.
+var ErrInconsistentHistory = errors.New("migrate: inconsistent history")
+
+// VerifyRestore checks migrations history of database without changing it, e.g. right after restoring backup:
+//
+// - records are in order of their timestamps;
+//
+// - the last record is not dirty (ErrDirty);
+//
+// - applied migrations are registered and were not edited (see Validate);
+//
+// - every registered migration up to current version was applied (ErrInconsistentHistory).
+//
+// All found problems are reported in one error.
+func (m *Migrate) VerifyRestore(ctx context.Context) error {
+	history, err := m.Versions().List(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := m.currentRecord(ctx)
+	if err != nil {
+		return err
+	}
+
+	return verifyHistory(m.migrations, history, current.Version)
+}
+
+func verifyHistory(migrations []Migration, history []VersionRecord, current uint64) error {
+	var errs []error
+	for i := 1; i < len(history); i++ {
+		if history[i].Timestamp.Before(history[i-1].Timestamp) {
+			errs = append(errs, fmt.Errorf("%w: record %d of version %d is older than previous one",
+				ErrInconsistentHistory, i, history[i].Version))
+		}
+	}
+	if len(history) > 0 {
+		if last := history[len(history)-1]; last.Dirty {
+			errs = append(errs, fmt.Errorf("%w: migration %d (%s) failed", ErrDirty, last.Version, last.Description))
+		}
+	}
+
+	if err := validateHistory(migrations, history, current); err != nil {
+		errs = append(errs, err)
+	}
+
+	applied := make(map[uint64]bool, len(history))
+	for _, rec := range history {
+		if !rec.Dirty {
+			applied[rec.Version] = true
+		}
+	}
+	for _, migration := range migrations {
+		if migration.Version <= current && !applied[migration.Version] {
+			errs = append(errs, fmt.Errorf("%w: migration %d (%s) is not applied but current version is %d",
+				ErrInconsistentHistory, migration.Version, migration.Description, current))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyHistory(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Description: "first", Checksum: "a"},
+		{Version: 2, Description: "second", Checksum: "b"},
+		{Version: 3, Description: "third"},
+	}
+	at := func(minutes int) time.Time { return time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC) }
+
+	for name, tc := range map[string]struct {
+		history      []VersionRecord
+		current      uint64
+		inconsistent bool
+		dirty        bool
+		mismatch     bool
+	}{
+		"clean": {
+			history: []VersionRecord{{Version: 1, Checksum: "a", Timestamp: at(1)}, {Version: 2, Checksum: "b", Timestamp: at(2)}},
+			current: 2,
+		},
+		"reverted": {
+			history: []VersionRecord{{Version: 1, Timestamp: at(1)}, {Version: 2, Timestamp: at(2)}, {Version: 1, Timestamp: at(3)}},
+			current: 1,
+		},
+		"unordered": {
+			history:      []VersionRecord{{Version: 1, Timestamp: at(2)}, {Version: 2, Timestamp: at(1)}},
+			current:      2,
+			inconsistent: true,
+		},
+		"gap": {
+			history:      []VersionRecord{{Version: 1, Timestamp: at(1)}, {Version: 3, Timestamp: at(2)}},
+			current:      3,
+			inconsistent: true,
+		},
+		"dirty": {
+			history: []VersionRecord{{Version: 1, Timestamp: at(1)}, {Version: 2, Timestamp: at(2), Dirty: true}},
+			current: 1,
+			dirty:   true,
+		},
+		"edited": {
+			history:  []VersionRecord{{Version: 1, Checksum: "x", Timestamp: at(1)}},
+			current:  1,
+			mismatch: true,
+		},
+	} {
+		err := verifyHistory(migrations, tc.history, tc.current)
+		if errors.Is(err, ErrInconsistentHistory) != tc.inconsistent || errors.Is(err, ErrDirty) != tc.dirty ||
+			errors.Is(err, ErrChecksumMismatch) != tc.mismatch {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+	}
+}
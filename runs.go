@@ -0,0 +1,189 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RunRecord is an audit document of run, i.e. single call of Up, Down, DownTo, MigrateTo or ApplyAll.
+// Audit records of migrations performed by run refer to it by RunID.
+type RunRecord struct {
+	// ID is generated by IDGenerator, see SetIDGenerator.
+	ID        interface{} `bson:"_id"`
+	Operation string      `bson:"operation"`
+
+	// Track is a name of migration track, see SetTrack.
+	Track string `bson:"track,omitempty"`
+
+	// N is a requested number of migrations, Target is a requested version.
+	N      int     `bson:"n,omitempty"`
+	Target *uint64 `bson:"target,omitempty"`
+
+	StartedAt  time.Time     `bson:"started_at"`
+	FinishedAt time.Time     `bson:"finished_at,omitempty"`
+	Duration   time.Duration `bson:"duration,omitempty"`
+
+	// Error is a text of error returned by run, empty if it succeeded or is in progress.
+	Error string `bson:"error,omitempty"`
+
+	Config RunConfig `bson:"config"`
+}
+
+// RunConfig is an effective configuration of migrator. Secrets are not included:
+// secret resolver is described by its type only.
+type RunConfig struct {
+	Database              string `bson:"database"`
+	MigrationsCollection  string `bson:"migrations_collection"`
+	CheckpointsCollection string `bson:"checkpoints_collection"`
+	LocksCollection       string `bson:"locks_collection"`
+	AuditCollection       string `bson:"audit_collection"`
+	ViewsCollection       string `bson:"views_collection"`
+
+	// Migrations is a number of registered migrations, Head is the newest registered version.
+	Migrations int    `bson:"migrations"`
+	Head       uint64 `bson:"head"`
+
+	ReadConcern    string `bson:"read_concern,omitempty"`
+	WriteConcern   string `bson:"write_concern,omitempty"`
+	ReadPreference string `bson:"read_preference,omitempty"`
+
+	Transactions      bool          `bson:"transactions"`
+	Audit             bool          `bson:"audit"`
+	RunControl        bool          `bson:"run_control"`
+	RunLockTTL        time.Duration `bson:"run_lock_ttl,omitempty"`
+	RunLockWait       bool          `bson:"run_lock_wait,omitempty"`
+	CollectionLockTTL time.Duration `bson:"collection_lock_ttl,omitempty"`
+	FailoverAttempts  int           `bson:"failover_attempts,omitempty"`
+	ProfileThreshold  time.Duration `bson:"profile_threshold,omitempty"`
+
+	NamespaceInclude []string `bson:"namespace_include,omitempty"`
+	NamespaceExclude []string `bson:"namespace_exclude,omitempty"`
+	CommandAllow     []string `bson:"command_allow,omitempty"`
+	CommandDeny      []string `bson:"command_deny,omitempty"`
+	SecretResolver   string   `bson:"secret_resolver,omitempty"`
+
+	Environment string          `bson:"environment,omitempty"`
+	Tenant      string          `bson:"tenant,omitempty"`
+	Flags       map[string]bool `bson:"flags,omitempty"`
+}
+
+type runIDKey struct{}
+
+// Config returns effective configuration of migrator.
+func (m *Migrate) Config() RunConfig {
+	cfg := RunConfig{
+		MigrationsCollection:  m.migrationsCollection,
+		CheckpointsCollection: m.checkpointsCollection,
+		LocksCollection:       m.locksCollection,
+		AuditCollection:       m.auditCollection,
+		ViewsCollection:       m.viewsCollection,
+		Migrations:            len(m.migrations),
+		Transactions:          m.transactions,
+		Audit:                 m.audit,
+		RunControl:            m.runControl,
+		RunLockTTL:            m.runLockTTL,
+		RunLockWait:           m.runLockWait,
+		CollectionLockTTL:     m.collectionLockTTL,
+		FailoverAttempts:      m.failoverRetry.Attempts,
+		ProfileThreshold:      m.profileThreshold,
+		CommandAllow:          m.commandPolicy.Allow,
+		CommandDeny:           m.commandPolicy.Deny,
+		Environment:           m.runInfo.Environment,
+		Tenant:                m.runInfo.Tenant,
+		Flags:                 m.runInfo.Flags,
+	}
+	for _, migration := range m.migrations {
+		if migration.Version > cfg.Head {
+			cfg.Head = migration.Version
+		}
+	}
+	if m.secrets != nil {
+		cfg.SecretResolver = fmt.Sprintf("%T", m.secrets)
+	}
+	if f := m.namespaceFilter; f != nil {
+		cfg.NamespaceInclude = append(cfg.NamespaceInclude, f.Include...)
+		cfg.NamespaceExclude = append(cfg.NamespaceExclude, f.Exclude...)
+		if f.IncludeRegexp != nil {
+			cfg.NamespaceInclude = append(cfg.NamespaceInclude, "regexp:"+f.IncludeRegexp.String())
+		}
+		if f.ExcludeRegexp != nil {
+			cfg.NamespaceExclude = append(cfg.NamespaceExclude, "regexp:"+f.ExcludeRegexp.String())
+		}
+	}
+
+	if m.db != nil {
+		cfg.Database = m.db.Name()
+		if rc := m.db.ReadConcern(); rc != nil {
+			cfg.ReadConcern = rc.Level
+		}
+		if wc := m.db.WriteConcern(); wc != nil {
+			cfg.WriteConcern = fmt.Sprintf("w=%v j=%v wtimeout=%s", wc.W, wc.Journal, wc.WTimeout)
+		}
+		if rp := m.db.ReadPreference(); rp != nil {
+			cfg.ReadPreference = rp.String()
+		}
+	}
+
+	return cfg
+}
+
+// beginRun writes run record if audit is enabled. Returned function completes record with result of run.
+func (m *Migrate) beginRun(ctx context.Context, rec RunRecord) (context.Context, func(err error)) {
+	if !m.audit {
+		return ctx, func(error) {}
+	}
+
+	rec.ID = m.newID()
+	rec.Track = m.track
+	rec.StartedAt = time.Now().UTC()
+	rec.Config = m.Config()
+	if _, err := m.db.Collection(m.auditCollection).InsertOne(ctx, rec); err != nil {
+		m.report(MsgRunAuditFailed, err)
+		return ctx, func(error) {}
+	}
+
+	return context.WithValue(ctx, runIDKey{}, rec.ID), func(err error) {
+		finished := time.Now().UTC()
+		set := bson.D{
+			{Key: "finished_at", Value: finished},
+			{Key: "duration", Value: finished.Sub(rec.StartedAt)},
+		}
+		if err != nil {
+			set = append(set, bson.E{Key: "error", Value: err.Error()})
+		}
+		// run context may be canceled already
+		_, updateErr := m.db.Collection(m.auditCollection).UpdateByID(context.Background(), rec.ID, bson.D{{Key: "$set", Value: set}})
+		if updateErr != nil {
+			m.report(MsgRunAuditFailed, updateErr)
+		}
+	}
+}
+
+func runIDFromContext(ctx context.Context) interface{} {
+	return ctx.Value(runIDKey{})
+}
+
+// Runs returns up to limit audit records of runs, the newest first. Non-positive limit means no limit.
+// Runs are recorded only if audit is enabled, see SetAudit. Only runs of migrator track are returned, see SetTrack.
+func (m *Migrate) Runs(ctx context.Context, limit int) ([]RunRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	filter := bson.D{{Key: "operation", Value: bson.D{{Key: "$exists", Value: true}}}, {Key: "track", Value: m.trackFilter()}}
+	cursor, err := m.db.Collection(m.auditCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []RunRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
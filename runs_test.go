@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+)
+
+func TestConfig(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database("test", options.Database().SetReadConcern(readconcern.Majority()))
+	m := NewMigrate(db, Migration{Version: 3}, Migration{Version: 7})
+	m.SetRunLock(time.Minute, true)
+	m.SetSecretResolver(EnvSecrets{Prefix: "TOKEN_"})
+	m.SetCommandPolicy(CommandPolicy{Deny: []string{"dropDatabase"}})
+	if err := m.SetNamespaceFilter(NamespaceFilter{
+		Include:       []string{"users"},
+		ExcludeRegexp: regexp.MustCompile(`^tmp_`),
+	}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	cfg := m.Config()
+	if cfg.Database != "test" || cfg.MigrationsCollection != defaultMigrationsCollection ||
+		cfg.Migrations != 2 || cfg.Head != 7 || cfg.ReadConcern != "majority" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if cfg.RunLockTTL != time.Minute || !cfg.RunLockWait {
+		t.Errorf("Unexpected lock settings: %+v", cfg)
+	}
+	// only type of resolver is recorded, not its settings
+	if cfg.SecretResolver != "migrate.EnvSecrets" {
+		t.Errorf("Unexpected secret resolver: %q", cfg.SecretResolver)
+	}
+	if len(cfg.CommandDeny) != 1 || len(cfg.NamespaceInclude) != 1 ||
+		len(cfg.NamespaceExclude) != 1 || cfg.NamespaceExclude[0] != "regexp:^tmp_" {
+		t.Errorf("Unexpected filters: %+v", cfg)
+	}
+}
@@ -0,0 +1,235 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultIDIndex = "_id_"
+
+// Schema is an exported snapshot of database schema: collections with their validators and indexes.
+type Schema struct {
+	Collections []CollectionSchema `bson:"collections"`
+}
+
+// CollectionSchema describes collection in Schema.
+type CollectionSchema struct {
+	Name      string        `bson:"name"`
+	Validator bson.D        `bson:"validator,omitempty"`
+	Indexes   []IndexSchema `bson:"indexes,omitempty"`
+}
+
+// IndexSchema describes index in Schema.
+type IndexSchema struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique,omitempty"`
+	Sparse                  bool   `bson:"sparse,omitempty"`
+	PartialFilterExpression bson.D `bson:"partialFilterExpression,omitempty"`
+	ExpireAfterSeconds      *int32 `bson:"expireAfterSeconds,omitempty"`
+}
+
+// ExportSchema takes snapshot of schema of collections of db, except ones listed in exclude.
+func ExportSchema(ctx context.Context, db *mongo.Database, exclude ...string) (Schema, error) {
+	cursor, err := db.ListCollections(ctx, bson.D{{Key: "type", Value: "collection"}})
+	if err != nil {
+		return Schema{}, err
+	}
+
+	var specs []struct {
+		Name    string `bson:"name"`
+		Options struct {
+			Validator bson.D `bson:"validator"`
+		} `bson:"options"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return Schema{}, err
+	}
+
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	var schema Schema
+	for _, spec := range specs {
+		if excluded[spec.Name] {
+			continue
+		}
+
+		cursor, err := db.Collection(spec.Name).Indexes().List(ctx)
+		if err != nil {
+			return Schema{}, err
+		}
+		var indexes []IndexSchema
+		if err := cursor.All(ctx, &indexes); err != nil {
+			return Schema{}, err
+		}
+
+		schema.Collections = append(schema.Collections, CollectionSchema{
+			Name:      spec.Name,
+			Validator: spec.Options.Validator,
+			Indexes:   indexes,
+		})
+	}
+
+	schema.sort()
+	return schema, nil
+}
+
+func (s Schema) sort() {
+	sort.Slice(s.Collections, func(i, j int) bool {
+		return s.Collections[i].Name < s.Collections[j].Name
+	})
+	for _, coll := range s.Collections {
+		sort.Slice(coll.Indexes, func(i, j int) bool {
+			return coll.Indexes[i].Name < coll.Indexes[j].Name
+		})
+	}
+}
+
+// DiffSchema returns commands which turn database with schema from into database with schema to ("up")
+// and commands which revert them ("down"). Collections missing in to are not dropped
+// to not lose data accidentally, such changes should be written by hand.
+// Returned commands are meant to be reviewed by developer, see WriteSchemaMigration.
+func DiffSchema(from, to Schema) (up, down []bson.D) {
+	existing := make(map[string]CollectionSchema, len(from.Collections))
+	for _, coll := range from.Collections {
+		existing[coll.Name] = coll
+	}
+
+	// each step of "up" is reverted by its own group of commands, groups run in backward order
+	var downSteps [][]bson.D
+	step := func(upCommands []bson.D, downCommands ...bson.D) {
+		up = append(up, upCommands...)
+		if len(downCommands) > 0 {
+			downSteps = append(downSteps, downCommands)
+		}
+	}
+
+	for _, coll := range to.Collections {
+		old, ok := existing[coll.Name]
+		if !ok {
+			create := bson.D{{Key: "create", Value: coll.Name}}
+			if len(coll.Validator) > 0 {
+				create = append(create, bson.E{Key: "validator", Value: coll.Validator})
+			}
+			step([]bson.D{create}, bson.D{{Key: "drop", Value: coll.Name}})
+		} else if !equalBSON(old.Validator, coll.Validator) {
+			step([]bson.D{collModValidator(coll.Name, coll.Validator)}, collModValidator(coll.Name, old.Validator))
+		}
+
+		oldIndexes := make(map[string]IndexSchema, len(old.Indexes))
+		for _, idx := range old.Indexes {
+			oldIndexes[idx.Name] = idx
+		}
+		newIndexes := make(map[string]bool, len(coll.Indexes))
+
+		for _, idx := range coll.Indexes {
+			newIndexes[idx.Name] = true
+			if idx.Name == defaultIDIndex {
+				continue
+			}
+
+			oldIdx, exists := oldIndexes[idx.Name]
+			switch {
+			case exists && equalBSON(oldIdx, idx):
+			case exists:
+				step([]bson.D{dropIndexCommand(coll.Name, idx.Name), createIndexCommand(coll.Name, idx)},
+					dropIndexCommand(coll.Name, idx.Name), createIndexCommand(coll.Name, oldIdx))
+			case ok:
+				step([]bson.D{createIndexCommand(coll.Name, idx)}, dropIndexCommand(coll.Name, idx.Name))
+			default:
+				// index of created collection is dropped with it
+				step([]bson.D{createIndexCommand(coll.Name, idx)})
+			}
+		}
+
+		for _, idx := range old.Indexes {
+			// "_id" index exists in every collection and can't be dropped
+			if !newIndexes[idx.Name] && idx.Name != defaultIDIndex {
+				step([]bson.D{dropIndexCommand(coll.Name, idx.Name)}, createIndexCommand(coll.Name, idx))
+			}
+		}
+	}
+
+	for i := len(downSteps) - 1; i >= 0; i-- {
+		down = append(down, downSteps[i]...)
+	}
+	return up, down
+}
+
+func collModValidator(collection string, validator bson.D) bson.D {
+	if validator == nil {
+		validator = bson.D{}
+	}
+	return bson.D{{Key: "collMod", Value: collection}, {Key: "validator", Value: validator}}
+}
+
+func createIndexCommand(collection string, idx IndexSchema) bson.D {
+	return bson.D{{Key: "createIndexes", Value: collection}, {Key: "indexes", Value: bson.A{idx}}}
+}
+
+func dropIndexCommand(collection, name string) bson.D {
+	return bson.D{{Key: "dropIndexes", Value: collection}, {Key: "index", Value: name}}
+}
+
+func equalBSON(a, b interface{}) bool {
+	ra, errA := bson.Marshal(bson.D{{Key: "v", Value: a}})
+	rb, errB := bson.Marshal(bson.D{{Key: "v", Value: b}})
+	return errA == nil && errB == nil && string(ra) == string(rb)
+}
+
+// WriteSchemaMigration writes "up" and "down" commands into migration files of dir
+// in format loaded by NewFileMigrations. It returns names of written files.
+func WriteSchemaMigration(dir string, version uint64, description string, up, down []bson.D) ([]string, error) {
+	base := filepath.Join(dir, fmt.Sprintf("%d_%s", version, description))
+	files := []struct {
+		name     string
+		commands []bson.D
+	}{
+		{name: base + upFileSuffix, commands: up},
+		{name: base + downFileSuffix, commands: down},
+	}
+
+	var written []string
+	for _, file := range files {
+		if len(file.commands) == 0 {
+			continue
+		}
+
+		data, err := marshalCommands(file.commands)
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(file.name, data, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, file.name)
+	}
+
+	return written, nil
+}
+
+func marshalCommands(commands []bson.D) ([]byte, error) {
+	data := []byte("[\n")
+	for i, command := range commands {
+		doc, err := bson.MarshalExtJSONIndent(command, false, false, "  ", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, "  "...)
+		data = append(data, doc...)
+		if i < len(commands)-1 {
+			data = append(data, ',')
+		}
+		data = append(data, '\n')
+	}
+	return append(data, "]\n"...), nil
+}
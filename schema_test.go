@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDiffSchema(t *testing.T) {
+	from := Schema{Collections: []CollectionSchema{
+		{Name: "users", Indexes: []IndexSchema{
+			{Name: "_id_", Key: bson.D{{Key: "_id", Value: 1}}},
+			{Name: "login_1", Key: bson.D{{Key: "login", Value: 1}}},
+			{Name: "old_1", Key: bson.D{{Key: "old", Value: 1}}},
+		}},
+	}}
+	to := Schema{Collections: []CollectionSchema{
+		{Name: "users", Validator: bson.D{{Key: "login", Value: bson.D{{Key: "$type", Value: "string"}}}}, Indexes: []IndexSchema{
+			{Name: "_id_", Key: bson.D{{Key: "_id", Value: 1}}},
+			{Name: "login_1", Key: bson.D{{Key: "login", Value: 1}}, Unique: true},
+		}},
+		{Name: "orders", Indexes: []IndexSchema{
+			{Name: "_id_", Key: bson.D{{Key: "_id", Value: 1}}},
+			{Name: "user_1", Key: bson.D{{Key: "user", Value: 1}}},
+		}},
+	}}
+
+	up, down := DiffSchema(from, to)
+	expectedUp := []string{"collMod", "dropIndexes", "createIndexes", "dropIndexes", "create", "createIndexes"}
+	expectedDown := []string{"drop", "createIndexes", "dropIndexes", "createIndexes", "collMod"}
+	if names := commandNames(up); !equalStrings(names, expectedUp) {
+		t.Errorf("Unexpected up commands: %v", names)
+	}
+	if names := commandNames(down); !equalStrings(names, expectedDown) {
+		t.Errorf("Unexpected down commands: %v", names)
+	}
+
+	up, down = DiffSchema(to, to)
+	if len(up) != 0 || len(down) != 0 {
+		t.Errorf("Unexpected commands for equal schemas: %v %v", up, down)
+	}
+}
+
+func TestDiffSchemaWithoutIDIndex(t *testing.T) {
+	from := Schema{Collections: []CollectionSchema{
+		{Name: "users", Indexes: []IndexSchema{{Name: "_id_", Key: bson.D{{Key: "_id", Value: 1}}}}},
+	}}
+	// hand-written schemas may omit "_id" index
+	to := Schema{Collections: []CollectionSchema{{Name: "users"}}}
+
+	up, down := DiffSchema(from, to)
+	if len(up) != 0 || len(down) != 0 {
+		t.Errorf("Unexpected commands: %v %v", up, down)
+	}
+}
+
+func TestWriteSchemaMigration(t *testing.T) {
+	dir := t.TempDir()
+	up, down := DiffSchema(Schema{}, Schema{Collections: []CollectionSchema{{Name: "users"}}})
+	written, err := WriteSchemaMigration(dir, 3, "create_users", up, down)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(written) != 2 {
+		t.Errorf("Unexpected written files: %v", written)
+		return
+	}
+
+	migrations, err := NewFileMigrations(os.DirFS(dir), ".")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if len(migrations) != 1 || migrations[0].Version != 3 || migrations[0].Down == nil {
+		t.Errorf("Unexpected migrations: %+v", migrations)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "3_create_users.up.json")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func commandNames(commands []bson.D) []string {
+	names := make([]string, 0, len(commands))
+	for _, command := range commands {
+		names = append(names, command[0].Key)
+	}
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// secretKey is a key of placeholder document {"$secret": "<name>"} replaced by secret value when command runs.
+const secretKey = "$secret"
+
+// ErrSecretNotFound returned by secret resolvers for unknown secrets.
+var ErrSecretNotFound = errors.New("migrate: secret not found")
+
+// SecretResolver returns values of secrets referenced by commands of migrations, see SetSecretResolver.
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, name string) (string, error)
+}
+
+// SecretResolverFunc is an adapter to use function as SecretResolver.
+type SecretResolverFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// SetSecretResolver sets resolver of secret placeholders. Commands issued via RunCommand (including commands
+// of migration files) may contain placeholder documents {"$secret": "<name>"}, e.g.
+//
+//	{"createUser": "reporter", "pwd": {"$secret": "REPORTER_PASSWORD"}, "roles": ["read"]}
+//
+// Placeholders are replaced by secret values right before execution, so secrets are not stored in files,
+// history records or dry run output. In files placeholder may be written as {{ secret "REPORTER_PASSWORD" }}.
+func (m *Migrate) SetSecretResolver(resolver SecretResolver) {
+	m.secrets = resolver
+}
+
+// EnvSecrets resolves secrets from environment variables named by secret name with Prefix.
+type EnvSecrets struct {
+	Prefix string
+}
+
+func (e EnvSecrets) ResolveSecret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(e.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrSecretNotFound, name)
+	}
+
+	return value, nil
+}
+
+// FileSecrets resolves secrets from files of Dir named by secret name, e.g. mounted Kubernetes secrets.
+// Trailing newline of file is trimmed.
+type FileSecrets struct {
+	Dir string
+}
+
+func (f FileSecrets) ResolveSecret(_ context.Context, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) {
+		return "", fmt.Errorf("migrate: invalid secret name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(f.Dir, name))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf("%w: %q", ErrSecretNotFound, name)
+	case err != nil:
+		return "", fmt.Errorf("migrate: read secret %q: %w", name, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultSecrets resolves secrets from HashiCorp Vault KV version 2 engine. Secret name is
+// "<path>#<key>", e.g. "db/reporter#password".
+type VaultSecrets struct {
+	// Address is Vault address, e.g. "https://vault:8200". Token is used for authentication.
+	Address string
+	Token   string
+
+	// Mount is a mount path of KV engine, by default "secret".
+	Mount string
+
+	// Client performs requests, by default http.DefaultClient.
+	Client *http.Client
+}
+
+func (v VaultSecrets) ResolveSecret(ctx context.Context, name string) (string, error) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("migrate: invalid secret name %q, expected \"<path>#<key>\"", name)
+	}
+	mount, client := v.Mount, v.Client
+	if mount == "" {
+		mount = "secret"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	endpoint, err := url.JoinPath(v.Address, "v1", mount, "data", path)
+	if err != nil {
+		return "", fmt.Errorf("migrate: vault address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("migrate: read secret %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %q", ErrSecretNotFound, name)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("migrate: read secret %q: vault responded %s", name, resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("migrate: read secret %q: %w", name, err)
+	}
+	value, ok := body.Data.Data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrSecretNotFound, name)
+	}
+
+	return value, nil
+}
+
+// resolveSecrets returns copy of command with placeholders replaced by secret values.
+// Command without placeholders is returned as is.
+func resolveSecrets(ctx context.Context, resolver SecretResolver, command bson.D) (bson.D, error) {
+	resolved, _, err := resolveValue(ctx, resolver, command)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved.(bson.D), nil
+}
+
+// resolveValue replaces placeholders in value, changed reports whether value was copied.
+func resolveValue(ctx context.Context, resolver SecretResolver, value interface{}) (ret interface{}, changed bool, err error) {
+	switch v := value.(type) {
+	case bson.D:
+		if len(v) == 1 && v[0].Key == secretKey {
+			name, ok := v[0].Value.(string)
+			if !ok {
+				return nil, false, errors.New("migrate: secret name must be a string")
+			}
+			if resolver == nil {
+				return nil, false, fmt.Errorf("migrate: secret %q referenced but secret resolver is not set", name)
+			}
+			secret, err := resolver.ResolveSecret(ctx, name)
+			return secret, true, err
+		}
+
+		var doc bson.D
+		for i, e := range v {
+			resolved, changed, err := resolveValue(ctx, resolver, e.Value)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && doc == nil {
+				doc = append(bson.D(nil), v...)
+			}
+			if changed {
+				doc[i].Value = resolved
+			}
+		}
+		if doc == nil {
+			return v, false, nil
+		}
+		return doc, true, nil
+	case bson.A:
+		var arr bson.A
+		for i, item := range v {
+			resolved, changed, err := resolveValue(ctx, resolver, item)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && arr == nil {
+				arr = append(bson.A(nil), v...)
+			}
+			if changed {
+				arr[i] = resolved
+			}
+		}
+		if arr == nil {
+			return v, false, nil
+		}
+		return arr, true, nil
+	default:
+		return value, false, nil
+	}
+}
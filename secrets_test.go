@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	ctx := context.Background()
+	resolver := SecretResolverFunc(func(_ context.Context, name string) (string, error) {
+		if name == "PWD" {
+			return "s3cret", nil
+		}
+		return "", ErrSecretNotFound
+	})
+
+	command := bson.D{
+		{Key: "createUser", Value: "reporter"},
+		{Key: "pwd", Value: bson.D{{Key: secretKey, Value: "PWD"}}},
+		{Key: "roles", Value: bson.A{"read", bson.D{{Key: "key", Value: bson.D{{Key: secretKey, Value: "PWD"}}}}}},
+	}
+	resolved, err := resolveSecrets(ctx, resolver, command)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	data, _ := bson.MarshalExtJSON(resolved, false, false)
+	if string(data) != `{"createUser":"reporter","pwd":"s3cret","roles":["read",{"key":"s3cret"}]}` {
+		t.Errorf("Unexpected resolved command: %s", data)
+	}
+	// original command keeps placeholders
+	if _, ok := command[1].Value.(bson.D); !ok {
+		t.Errorf("Command was modified: %v", command)
+	}
+
+	unknown := bson.D{{Key: "pwd", Value: bson.D{{Key: secretKey, Value: "OTHER"}}}}
+	if _, err := resolveSecrets(ctx, resolver, unknown); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := resolveSecrets(ctx, nil, unknown); err == nil {
+		t.Errorf("Unexpected nil error without resolver")
+	}
+}
+
+func TestSecretPlaceholderInFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/1_user.up.json": {Data: []byte(`{"createUser": "reporter", "pwd": {{ secret "PWD" }}, "roles": []}`)},
+	}
+	migrations, err := NewFileMigrations(fsys, "m")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	m := NewMigrate(nil)
+	m.SetSecretResolver(EnvSecrets{})
+	var commands []bson.D
+	ctx := m.runContext(context.Background(), migrations[0], directionUp)
+	state, _ := runFromContext(ctx)
+	state.dryRun = &commands
+	if err := migrations[0].Up(ctx, nil); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	data, _ := bson.MarshalExtJSON(commands[0], false, false)
+	if string(data) != `{"createUser":"reporter","pwd":{"$secret":"PWD"},"roles":[]}` {
+		t.Errorf("Unexpected dry run command: %s", data)
+	}
+}
+
+func TestFileAndEnvSecrets(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pwd"), []byte("from-file\n"), 0o600); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if value, err := (FileSecrets{Dir: dir}).ResolveSecret(ctx, "pwd"); err != nil || value != "from-file" {
+		t.Errorf("Unexpected file secret: %q %v", value, err)
+	}
+	if _, err := (FileSecrets{Dir: dir}).ResolveSecret(ctx, "../pwd"); err == nil {
+		t.Errorf("Unexpected nil error for path outside of dir")
+	}
+	if _, err := (FileSecrets{Dir: dir}).ResolveSecret(ctx, "missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	t.Setenv("TEST_SECRET_PWD", "from-env")
+	if value, err := (EnvSecrets{Prefix: "TEST_SECRET_"}).ResolveSecret(ctx, "PWD"); err != nil || value != "from-env" {
+		t.Errorf("Unexpected env secret: %q %v", value, err)
+	}
+}
+
+func TestVaultSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data/db/reporter" || r.Header.Get("X-Vault-Token") != "token" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"password": "from-vault"}}}`))
+	}))
+	defer server.Close()
+
+	vault := VaultSecrets{Address: server.URL, Token: "token", Mount: "kv"}
+	ctx := context.Background()
+	if value, err := vault.ResolveSecret(ctx, "db/reporter#password"); err != nil || value != "from-vault" {
+		t.Errorf("Unexpected vault secret: %q %v", value, err)
+	}
+	if _, err := vault.ResolveSecret(ctx, "db/other#password"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := vault.ResolveSecret(ctx, "db/reporter"); err == nil {
+		t.Errorf("Unexpected nil error for name without key")
+	}
+}
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInterrupted returned when run was stopped by signal at migration boundary, see NotifyContext.
+var ErrInterrupted = errors.New("migrate: run interrupted")
+
+// NotifyContext installs handler of signals (SIGINT and SIGTERM by default) which gracefully stops runs:
+// after the first signal migration in progress completes, next migration is not started and ErrInterrupted is returned.
+// Interruption is recorded to run control document (see RunControl), so it's clear where run stopped.
+// The second signal cancels returned context, so migration in progress is aborted.
+// Calling returned cancel function removes the handler, cancels context and forgets received signal,
+// so the next run of migrator is not interrupted.
+func (m *Migrate) NotifyContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				if m.stopSignal.CompareAndSwap(nil, &sig) {
+					m.report(MsgSignalStopping, sig)
+					continue
+				}
+				m.report(MsgSignalAborting, sig)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(ch)
+		cancel()
+		// handler must not record signal after reset
+		<-done
+		m.stopSignal.Store(nil)
+	}
+}
+
+// checkInterrupted returns ErrInterrupted if run was stopped by signal before migration of provided version.
+func (m *Migrate) checkInterrupted(ctx context.Context, version uint64) error {
+	sig := m.stopSignal.Load()
+	if sig == nil {
+		return nil
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "interrupted_at", Value: time.Now().UTC()},
+		{Key: "interrupted_version", Value: version},
+		{Key: "interrupted_by", Value: (*sig).String()},
+	}}}
+	if err := m.updateControl(ctx, update); err != nil {
+		m.report(MsgInterruptionFailed, err)
+	}
+
+	return fmt.Errorf("%w by %v before migration %d", ErrInterrupted, *sig, version)
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestNotifyContext(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	m := NewMigrate(client.Database("test"))
+	runCtx, stop := m.NotifyContext(ctx, syscall.SIGUSR1)
+	defer stop()
+
+	if err := m.checkInterrupted(runCtx, 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	for i := 0; i < 100 && m.stopSignal.Load() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runCtx.Err() != nil {
+		t.Errorf("Context canceled by the first signal")
+		return
+	}
+
+	// recording of interruption fails fast without server
+	shortCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := m.checkInterrupted(shortCtx, 2); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	select {
+	case <-runCtx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Context is not canceled by the second signal")
+		return
+	}
+
+	// the next run is not interrupted by signal of previous one
+	stop()
+	if err := m.checkInterrupted(ctx, 3); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionStats describes footprint of collection used by migrator for bookkeeping.
+type CollectionStats struct {
+	Name   string
+	Exists bool
+
+	// Count is a number of records. Oldest and Newest are timestamps of the first and last inserted records.
+	Count  int64
+	Oldest time.Time
+	Newest time.Time
+
+	// Size is uncompressed size of records, StorageSize is size allocated on disk, both in bytes.
+	Size        int64
+	StorageSize int64
+
+	// Indexes is a number of indexes, TotalIndexSize is their size in bytes.
+	Indexes        int
+	TotalIndexSize int64
+}
+
+// BookkeepingCollections returns names of collections used by migrator itself: migrations history, checkpoints,
+// locks, audit and materialized views metadata.
+func (m *Migrate) BookkeepingCollections() []string {
+	return []string{m.migrationsCollection, m.checkpointsCollection, m.locksCollection, m.auditCollection, m.viewsCollection}
+}
+
+// MetaStats reports footprint of collections used by migrator itself, see BookkeepingCollections.
+func (m *Migrate) MetaStats(ctx context.Context) ([]CollectionStats, error) {
+	names := m.BookkeepingCollections()
+
+	stats := make([]CollectionStats, 0, len(names))
+	for _, name := range names {
+		s, err := m.collectionStats(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+func (m *Migrate) collectionStats(ctx context.Context, name string) (CollectionStats, error) {
+	stats := CollectionStats{Name: name}
+
+	exist, err := m.isCollectionExist(ctx, name)
+	if err != nil || !exist {
+		return stats, err
+	}
+	stats.Exists = true
+
+	pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}}}
+	cursor, err := m.db.Collection(name).Aggregate(ctx, pipeline)
+	if err != nil {
+		return stats, err
+	}
+
+	var results []struct {
+		StorageStats struct {
+			Count          int64 `bson:"count"`
+			Size           int64 `bson:"size"`
+			StorageSize    int64 `bson:"storageSize"`
+			Indexes        int   `bson:"nindexes"`
+			TotalIndexSize int64 `bson:"totalIndexSize"`
+		} `bson:"storageStats"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return stats, err
+	}
+	for _, result := range results {
+		stats.Count += result.StorageStats.Count
+		stats.Size += result.StorageStats.Size
+		stats.StorageSize += result.StorageStats.StorageSize
+		stats.TotalIndexSize += result.StorageStats.TotalIndexSize
+		stats.Indexes = result.StorageStats.Indexes
+	}
+
+	if stats.Oldest, err = m.recordTimestamp(ctx, name, 1); err != nil {
+		return stats, err
+	}
+	if stats.Newest, err = m.recordTimestamp(ctx, name, -1); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// recordTimestamp returns timestamp of the first (order=1) or the last (order=-1) inserted record.
+func (m *Migrate) recordTimestamp(ctx context.Context, name string, order int) (time.Time, error) {
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "_id", Value: order}}).
+		SetProjection(bson.D{{Key: "timestamp", Value: 1}})
+
+	var rec struct {
+		Timestamp time.Time `bson:"timestamp"`
+	}
+	err := m.db.Collection(name).FindOne(ctx, bson.D{}, opts).Decode(&rec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+
+	return rec.Timestamp, err
+}
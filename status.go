@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MigrationStatus describes state of migration version in database.
+type MigrationStatus struct {
+	Version     uint64
+	Description string
+
+	// Applied reports whether version is recorded in history and not newer than current database version,
+	// so migrations missed by out-of-order deployment (see SetMissedMigrations) are not applied.
+	// AppliedAt is the time of the latest record of version, it's zero for pending migrations.
+	Applied   bool
+	AppliedAt time.Time
+
+	// Missing reports that version is recorded in history but not registered.
+	Missing bool
+
+	// Dirty reports that migration of version failed halfway, see DirtyVersion.
+	Dirty bool
+}
+
+// MigrationState is a summary of MigrationStatus suitable for rendering, e.g. "STATE" column of status table.
+type MigrationState string
+
+const (
+	StatePending MigrationState = "pending"
+	StateApplied MigrationState = "applied"
+	StateMissing MigrationState = "missing"
+	StateDirty   MigrationState = "dirty"
+)
+
+// State summarizes status. Dirty state takes precedence over missing and applied ones.
+func (s MigrationStatus) State() MigrationState {
+	switch {
+	case s.Dirty:
+		return StateDirty
+	case s.Missing:
+		return StateMissing
+	case s.Applied:
+		return StateApplied
+	default:
+		return StatePending
+	}
+}
+
+// Status cross-references migrations history with registered migrations.
+// It returns status of each registered and each recorded but not registered version in ascending order.
+func (m *Migrate) Status(ctx context.Context) ([]MigrationStatus, error) {
+	current, err := m.currentRecord(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history, err := m.Versions().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrationStatus(m.migrations, history, current.Version), nil
+}
+
+// Plan returns migrations which would be performed by Up(ctx, n) in order of application.
+// See DryRun for commands which they would issue.
+func (m *Migrate) Plan(ctx context.Context, n int) ([]MigrationStatus, error) {
+	current, err := m.currentRecord(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := m.upPlan(ctx, current.Version, n)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		plan = append(plan, MigrationStatus{Version: migration.Version, Description: migration.Description})
+	}
+
+	return plan, nil
+}
+
+func migrationStatus(migrations []Migration, history []VersionRecord, current uint64) []MigrationStatus {
+	byVersion := make(map[uint64]*MigrationStatus)
+	for _, migration := range migrations {
+		byVersion[migration.Version] = &MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+	}
+
+	for i, rec := range history {
+		if rec.Dirty {
+			// only the last record may make database dirty, older dirty records are superseded
+			if status, ok := byVersion[rec.Version]; ok && i == len(history)-1 {
+				status.Dirty = true
+			}
+			continue
+		}
+		if rec.Version == 0 {
+			continue
+		}
+		status, ok := byVersion[rec.Version]
+		if !ok {
+			status = &MigrationStatus{
+				Version:     rec.Version,
+				Description: rec.Description,
+				Missing:     true,
+			}
+			byVersion[rec.Version] = status
+		}
+		if rec.Version <= current {
+			status.Applied = true
+			status.AppliedAt = rec.Timestamp
+		}
+	}
+
+	ret := make([]MigrationStatus, 0, len(byVersion))
+	for _, status := range byVersion {
+		ret = append(ret, *status)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret
+}
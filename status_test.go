@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMigrationStatus(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2, t3, t4 := t1.Add(time.Hour), t1.Add(2*time.Hour), t1.Add(3*time.Hour)
+
+	migrations := []Migration{
+		{Version: 3, Description: "three"},
+		{Version: 1, Description: "one"},
+		{Version: 4, Description: "four"},
+	}
+	history := []VersionRecord{
+		{Version: 1, Description: "one", Timestamp: t1},
+		{Version: 2, Description: "removed", Timestamp: t2},
+		{Version: 3, Description: "three", Timestamp: t3},
+		{Version: 1, Description: "one", Timestamp: t4},
+		{Version: 2, Description: "removed", Timestamp: t4},
+	}
+
+	expected := []MigrationStatus{
+		{Version: 1, Description: "one", Applied: true, AppliedAt: t4},
+		{Version: 2, Description: "removed", Applied: true, AppliedAt: t4, Missing: true},
+		{Version: 3, Description: "three"},
+		{Version: 4, Description: "four"},
+	}
+	if actual := migrationStatus(migrations, history, 2); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected status: %+v", actual)
+	}
+}
+
+func TestMigrationStatusDirty(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}}
+	history := []VersionRecord{{Version: 1}, {Version: 2, Dirty: true}}
+
+	status := migrationStatus(migrations, history, 1)
+	if len(status) != 2 || status[0].Dirty || !status[1].Dirty || status[1].Applied || !status[1].AppliedAt.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	// dirty record superseded by force
+	history = append(history, VersionRecord{Version: 2})
+	status = migrationStatus(migrations, history, 2)
+	if status[1].Dirty || !status[1].Applied {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestMigrationStatusMissed(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+	history := []VersionRecord{{Version: 1}, {Version: 3}}
+
+	status := migrationStatus(migrations, history, 3)
+	if !status[0].Applied || status[1].Applied || status[1].State() != StatePending || !status[2].Applied {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// namespaceNotFoundCode is a code of server error returned for stats of missing collection.
+const namespaceNotFoundCode = 26
+
+// ErrStorageLimit returned when projected storage of database after migration exceeds StorageLimit.
+var ErrStorageLimit = errors.New("migrate: projected storage exceeds limit")
+
+// StorageGrowth describes data which migration is expected to write, e.g. copy or backfill of collection.
+type StorageGrowth struct {
+	// Collection is a name of collection which documents are copied or backfilled.
+	Collection string
+
+	// Filter selects affected documents, nil means all documents of collection.
+	Filter interface{}
+
+	// Factor scales average document size of collection, e.g. 0.2 for backfill adding fields
+	// of about 20% of document size. Default is 1, i.e. full copy of documents.
+	Factor float64
+}
+
+// StorageLimit configures guardrails checked before migrations declaring Migration.Growth start.
+// Zero fields are not checked.
+type StorageLimit struct {
+	// MaxDataSize is a limit of uncompressed data size of database in bytes.
+	MaxDataSize int64
+
+	// MaxStorageSize is a limit of storage allocated by database for data and indexes in bytes,
+	// e.g. disk capacity of Atlas cluster tier.
+	MaxStorageSize int64
+
+	// MinFreeDisk is a number of bytes which must stay free on filesystem of database server.
+	// It's checked only if server reports filesystem usage.
+	MinFreeDisk int64
+}
+
+// StorageProjection describes storage of database before and after expected growth. Sizes are in bytes.
+type StorageProjection struct {
+	DataSize    int64
+	StorageSize int64
+
+	// FreeDisk is free space on filesystem of database server, zero if server does not report it.
+	FreeDisk int64
+
+	// Growth is expected size of written data estimated from document counts and average document sizes.
+	Growth int64
+}
+
+// SetStorageLimit sets guardrails checked before migrations which declare Migration.Growth,
+// so migration fails early with ErrStorageLimit instead of filling the disk halfway through.
+func (m *Migrate) SetStorageLimit(limit StorageLimit) {
+	m.storageLimit = limit
+}
+
+// ProjectStorage estimates storage of database after growth using document counts and average document sizes.
+func ProjectStorage(ctx context.Context, db *mongo.Database, growth ...StorageGrowth) (StorageProjection, error) {
+	for _, g := range growth {
+		if err := checkNamespace(ctx, g.Collection); err != nil {
+			return StorageProjection{}, err
+		}
+	}
+
+	var stats struct {
+		DataSize    float64 `bson:"dataSize"`
+		StorageSize float64 `bson:"storageSize"`
+		IndexSize   float64 `bson:"indexSize"`
+		FSUsedSize  float64 `bson:"fsUsedSize"`
+		FSTotalSize float64 `bson:"fsTotalSize"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}, {Key: "scale", Value: 1}}).Decode(&stats); err != nil {
+		return StorageProjection{}, fmt.Errorf("migrate: read database stats: %w", err)
+	}
+
+	projection := StorageProjection{
+		DataSize:    int64(stats.DataSize),
+		StorageSize: int64(stats.StorageSize + stats.IndexSize),
+	}
+	if stats.FSTotalSize > 0 {
+		projection.FreeDisk = int64(stats.FSTotalSize - stats.FSUsedSize)
+	}
+	for _, g := range growth {
+		size, err := growthSize(ctx, db.Collection(g.Collection), g)
+		if err != nil {
+			return StorageProjection{}, err
+		}
+		projection.Growth += size
+	}
+
+	return projection, nil
+}
+
+// growthSize returns number of affected documents multiplied by average document size of collection.
+func growthSize(ctx context.Context, coll *mongo.Collection, growth StorageGrowth) (int64, error) {
+	pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}}}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == namespaceNotFoundCode {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("migrate: read stats of %q: %w", coll.Name(), err)
+	}
+
+	var results []struct {
+		StorageStats struct {
+			Count int64 `bson:"count"`
+			Size  int64 `bson:"size"`
+		} `bson:"storageStats"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, fmt.Errorf("migrate: read stats of %q: %w", coll.Name(), err)
+	}
+
+	// shards report their parts separately
+	var count, size int64
+	for _, result := range results {
+		count += result.StorageStats.Count
+		size += result.StorageStats.Size
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	avgObjSize := float64(size) / float64(count)
+	if growth.Filter != nil {
+		if count, err = coll.CountDocuments(ctx, growth.Filter); err != nil {
+			return 0, fmt.Errorf("migrate: count documents of %q: %w", coll.Name(), err)
+		}
+	}
+
+	factor := growth.Factor
+	if factor <= 0 {
+		factor = 1
+	}
+	return int64(float64(count) * avgObjSize * factor), nil
+}
+
+// Check returns error wrapping ErrStorageLimit if projection violates limit.
+func (l StorageLimit) Check(projection StorageProjection) error {
+	var errs []error
+	if l.MaxDataSize > 0 && projection.DataSize+projection.Growth > l.MaxDataSize {
+		errs = append(errs, fmt.Errorf("%w: data size %d + %d > %d", ErrStorageLimit,
+			projection.DataSize, projection.Growth, l.MaxDataSize))
+	}
+	if l.MaxStorageSize > 0 && projection.StorageSize+projection.Growth > l.MaxStorageSize {
+		errs = append(errs, fmt.Errorf("%w: storage size %d + %d > %d", ErrStorageLimit,
+			projection.StorageSize, projection.Growth, l.MaxStorageSize))
+	}
+	if l.MinFreeDisk > 0 && projection.FreeDisk > 0 && projection.FreeDisk-projection.Growth < l.MinFreeDisk {
+		errs = append(errs, fmt.Errorf("%w: free disk %d - %d < %d", ErrStorageLimit,
+			projection.FreeDisk, projection.Growth, l.MinFreeDisk))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkStorage checks storage limit before migration which declares expected growth.
+func (m *Migrate) checkStorage(ctx context.Context, migration Migration) error {
+	if len(migration.Growth) == 0 || m.storageLimit == (StorageLimit{}) {
+		return nil
+	}
+
+	projection, err := ProjectStorage(ctx, m.db, migration.Growth...)
+	if err != nil {
+		return err
+	}
+	if err := m.storageLimit.Check(projection); err != nil {
+		return fmt.Errorf("migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
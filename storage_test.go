@@ -0,0 +1,33 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStorageLimitCheck(t *testing.T) {
+	projection := StorageProjection{DataSize: 600, StorageSize: 300, FreeDisk: 1000, Growth: 500}
+
+	for _, tc := range []struct {
+		limit    StorageLimit
+		exceeded bool
+	}{
+		{StorageLimit{}, false},
+		{StorageLimit{MaxDataSize: 1000}, true},
+		{StorageLimit{MaxDataSize: 1100}, false},
+		{StorageLimit{MaxStorageSize: 700}, true},
+		{StorageLimit{MaxStorageSize: 800}, false},
+		{StorageLimit{MinFreeDisk: 600}, true},
+		{StorageLimit{MinFreeDisk: 500}, false},
+	} {
+		err := tc.limit.Check(projection)
+		if errors.Is(err, ErrStorageLimit) != tc.exceeded {
+			t.Errorf("Unexpected result for limit %+v: %v", tc.limit, err)
+		}
+	}
+
+	// free disk is not checked if server does not report it
+	if err := (StorageLimit{MinFreeDisk: 1}).Check(StorageProjection{Growth: 10}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
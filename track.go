@@ -0,0 +1,40 @@
+package migrate
+
+// SetTrack makes migrator version independent migration track, e.g. "analytics", so loosely coupled subsystems
+// sharing database keep their own version numbers. Migrations history and checkpoints collections get
+// "_<name>" suffix, while locks, run control and audit collections are shared by all tracks: collection locks
+// (see SetCollectionLocks) still serialize tracks touching the same collections, pause requests apply to all tracks
+// and audit records are marked by track. Run lock (see SetRunLock) is held per track, so tracks run concurrently.
+// Call it after SetCollectionAffixes, collection names set explicitly afterwards take precedence.
+func (m *Migrate) SetTrack(name string) {
+	m.track = name
+	if name == "" {
+		return
+	}
+	m.migrationsCollection += "_" + name
+	m.checkpointsCollection += "_" + name
+}
+
+// Track returns name of migration track, it is empty for default one. See SetTrack.
+func (m *Migrate) Track() string {
+	return m.track
+}
+
+// trackID returns id of document of shared collection which is kept per track.
+func (m *Migrate) trackID(id string) string {
+	if m.track == "" {
+		return id
+	}
+
+	return id + ":" + m.track
+}
+
+// trackFilter returns value of "track" field of audit documents written by migrator,
+// nil matches documents of default track which have no such field.
+func (m *Migrate) trackFilter() interface{} {
+	if m.track == "" {
+		return nil
+	}
+
+	return m.track
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestSetTrack(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetCollectionAffixes("billing_", "")
+	m.SetTrack("analytics")
+
+	if m.Track() != "analytics" {
+		t.Errorf("Unexpected track: %s", m.Track())
+	}
+	expected := []string{"billing_migrations_analytics", "billing_migrations_checkpoints_analytics",
+		"billing_migrations_lock", "billing_migrations_audit", "billing_migrations_views"}
+	collections := m.BookkeepingCollections()
+	for i, name := range expected {
+		if collections[i] != name {
+			t.Errorf("Unexpected bookkeeping collections: %v", collections)
+			return
+		}
+	}
+	if id := m.trackID(runLockName); id != "run:analytics" {
+		t.Errorf("Unexpected run lock name: %s", id)
+	}
+	if filter := m.trackFilter(); filter != "analytics" {
+		t.Errorf("Unexpected track filter: %v", filter)
+	}
+}
+
+func TestDefaultTrack(t *testing.T) {
+	m := NewMigrate(nil)
+	m.SetTrack("")
+
+	if collections := m.BookkeepingCollections(); collections[0] != defaultMigrationsCollection {
+		t.Errorf("Unexpected bookkeeping collections: %v", collections)
+	}
+	if id := m.trackID(runLockName); id != runLockName {
+		t.Errorf("Unexpected run lock name: %s", id)
+	}
+	if filter := m.trackFilter(); filter != nil {
+		t.Errorf("Unexpected track filter: %v", filter)
+	}
+}
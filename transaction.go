@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTransactionsUnsupported returned when transactions are enabled but server is standalone.
+var ErrTransactionsUnsupported = errors.New("migrate: transactions require replica set or sharded cluster")
+
+// SetTransactions enables running each migration callback together with version record write in one transaction,
+// so failed migration leaves neither partial data changes nor version record. Savepoints and audit record
+// of successful migration are committed in the same transaction. Transaction is retried on transient errors,
+// so callbacks must tolerate re-execution. Note that server limits transactions in duration and size
+// and doesn't allow some commands (e.g. creation of capped collections) inside them.
+// Migrations fail with ErrTransactionsUnsupported on standalone servers.
+func (m *Migrate) SetTransactions(enabled bool) {
+	m.transactions = enabled
+}
+
+// inTransaction runs fn in transaction if they are enabled.
+func (m *Migrate) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.transactions {
+		return fn(ctx)
+	}
+
+	if err := m.checkTransactions(ctx); err != nil {
+		return err
+	}
+
+	session, err := m.db.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("migrate: start session: %w", err)
+	}
+	defer session.EndSession(withoutSession(ctx))
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+func (m *Migrate) checkTransactions(ctx context.Context) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := m.db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return err
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return ErrTransactionsUnsupported
+	}
+
+	return nil
+}
+
+// withoutSession returns context detached from session, so operations run outside of transaction.
+func withoutSession(ctx context.Context) context.Context {
+	return mongo.NewSessionContext(ctx, nil)
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestWithoutSession(t *testing.T) {
+	ctx := context.Background()
+	// client connects lazily, so no server is needed
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	session, err := client.StartSession()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer session.EndSession(ctx)
+
+	sc := mongo.NewSessionContext(ctx, session)
+	if mongo.SessionFromContext(sc) == nil {
+		t.Errorf("Unexpectedly not found session")
+	}
+	if mongo.SessionFromContext(withoutSession(sc)) != nil {
+		t.Errorf("Unexpectedly found session")
+	}
+}
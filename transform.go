@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxBSONSize is the maximum size of BSON document accepted by MongoDB.
+const MaxBSONSize = 16 * 1024 * 1024
+
+const defaultPreflightLimit = 100
+
+// TransformFunc returns replacement for provided document.
+// Nil replacement means that document should be left unchanged.
+type TransformFunc func(doc bson.Raw) (replacement interface{}, err error)
+
+// PreflightOptions configures PreflightTransform.
+type PreflightOptions struct {
+	// MaxSize is a budget for transformed document size in bytes. By default, it is MaxBSONSize.
+	MaxSize int
+
+	// Limit is a maximum number of reported documents, scan stops when it is reached. By default, it is 100.
+	Limit int
+}
+
+// OversizedDocument describes document which would exceed size budget after transformation.
+type OversizedDocument struct {
+	ID   bson.RawValue
+	Size int
+}
+
+func (d OversizedDocument) String() string {
+	return fmt.Sprintf("%s (%d bytes)", d.ID, d.Size)
+}
+
+// PreflightTransform applies fn to documents of coll matching filter without writing results
+// and reports documents which transformed size exceeds budget. Replacements are marshaled with codec
+// settings of migrator which performs current migration, see SetBSONRegistry.
+// Run it before transformation which may grow documents to not fail in the middle of data mutation.
+func PreflightTransform(ctx context.Context, coll *mongo.Collection, filter interface{}, fn TransformFunc, opts PreflightOptions) ([]OversizedDocument, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = MaxBSONSize
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPreflightLimit
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var oversized []OversizedDocument
+	for len(oversized) < opts.Limit && cursor.Next(ctx) {
+		size, err := transformedSize(ctx, cursor.Current, fn)
+		if err != nil {
+			return nil, err
+		}
+		if size > opts.MaxSize {
+			oversized = append(oversized, OversizedDocument{ID: cursor.Current.Lookup("_id"), Size: size})
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return oversized, nil
+}
+
+func transformedSize(ctx context.Context, doc bson.Raw, fn TransformFunc) (int, error) {
+	replacement, err := fn(doc)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: transform document %s: %w", doc.Lookup("_id"), err)
+	}
+	if replacement == nil {
+		return len(doc), nil
+	}
+
+	raw, err := MarshalDocument(ctx, replacement)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: marshal transformed document %s: %w", doc.Lookup("_id"), err)
+	}
+
+	return len(raw), nil
+}
@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTransformedSize(t *testing.T) {
+	doc, err := bson.Marshal(bson.D{{Key: "_id", Value: 1}, {Key: "a", Value: "b"}})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	size, err := transformedSize(context.Background(), doc, func(doc bson.Raw) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if size != len(doc) {
+		t.Errorf("Unexpected size of unchanged document: %v", size)
+	}
+
+	size, err = transformedSize(context.Background(), doc, func(doc bson.Raw) (interface{}, error) {
+		return bson.D{{Key: "_id", Value: 1}, {Key: "a", Value: strings.Repeat("b", 1000)}}, nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if size <= 1000 {
+		t.Errorf("Unexpected size of transformed document: %v", size)
+	}
+}
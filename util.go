@@ -14,17 +14,20 @@ func extractVersionDescription(name string) (uint64, string, error) {
 		return 0, "", fmt.Errorf("can not extract version from %q", base)
 	}
 
-	idx := strings.IndexByte(base, '_')
+	return splitVersionDescription(base[:len(base)-len(".go")])
+}
+
+// splitVersionDescription splits name without extension like "<version>_<description>".
+func splitVersionDescription(name string) (uint64, string, error) {
+	idx := strings.IndexByte(name, '_')
 	if idx == -1 {
-		return 0, "", fmt.Errorf("can not extract version from %q", base)
+		return 0, "", fmt.Errorf("can not extract version from %q", name)
 	}
 
-	version, err := strconv.ParseUint(base[:idx], 10, 64)
+	version, err := strconv.ParseUint(name[:idx], 10, 64)
 	if err != nil {
 		return 0, "", err
 	}
 
-	description := base[idx+1 : len(base)-len(".go")]
-
-	return version, description, nil
+	return version, name[idx+1:], nil
 }
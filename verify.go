@@ -0,0 +1,208 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VerifyFunc checks invariants after "up" callback of migration.
+type VerifyFunc func(ctx context.Context, db *mongo.Database) error
+
+// ErrNoSnapshot returned by time-travel helpers called outside of Verify step.
+var ErrNoSnapshot = errors.New("migrate: pre-migration cluster time is not available")
+
+// ErrVerificationFailed returned when Verify step detects broken invariant.
+var ErrVerificationFailed = errors.New("migrate: verification failed")
+
+// clusterTime returns current operation time of cluster. It is zero value for standalone servers.
+func (m *Migrate) clusterTime(ctx context.Context) (primitive.Timestamp, error) {
+	var reply struct {
+		OperationTime primitive.Timestamp `bson:"operationTime"`
+	}
+	if err := m.db.RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Decode(&reply); err != nil {
+		return primitive.Timestamp{}, err
+	}
+
+	return reply.OperationTime, nil
+}
+
+func (m *Migrate) verify(ctx context.Context, migration Migration) error {
+	if err := migration.Verify(ctx, m.migrationDB(migration.VerifyReadPreference)); err != nil {
+		return fmt.Errorf("migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+// AggregateBefore runs pipeline on collection as it was before current migration started (snapshot read at cluster time)
+// and decodes results into provided slice pointer. It is available only in Verify step and requires replica set or
+// sharded cluster with snapshot reads support. Read preference of collection database is honored.
+func AggregateBefore(ctx context.Context, coll *mongo.Collection, pipeline interface{}, results interface{}) error {
+	state, ok := runFromContext(ctx)
+	if !ok || state.before.IsZero() {
+		return ErrNoSnapshot
+	}
+	if err := checkNamespace(ctx, coll.Name()); err != nil {
+		return err
+	}
+
+	command := bson.D{
+		{Key: "aggregate", Value: coll.Name()},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+		{Key: "readConcern", Value: bson.D{
+			{Key: "level", Value: "snapshot"},
+			{Key: "atClusterTime", Value: state.before},
+		}},
+	}
+	// snapshot read concern can't be set inside transaction
+	opts := options.RunCmd().SetReadPreference(coll.Database().ReadPreference())
+	cursor, err := coll.Database().RunCommandCursor(withoutSession(ctx), command, opts)
+	if err != nil {
+		return fmt.Errorf("migrate: snapshot read of %q: %w", coll.Name(), err)
+	}
+
+	return cursor.All(ctx, results)
+}
+
+// CountBefore returns number of documents matching filter before current migration started. See AggregateBefore.
+func CountBefore(ctx context.Context, coll *mongo.Collection, filter interface{}) (int64, error) {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$count", Value: "count"}},
+	}
+	var results []struct {
+		Count int64 `bson:"count"`
+	}
+	if err := AggregateBefore(ctx, coll, pipeline, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	return results[0].Count, nil
+}
+
+// SumBefore returns sum of numeric field of documents matching filter before current migration started. See AggregateBefore.
+// Values are summed as decimals, so sum of integers is exact unlike sum of doubles.
+func SumBefore(ctx context.Context, coll *mongo.Collection, field string, filter interface{}) (primitive.Decimal128, error) {
+	pipeline := sumPipeline(field, filter)
+	var results []struct {
+		Sum primitive.Decimal128 `bson:"sum"`
+	}
+	if err := AggregateBefore(ctx, coll, pipeline, &results); err != nil {
+		return decimalZero, err
+	}
+	if len(results) == 0 {
+		return decimalZero, nil
+	}
+
+	return results[0].Sum, nil
+}
+
+// decimalZero is a zero decimal with zero exponent, so it's rendered as "0".
+var decimalZero = primitive.NewDecimal128(0x3040000000000000, 0)
+
+// decimalEqual reports whether decimals are numerically equal, e.g. sum of int 5 equals sum of double 5.0
+// which is converted to 5.00000000000000. NaN and infinities are compared by representation.
+func decimalEqual(a, b primitive.Decimal128) bool {
+	x, xExp, xErr := a.BigInt()
+	y, yExp, yErr := b.BigInt()
+	if xErr != nil || yErr != nil {
+		return a == b
+	}
+
+	if xExp < yExp {
+		x, y, xExp, yExp = y, x, yExp, xExp
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(xExp-yExp)), nil)
+	return x.Mul(x, scale).Cmp(y) == 0
+}
+
+// sumPipeline sums values converted to decimal: integers are summed exactly and
+// each double is rounded the same way in both compared sums.
+func sumPipeline(field string, filter interface{}) mongo.Pipeline {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	return mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "sum", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$toDecimal", Value: "$" + field}}}}},
+		}}},
+	}
+}
+
+// VerifyCountPreserved returns Verify step checking that number of documents of collection matching filter
+// is the same as before migration.
+func VerifyCountPreserved(collection string, filter interface{}) VerifyFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if err := checkNamespace(ctx, collection); err != nil {
+			return err
+		}
+		if filter == nil {
+			filter = bson.D{}
+		}
+
+		before, err := CountBefore(ctx, db.Collection(collection), filter)
+		if err != nil {
+			return err
+		}
+		after, err := db.Collection(collection).CountDocuments(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if before != after {
+			return fmt.Errorf("%w: count of %q changed from %d to %d", ErrVerificationFailed, collection, before, after)
+		}
+		return nil
+	}
+}
+
+// VerifySumPreserved returns Verify step checking that sum of numeric field of collection documents
+// is the same as before migration.
+func VerifySumPreserved(collection, field string) VerifyFunc {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if err := checkNamespace(ctx, collection); err != nil {
+			return err
+		}
+		before, err := SumBefore(ctx, db.Collection(collection), field, nil)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := db.Collection(collection).Aggregate(ctx, sumPipeline(field, nil))
+		if err != nil {
+			return err
+		}
+		var results []struct {
+			Sum primitive.Decimal128 `bson:"sum"`
+		}
+		if err := cursor.All(ctx, &results); err != nil {
+			return err
+		}
+		after := decimalZero
+		if len(results) > 0 {
+			after = results[0].Sum
+		}
+
+		if !decimalEqual(before, after) {
+			return fmt.Errorf("%w: sum of %q.%s changed from %v to %v", ErrVerificationFailed, collection, field, before, after)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestAggregateBeforeOutsideVerify(t *testing.T) {
+	ctx := NewMigrate(nil).runContext(context.Background(), Migration{Version: 1}, directionUp)
+	if _, err := CountBefore(ctx, nil, nil); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := SumBefore(context.Background(), nil, "amount", nil); !errors.Is(err, ErrNoSnapshot) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestDecimalEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"5", "5.00000000000000", true},
+		{"1.5E+3", "1500", true},
+		{"0", "0E-6176", true},
+		{"0.1", "0.10000000000000001", false},
+		{"-2", "2", false},
+		{"NaN", "NaN", true},
+	}
+	for _, test := range tests {
+		a, err := primitive.ParseDecimal128(test.a)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		b, err := primitive.ParseDecimal128(test.b)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		if actual := decimalEqual(a, b); actual != test.equal {
+			t.Errorf("Unexpected result for %s and %s: %t", test.a, test.b, actual)
+		}
+	}
+}
+
+func TestVerifyReadPreference(t *testing.T) {
+	ctx := context.Background()
+	// client connects lazily, so no server is needed
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	m := NewMigrate(client.Database("test"))
+	if db := m.migrationDB(nil); db != m.db {
+		t.Errorf("Unexpected new database handle")
+	}
+
+	rp := readpref.SecondaryPreferred(readpref.WithMaxStaleness(90 * time.Second))
+	db := m.migrationDB(rp)
+	if db.ReadPreference() != rp || db.Name() != "test" {
+		t.Errorf("Unexpected read preference: %v", db.ReadPreference())
+	}
+}
+
+func TestMigrationReadPreference(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	defer client.Disconnect(ctx)
+
+	m := NewMigrate(client.Database("test"))
+	rp := readpref.SecondaryPreferred()
+	var passed *readpref.ReadPref
+	migration := Migration{Version: 1, ReadPreference: rp}
+	err = m.call(ctx, migration, func(ctx context.Context, db *mongo.Database) error {
+		passed = db.ReadPreference()
+		return nil
+	}, directionUp)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if passed != rp {
+		t.Errorf("Unexpected read preference: %v", passed)
+	}
+	if m.db.ReadPreference() == rp {
+		t.Errorf("Read preference of migrator database changed")
+	}
+}
@@ -0,0 +1,264 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionStore is a storage of migrations history. Records are kept in order of recording:
+// the latest record which is neither dirty nor out of order defines current version, the latest dirty record marks
+// migration which failed halfway (see DirtyVersion).
+//
+// Migrator does not lock store. Runs of different processes are serialized by run lock (see SetRunLock),
+// which is kept in locks collection of migrator database regardless of store, so store sharing history
+// between several databases should be used with run lock of the same database or without concurrent runs.
+// Writes participate in migration transaction (see SetTransactions) only if store uses the same client
+// as migrator database, other stores write history outside of transaction.
+type VersionStore interface {
+	Versions
+
+	// Last returns the latest record, dirty or not. Found is false if history is empty.
+	Last(ctx context.Context) (rec VersionRecord, found bool, err error)
+
+	// Append adds records to the end of history in provided order.
+	Append(ctx context.Context, records ...VersionRecord) error
+
+	// ReplaceLast replaces the latest record, e.g. to clear dirty mark of completed migration.
+	ReplaceLast(ctx context.Context, rec VersionRecord) error
+
+	// DeleteLast removes the latest record. Empty history is not an error.
+	DeleteLast(ctx context.Context) error
+}
+
+// SetVersionStore replaces storage of migrations history. By default, history is stored in migrations collection
+// of migrator database (see SetMigrationsCollection). Nil store resets it to default.
+func (m *Migrate) SetVersionStore(store VersionStore) {
+	m.versionStore = store
+}
+
+func (m *Migrate) versions() VersionStore {
+	if m.versionStore != nil {
+		return m.versionStore
+	}
+
+	return collectionVersions{m: m}
+}
+
+// NewCollectionVersionStore returns store keeping history in provided collection,
+// e.g. collection of dedicated admin cluster. Collection is created on the first read of current version.
+func NewCollectionVersionStore(coll *mongo.Collection) VersionStore {
+	return collectionVersions{coll: coll}
+}
+
+// collectionVersions keeps history in provided collection or in migrations collection of migrator database.
+// Read only store does not create collection.
+type collectionVersions struct {
+	m        *Migrate
+	coll     *mongo.Collection
+	readOnly bool
+}
+
+func (v collectionVersions) collection() *mongo.Collection {
+	if v.coll != nil {
+		return v.coll
+	}
+
+	return v.m.db.Collection(v.m.migrationsCollection)
+}
+
+func (v collectionVersions) Current(ctx context.Context) (VersionRecord, error) {
+	if !v.readOnly {
+		if err := createCollection(ctx, v.collection()); err != nil {
+			return VersionRecord{}, err
+		}
+	}
+
+	// find clean record with the greatest id (assuming it`s latest also)
+	rec, _, err := v.findOne(ctx, currentFilter, -1)
+	return rec, err
+}
+
+func (v collectionVersions) Applied(ctx context.Context, version uint64) (VersionRecord, bool, error) {
+	return v.findOne(ctx, append(bson.D{{Key: "version", Value: version}}, cleanFilter...), 1)
+}
+
+func (v collectionVersions) List(ctx context.Context) ([]VersionRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := v.collection().Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []VersionRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (v collectionVersions) Last(ctx context.Context) (VersionRecord, bool, error) {
+	return v.findOne(ctx, bson.D{}, -1)
+}
+
+func (v collectionVersions) Append(ctx context.Context, records ...VersionRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(records))
+	for _, rec := range records {
+		docs = append(docs, rec)
+	}
+
+	_, err := v.collection().InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	return err
+}
+
+func (v collectionVersions) ReplaceLast(ctx context.Context, rec VersionRecord) error {
+	id, found, err := v.lastID(ctx)
+	if err != nil || !found {
+		return err
+	}
+
+	_, err = v.collection().ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, rec)
+	return err
+}
+
+func (v collectionVersions) DeleteLast(ctx context.Context) error {
+	id, found, err := v.lastID(ctx)
+	if err != nil || !found {
+		return err
+	}
+
+	_, err = v.collection().DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	return err
+}
+
+// findOne returns record matching filter with the least (order 1) or the greatest (order -1) id.
+func (v collectionVersions) findOne(ctx context.Context, filter bson.D, order int) (VersionRecord, bool, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: order}})
+
+	var rec VersionRecord
+	err := v.collection().FindOne(ctx, filter, opts).Decode(&rec)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return VersionRecord{}, false, nil
+	case err != nil:
+		return VersionRecord{}, false, err
+	}
+
+	return rec, true, nil
+}
+
+func (v collectionVersions) lastID(ctx context.Context) (bson.RawValue, bool, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetProjection(bson.D{{Key: "_id", Value: 1}})
+
+	var doc struct {
+		ID bson.RawValue `bson:"_id"`
+	}
+	err := v.collection().FindOne(ctx, bson.D{}, opts).Decode(&doc)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return bson.RawValue{}, false, nil
+	case err != nil:
+		return bson.RawValue{}, false, err
+	}
+
+	return doc.ID, true, nil
+}
+
+func createCollection(ctx context.Context, coll *mongo.Collection) error {
+	names, err := coll.Database().ListCollectionNames(ctx, bson.D{{Key: "name", Value: coll.Name()}})
+	if err != nil || len(names) > 0 {
+		return err
+	}
+
+	return coll.Database().RunCommand(ctx, bson.D{{Key: "create", Value: coll.Name()}}).Err()
+}
+
+// MemoryVersionStore keeps migrations history in memory, e.g. for tests of migrations.
+// It is safe for concurrent use.
+type MemoryVersionStore struct {
+	mu      sync.Mutex
+	records []VersionRecord
+}
+
+// NewMemoryVersionStore returns store with empty history.
+func NewMemoryVersionStore() *MemoryVersionStore {
+	return &MemoryVersionStore{}
+}
+
+func (s *MemoryVersionStore) Current(context.Context) (VersionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if !s.records[i].Dirty && !s.records[i].OutOfOrder {
+			return s.records[i], nil
+		}
+	}
+	return VersionRecord{}, nil
+}
+
+func (s *MemoryVersionStore) Applied(_ context.Context, version uint64) (VersionRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rec := range s.records {
+		if rec.Version == version && !rec.Dirty {
+			return rec, true, nil
+		}
+	}
+	return VersionRecord{}, false, nil
+}
+
+func (s *MemoryVersionStore) List(context.Context) ([]VersionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]VersionRecord(nil), s.records...), nil
+}
+
+func (s *MemoryVersionStore) Last(context.Context) (VersionRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) == 0 {
+		return VersionRecord{}, false, nil
+	}
+	return s.records[len(s.records)-1], true, nil
+}
+
+func (s *MemoryVersionStore) Append(_ context.Context, records ...VersionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *MemoryVersionStore) ReplaceLast(_ context.Context, rec VersionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) > 0 {
+		s.records[len(s.records)-1] = rec
+	}
+	return nil
+}
+
+func (s *MemoryVersionStore) DeleteLast(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) > 0 {
+		s.records = s.records[:len(s.records)-1]
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryVersionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVersionStore()
+
+	if _, found, err := store.Last(ctx); err != nil || found {
+		t.Errorf("Unexpected last record of empty history: %v %v", found, err)
+		return
+	}
+	if err := store.Append(ctx, VersionRecord{Version: 1}, VersionRecord{Version: 2, Dirty: true}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	current, err := store.Current(ctx)
+	if err != nil || current.Version != 1 {
+		t.Errorf("Unexpected current record: %+v %v", current, err)
+		return
+	}
+	if _, found, _ := store.Applied(ctx, 2); found {
+		t.Errorf("Dirty record unexpectedly reported as applied")
+	}
+
+	if err := store.ReplaceLast(ctx, VersionRecord{Version: 2}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	if current, _ = store.Current(ctx); current.Version != 2 {
+		t.Errorf("Unexpected current record: %+v", current)
+	}
+
+	if err := store.DeleteLast(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	records, _ := store.List(ctx)
+	if len(records) != 1 || records[0].Version != 1 {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
+
+func TestVersionStoreDirty(t *testing.T) {
+	ctx := context.Background()
+	m := NewMigrate(nil, Migration{Version: 1, Description: "first"}, Migration{Version: 2, Description: "second"})
+	store := NewMemoryVersionStore()
+	m.SetVersionStore(store)
+
+	if err := m.SetVersion(ctx, 1, "first"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	dirty, err := m.markDirty(ctx, m.migrations[1])
+	if err != nil || !dirty {
+		t.Errorf("Unexpected result of marking dirty: %v %v", dirty, err)
+		return
+	}
+	rec, found, err := m.DirtyVersion(ctx)
+	if err != nil || !found || rec.Version != 2 {
+		t.Errorf("Unexpected dirty version: %+v %v %v", rec, found, err)
+		return
+	}
+
+	version, _, err := m.Version(ctx)
+	if err != nil || version != 1 {
+		t.Errorf("Unexpected version: %d %v", version, err)
+		return
+	}
+
+	if err := m.commitVersion(ctx, dirty, newVersionRecord(m.migrations[1])); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+	records, _ := m.Versions().List(ctx)
+	if len(records) != 2 || records[1].Version != 2 || records[1].Dirty {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"context"
+)
+
+// Versions provides read access to migrations history. See VersionStore for complete storage contract.
+type Versions interface {
+	// Current returns the latest record of completed migration which is not out of order.
+	// Zero value returned if no migrations were applied.
+	Current(ctx context.Context) (VersionRecord, error)
+
+	// Applied returns the earliest record of provided version.
+	// Found is false if version was never recorded.
+	Applied(ctx context.Context, version uint64) (rec VersionRecord, found bool, err error)
+
+	// List returns all version records in order of recording, including dirty ones.
+	List(ctx context.Context) ([]VersionRecord, error)
+}
+
+// Versions returns read access to migrations history.
+func (m *Migrate) Versions() Versions {
+	return m.versions()
+}
+
+// VersionsFromContext returns migrations history of Migrate which performs current migration.
+// Use it inside migration callbacks which depend on previously applied versions.
+func VersionsFromContext(ctx context.Context) (Versions, bool) {
+	state, ok := runFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	return state.migrate.Versions(), true
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVersionsFromContextOutsideMigration(t *testing.T) {
+	if _, ok := VersionsFromContext(context.Background()); ok {
+		t.Errorf("Unexpectedly found versions outside of migration")
+	}
+
+	m := NewMigrate(nil)
+	ctx := m.runContext(context.Background(), Migration{Version: 1}, directionUp)
+	if _, ok := VersionsFromContext(ctx); !ok {
+		t.Errorf("Unexpectedly not found versions inside of migration")
+	}
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultViewsCollection = "migrations_views"
+	viewStagingSuffix      = "_refresh"
+)
+
+// MaterializedView describes collection built from aggregation pipeline over source collection.
+// Unlike versioned migrations it is repeatable: it's refreshed by every "Up" call.
+// Refresh merges pipeline results into staging collection "<name>_refresh" with indexes of target collection
+// and renames it over target, so documents which are not produced by pipeline anymore are removed.
+type MaterializedView struct {
+	// Name is a name of target collection.
+	Name string
+
+	// Source is a name of collection aggregated by Pipeline.
+	Source   string
+	Pipeline mongo.Pipeline
+
+	// On is a list of fields identifying target documents for "$merge" stage. Default is "_id".
+	// Target collection must have unique index on these fields, it is copied to staging collection.
+	// If target collection doesn't exist yet, the index is created by the first refresh.
+	On []string
+
+	// MaxAge makes refresh skipped if view was rebuilt less than MaxAge ago. Zero means refresh on every run.
+	MaxAge time.Duration
+}
+
+// ViewRecord is a freshness metadata of materialized view.
+type ViewRecord struct {
+	Name        string        `bson:"_id"`
+	Source      string        `bson:"source"`
+	RefreshedAt time.Time     `bson:"refreshed_at"`
+	Duration    time.Duration `bson:"duration"`
+
+	// Version is a version of database at the moment of refresh.
+	Version uint64 `bson:"version"`
+}
+
+// SetViewsCollection replaces name of collection for storing freshness metadata of materialized views.
+// By default, it is "migrations_views".
+func (m *Migrate) SetViewsCollection(name string) {
+	m.viewsCollection = name
+}
+
+// SetMaterializedViews sets materialized views refreshed after versioned migrations by every "Up" call.
+func (m *Migrate) SetMaterializedViews(views ...MaterializedView) {
+	m.views = views
+}
+
+// RefreshViews rebuilds all materialized views which are older than their MaxAge.
+func (m *Migrate) RefreshViews(ctx context.Context) error {
+	for _, view := range m.views {
+		if err := m.refreshView(ctx, view, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RefreshView forcibly rebuilds materialized view ignoring its MaxAge.
+func (m *Migrate) RefreshView(ctx context.Context, view MaterializedView) error {
+	return m.refreshView(ctx, view, true)
+}
+
+// Views returns freshness metadata of materialized views sorted by name.
+func (m *Migrate) Views(ctx context.Context) ([]ViewRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := m.db.Collection(m.viewsCollection).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ViewRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (m *Migrate) refreshView(ctx context.Context, view MaterializedView, force bool) error {
+	if err := m.matchViewNamespace(view); err != nil {
+		return err
+	}
+
+	if !force {
+		due, err := m.viewDue(ctx, view)
+		if err != nil || !due {
+			return err
+		}
+	}
+
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	if err := m.buildView(ctx, view); err != nil {
+		return fmt.Errorf("migrate: refresh view %q: %w", view.Name, err)
+	}
+
+	rec := ViewRecord{
+		Name:        view.Name,
+		Source:      view.Source,
+		RefreshedAt: time.Now().UTC(),
+		Duration:    time.Since(started),
+		Version:     version,
+	}
+	opts := options.Replace().SetUpsert(true)
+	if _, err := m.db.Collection(m.viewsCollection).ReplaceOne(ctx, bson.D{{Key: "_id", Value: view.Name}}, rec, opts); err != nil {
+		return fmt.Errorf("migrate: save freshness of view %q: %w", view.Name, err)
+	}
+
+	m.report(MsgViewRefreshed, view.Name, rec.Duration)
+	return nil
+}
+
+// buildView merges pipeline results into fresh staging collection and renames it over target one.
+func (m *Migrate) buildView(ctx context.Context, view MaterializedView) error {
+	if err := m.checkCommands("drop", "create", "createIndexes", "aggregate", "renameCollection"); err != nil {
+		return err
+	}
+	if err := m.matchViewNamespace(view); err != nil {
+		return err
+	}
+
+	staging := m.db.Collection(view.Name + viewStagingSuffix)
+	// staging collection may be left by interrupted refresh
+	if err := staging.Drop(ctx); err != nil {
+		return err
+	}
+	// pipeline may produce no documents, but empty view must replace target anyway
+	if err := m.db.CreateCollection(ctx, staging.Name()); err != nil {
+		return err
+	}
+
+	names, err := m.db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: view.Name}})
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		if err := copyIndexes(ctx, m.db.Collection(view.Name), staging); err != nil {
+			return err
+		}
+	} else if keys := viewOnKeys(view); len(keys) > 0 {
+		// "$merge" requires unique index on fields of "on"
+		if _, err := staging.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: options.Index().SetUnique(true)}); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := m.db.Collection(view.Source).Aggregate(ctx, viewPipeline(view, staging.Name()))
+	if err != nil {
+		return err
+	}
+	if err := cursor.Close(ctx); err != nil {
+		return err
+	}
+
+	return renameCollection(ctx, m.db, staging.Name(), view.Name, true)
+}
+
+// viewDue reports if view is older than its MaxAge and should be refreshed.
+func (m *Migrate) viewDue(ctx context.Context, view MaterializedView) (bool, error) {
+	if view.MaxAge <= 0 {
+		return true, nil
+	}
+
+	var rec ViewRecord
+	err := m.db.Collection(m.viewsCollection).FindOne(ctx, bson.D{{Key: "_id", Value: view.Name}}).Decode(&rec)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("migrate: load freshness of view %q: %w", view.Name, err)
+	}
+	return time.Since(rec.RefreshedAt) >= view.MaxAge, nil
+}
+
+// matchViewNamespace returns ErrNamespaceFiltered if filter excludes any collection touched by refresh of view.
+func (m *Migrate) matchViewNamespace(view MaterializedView) error {
+	return m.checkNamespaces(view.Name, view.Source, view.Name+viewStagingSuffix)
+}
+
+// viewOnKeys returns keys of unique index required by "$merge" on fields of view.On.
+// It's nil if documents are merged by "_id" which is indexed anyway.
+func viewOnKeys(view MaterializedView) bson.D {
+	if len(view.On) == 0 || len(view.On) == 1 && view.On[0] == "_id" {
+		return nil
+	}
+
+	keys := make(bson.D, 0, len(view.On))
+	for _, field := range view.On {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	return keys
+}
+
+// viewPipeline returns pipeline of view merging results into collection.
+func viewPipeline(view MaterializedView, into string) mongo.Pipeline {
+	merge := bson.D{{Key: "into", Value: into}}
+	if len(view.On) > 0 {
+		merge = append(merge, bson.E{Key: "on", Value: view.On})
+	}
+	merge = append(merge,
+		bson.E{Key: "whenMatched", Value: "replace"},
+		bson.E{Key: "whenNotMatched", Value: "insert"},
+	)
+
+	pipeline := make(mongo.Pipeline, 0, len(view.Pipeline)+1)
+	pipeline = append(pipeline, view.Pipeline...)
+	return append(pipeline, bson.D{{Key: "$merge", Value: merge}})
+}
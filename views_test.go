@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestViewPipeline(t *testing.T) {
+	view := MaterializedView{
+		Name:     "totals",
+		Source:   "orders",
+		Pipeline: mongo.Pipeline{{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$customer"}}}}},
+		On:       []string{"_id"},
+	}
+
+	expected := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$customer"}}}},
+		{{Key: "$merge", Value: bson.D{
+			{Key: "into", Value: "totals_refresh"},
+			{Key: "on", Value: []string{"_id"}},
+			{Key: "whenMatched", Value: "replace"},
+			{Key: "whenNotMatched", Value: "insert"},
+		}}},
+	}
+	if actual := viewPipeline(view, "totals_refresh"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected pipeline: %v", actual)
+	}
+	if len(view.Pipeline) != 1 {
+		t.Errorf("Source pipeline modified: %v", view.Pipeline)
+	}
+}
+
+func TestViewOnKeys(t *testing.T) {
+	if keys := viewOnKeys(MaterializedView{On: []string{"_id"}}); keys != nil {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+	keys := viewOnKeys(MaterializedView{On: []string{"customer", "day"}})
+	if !reflect.DeepEqual(keys, bson.D{{Key: "customer", Value: 1}, {Key: "day", Value: 1}}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
+
+func TestMatchViewNamespace(t *testing.T) {
+	m := NewMigrate(nil)
+	if err := m.SetNamespaceFilter(NamespaceFilter{Exclude: []string{"orders", "totals_refresh"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+		return
+	}
+
+	if err := m.matchViewNamespace(MaterializedView{Name: "totals", Source: "orders"}); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// staging collection is excluded
+	if err := m.matchViewNamespace(MaterializedView{Name: "totals", Source: "payments"}); !errors.Is(err, ErrNamespaceFiltered) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := m.matchViewNamespace(MaterializedView{Name: "summary", Source: "payments"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}